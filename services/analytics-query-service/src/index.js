@@ -4,6 +4,7 @@ const { CosmosClient } = require("@azure/cosmos");
 const UAParser = require('ua-parser-js');
 const cors = require('cors');
 const crypto = require('crypto');
+const { CosmosNonceStore, verifier } = require('./signing');
 
 // Polyfill for Cosmos DB SDK on Node < 19
 if (!global.crypto) {
@@ -17,6 +18,10 @@ const PORT = process.env.PORT || 3001;
 const COSMOS_CONNECTION_STRING = process.env.COSMOS_CONNECTION_STRING;
 const COSMOS_DATABASE_NAME = process.env.COSMOS_DATABASE_NAME || "analytics-db";
 const COSMOS_CONTAINER_NAME = process.env.COSMOS_CONTAINER_NAME || "clicks";
+// Shared with link-management-service, which signs its calls to purge click data of deleted accounts
+const ANALYTICS_PURGE_TOKEN = process.env.ANALYTICS_PURGE_TOKEN;
+const PURGE_SERVICE_ID = process.env.PURGE_SERVICE_ID || "link-management-service";
+const COSMOS_NONCE_CONTAINER_NAME = process.env.COSMOS_NONCE_CONTAINER_NAME || "service-nonces";
 
 if (!COSMOS_CONNECTION_STRING) {
     console.error("Error: Missing COSMOS_CONNECTION_STRING environment variable.");
@@ -24,10 +29,12 @@ if (!COSMOS_CONNECTION_STRING) {
 }
 
 const cosmosClient = new CosmosClient(COSMOS_CONNECTION_STRING);
-const container = cosmosClient.database(COSMOS_DATABASE_NAME).container(COSMOS_CONTAINER_NAME);
+const database = cosmosClient.database(COSMOS_DATABASE_NAME);
+const container = database.container(COSMOS_CONTAINER_NAME);
 
 app.use(cors());
-app.use(express.json());
+// Keep the raw body, signatures cover it
+app.use(express.json({ limit: '1mb', verify: (req, res, buf) => { req.rawBody = buf; } }));
 
 // --- Helper: Process Analytics Data ---
 function processAnalytics(items) {
@@ -104,6 +111,34 @@ app.get('/api/analytics/:shortCode', async (req, res) => {
     }
 });
 
+// --- Purge Endpoint (service-to-service) ---
+// Without a secret of at least 32 characters no call can be verified, so purging stays off
+const requirePurgeSignature = ANALYTICS_PURGE_TOKEN && ANALYTICS_PURGE_TOKEN.length >= 32
+    ? verifier({ [PURGE_SERVICE_ID]: ANALYTICS_PURGE_TOKEN }, 5 * 60, new CosmosNonceStore(database, COSMOS_NONCE_CONTAINER_NAME))
+    : (req, res) => res.status(401).json({ error: "Invalid service signature" });
+
+app.delete('/api/analytics/:shortCode', requirePurgeSignature, async (req, res) => {
+    const { shortCode } = req.params;
+
+    try {
+        const querySpec = {
+            query: "SELECT c.id FROM c WHERE c.short_code = @shortCode",
+            parameters: [{ name: "@shortCode", value: shortCode }]
+        };
+        const { resources: items } = await container.items.query(querySpec, { partitionKey: shortCode }).fetchAll();
+
+        for (const item of items) {
+            await container.item(item.id, shortCode).delete();
+        }
+        console.log(`Purged ${items.length} clicks for: ${shortCode}`);
+        res.status(204).end();
+
+    } catch (error) {
+        console.error("Cosmos DB Error:", error);
+        res.status(500).json({ error: "Failed to purge analytics data" });
+    }
+});
+
 app.get('/health', (req, res) => {
     res.send('Analytics Query Service is Healthy');
 });
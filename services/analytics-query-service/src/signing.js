@@ -0,0 +1,101 @@
+// Verifies service-to-service calls signed by services/pkg/signing (Go).
+// The caller signs its service id, the method, path and query, a timestamp,
+// a random nonce and the body hash with a secret both sides share; unsigned,
+// stale and replayed requests are rejected.
+const crypto = require('crypto');
+
+const HEADER_SERVICE = 'X-Service-Id';
+const HEADER_TIMESTAMP = 'X-Service-Timestamp'; // Unix seconds
+const HEADER_NONCE = 'X-Service-Nonce';
+const HEADER_SIGNATURE = 'X-Service-Signature'; // Hex HMAC-SHA256 of the canonical request
+
+function sign(secret, req, serviceId, timestamp, nonce, body) {
+    const bodyHash = crypto.createHash('sha256').update(body).digest('hex');
+    const canonical = [serviceId, req.method, req.originalUrl, timestamp, nonce, bodyHash].join('\n');
+    return crypto.createHmac('sha256', secret).update(canonical).digest();
+}
+
+// Nonces are kept in a Cosmos container shared by every replica, so a request
+// replayed to another replica is caught too. Each nonce document expires once
+// its signature would be stale anyway.
+class CosmosNonceStore {
+    constructor(database, containerName) {
+        this.database = database;
+        this.containerName = containerName;
+        this.ready = null;
+    }
+
+    container() {
+        if (!this.ready) {
+            this.ready = this.database.containers
+                .createIfNotExists({ id: this.containerName, partitionKey: { paths: ['/id'] }, defaultTtl: -1 })
+                .then(({ container }) => container);
+            // Try again on the next call
+            this.ready.catch(() => { this.ready = null; });
+        }
+        return this.ready;
+    }
+
+    // Resolves to true if the key was not used before
+    async use(key, ttlSeconds) {
+        const container = await this.container();
+        // Document ids may not contain some characters, so store a hash
+        const id = crypto.createHash('sha256').update(key).digest('hex');
+        try {
+            await container.items.create({ id, ttl: ttlSeconds });
+            return true;
+        } catch (error) {
+            if (error.code === 409) {
+                return false;
+            }
+            throw error;
+        }
+    }
+}
+
+// secrets maps the accepted service ids to their shared secrets. The request
+// body must have been kept as req.rawBody.
+function verifier(secrets, maxSkewSeconds, nonces) {
+    return async (req, res, next) => {
+        const serviceId = req.get(HEADER_SERVICE) || '';
+        const timestamp = req.get(HEADER_TIMESTAMP) || '';
+        const nonce = req.get(HEADER_NONCE) || '';
+        const signature = Buffer.from(req.get(HEADER_SIGNATURE) || '', 'hex');
+
+        const reject = (reason) => {
+            console.warn(`Rejected service call ${req.method} ${req.path}: ${reason}`);
+            res.status(401).json({ error: "Invalid service signature" });
+        };
+
+        if (!serviceId || !timestamp || nonce.length < 16 || signature.length === 0) {
+            return reject("missing signature");
+        }
+        const secret = secrets[serviceId];
+        if (!secret) {
+            return reject("unknown service");
+        }
+        const signedAt = Number(timestamp);
+        if (!/^\d+$/.test(timestamp) || Math.abs(Date.now() / 1000 - signedAt) > maxSkewSeconds) {
+            return reject("stale signature");
+        }
+        const expected = sign(secret, req, serviceId, timestamp, nonce, req.rawBody || Buffer.alloc(0));
+        if (signature.length !== expected.length || !crypto.timingSafeEqual(signature, expected)) {
+            return reject("invalid signature");
+        }
+
+        try {
+            // Only remember nonces of valid signatures, so nobody can fill the store
+            const ttl = Math.max(1, Math.ceil(signedAt + maxSkewSeconds - Date.now() / 1000));
+            if (!(await nonces.use(`${serviceId}:${nonce}`, ttl))) {
+                return reject("replayed signature");
+            }
+        } catch (error) {
+            console.error("Nonce store error:", error);
+            return res.status(500).json({ error: "Failed to verify service signature" });
+        }
+        req.serviceId = serviceId;
+        next();
+    };
+}
+
+module.exports = { CosmosNonceStore, verifier };
@@ -1,18 +1,34 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/authn"
 	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/config"
 	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/database"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/events"
 	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/handler"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/kek"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/keys"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/lockout"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/middleware"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/notify"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/password"
 	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
 	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
 )
 
+// Largest request body accepted; our requests are small JSON documents
+const maxRequestBody = 1 << 20
+
 func main() {
 	// Load Config
 	cfg := config.LoadConfig()
@@ -24,13 +40,90 @@ func main() {
 	}
 	defer db.Close()
 
+	// Secrets stored in the shared database are sealed with a key only we hold
+	secrets := newKEK(cfg)
+
+	// Load JWT Signing Keys and keep them rotating
+	keyring := keys.NewKeyring()
+	keySvc := service.NewKeyService(repository.NewKeyRepository(db), keyring, secrets, cfg)
+	if err := keySvc.Init(); err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+	go keySvc.Run(time.Minute)
+
+	// Outgoing account messages (password resets, email verification)
+	notifier := notify.New(cfg.Notifier, cfg.NotifyOutbox, &notify.SMTPNotifier{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUser,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+	})
+
+	// Failed login tracking
+	guard := &lockout.Guard{Store: newLockoutStore(cfg, db), Account: cfg.AccountLockout, IP: cfg.IPLockout}
+
 	// Initialize Layers
 	repo := repository.NewUserRepository(db)
-	svc := service.NewAuthService(repo, cfg)
-	h := handler.NewAuthHandler(svc)
+	sessions := repository.NewSessionRepository(db)
+	userTokens := repository.NewUserTokenRepository(db)
+	roles := repository.NewRoleRepository(db)
+	securityEvents := service.NewSecurityEventService(repository.NewSecurityEventRepository(db), repo, events.New(cfg.SecurityEventSink, cfg.SecurityEventFile))
+	if err := cfg.PasswordHash.Validate(); err != nil {
+		log.Fatalf("Invalid password hash settings: %v", err)
+	}
+	hasher := password.NewHasher(cfg.PasswordHash)
+	if cfg.PasswordBreachedFile != "" {
+		breached, err := password.OpenBreachedList(cfg.PasswordBreachedFile)
+		if err != nil {
+			log.Fatalf("Failed to open breached password list: %v", err)
+		}
+		cfg.PasswordPolicy.Breached = breached
+	}
+	svc := service.NewAuthService(repo, sessions, userTokens, roles, keyring, guard, newAuthenticator(cfg, hasher), hasher, securityEvents, cfg)
+	mfaSvc := service.NewMFAService(svc, repository.NewRecoveryCodeRepository(db), secrets)
+	emailSvc := service.NewEmailService(svc, userTokens, repository.NewEmailChangeRepository(db), notifier)
+	scimRepo := repository.NewScimRepository(db)
+	deletion := service.NewAccountDeletionService(svc, scimRepo, cfg.AccountDeletionGrace)
+	go deletion.Run(time.Hour)
+	if !models.ValidRegistrationMode(cfg.RegistrationMode) {
+		log.Fatalf("Unknown REGISTRATION_MODE %q", cfg.RegistrationMode)
+	}
+	registrationSvc := service.NewRegistrationService(svc, repository.NewRegistrationRepository(db))
+	h := handler.NewAuthHandler(svc, registrationSvc, emailSvc, securityEvents)
+	admin := handler.NewAdminHandler(keySvc, mfaSvc, service.NewUserAdminService(svc), deletion, service.NewRoleService(roles), securityEvents)
+	apiKeys := handler.NewApiKeyHandler(service.NewApiKeyService(repository.NewApiKeyRepository(db)), securityEvents)
+	passwords := handler.NewPasswordHandler(service.NewPasswordService(svc, userTokens, notifier), securityEvents)
+	emails := handler.NewEmailHandler(emailSvc, securityEvents)
+	mfa := handler.NewMFAHandler(mfaSvc, securityEvents)
+	eventLog := handler.NewSecurityEventHandler(securityEvents)
+	orgSvc := service.NewOrgService(repository.NewOrgRepository(db))
+	orgs := handler.NewOrgHandler(orgSvc)
+	oidcLogin := handler.NewOIDCHandler(service.NewOIDCService(svc, repository.NewExternalIdentityRepository(db), registrationSvc, cfg.OIDCProviders), securityEvents,
+		strings.HasPrefix(cfg.AppBaseURL, "https://"))
+	invites := handler.NewInviteHandler(service.NewInviteService(svc, orgSvc, registrationSvc, repository.NewOrgInviteRepository(db), notifier))
+	scimApi := handler.NewScimHandler(service.NewScimService(svc, scimRepo))
+	account := handler.NewAccountHandler(service.NewAccountService(svc, scimRepo), deletion, securityEvents)
+	exportSvc := service.NewDataExportService(svc, repository.NewDataExportRepository(db), repository.NewApiKeyRepository(db),
+		repository.NewExternalIdentityRepository(db), repository.NewOrgRepository(db), repository.NewSecurityEventRepository(db),
+		cfg.DataExportTTL, cfg.DataExportTimeout)
+	go exportSvc.Run(time.Minute)
+	exports := handler.NewDataExportHandler(exportSvc, securityEvents)
+	registration := handler.NewRegistrationHandler(registrationSvc)
+	introspection := handler.NewIntrospectionHandler(service.NewIntrospectionService(svc, repository.NewApiKeyRepository(db)), cfg.IntrospectionCache)
 
 	// Initialize Gin router
 	r := gin.Default()
+	// Gin trusts X-Forwarded-For from anyone by default, which would let
+	// clients pick the IP that lockouts and security events see
+	var proxies []string
+	if cfg.TrustedProxies != "" {
+		proxies = strings.Split(cfg.TrustedProxies, ",")
+	}
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	r.Use(middleware.LimitBody(maxRequestBody))
 
 	// Health check endpoint
 	r.GET("/health", func(c *gin.Context) {
@@ -40,11 +133,148 @@ func main() {
 		})
 	})
 
+	// Public keys for token verification
+	r.GET("/.well-known/jwks.json", h.JWKS)
+
 	// Auth Routes
 	api := r.Group("/api/auth")
 	{
+		api.GET("/register", h.RegistrationInfo)
 		api.POST("/register", h.Register)
 		api.POST("/login", h.Login)
+		api.POST("/login/mfa", mfa.LoginMFA)
+		api.POST("/refresh", h.Refresh)
+		api.POST("/logout", h.Logout)
+		api.POST("/password/forgot", passwords.ForgotPassword)
+		api.POST("/password/reset", passwords.ResetPassword)
+		api.POST("/password", middleware.RequireAuth(svc), middleware.DenyImpersonation(), passwords.ChangePassword)
+		api.POST("/email/verify", emails.VerifyEmail)
+		api.POST("/email/verify/resend", middleware.RequireAuth(svc), emails.ResendVerification)
+		api.POST("/email", middleware.RequireAuth(svc), middleware.DenyImpersonation(), emails.ChangeEmail)
+		api.POST("/email/confirm", emails.ConfirmEmailChange)
+		api.POST("/invites/accept", middleware.RequireAuth(svc), middleware.DenyImpersonation(), invites.AcceptInvite)
+		api.POST("/invites/register", invites.RegisterWithInvite)
+	}
+
+	// Current User Routes
+	meApi := r.Group("/api/auth/me")
+	meApi.Use(middleware.RequireAuth(svc))
+	{
+		meApi.GET("", account.GetMe)
+		meApi.PATCH("", middleware.DenyImpersonation(), account.UpdateMe)
+		meApi.GET("/sessions", account.ListSessions)
+		meApi.DELETE("/sessions/:id", middleware.DenyImpersonation(), account.RevokeSession)
+		meApi.GET("/events", eventLog.MyEvents)
+		meApi.POST("/delete", middleware.DenyImpersonation(), account.RequestDeletion)
+		meApi.POST("/delete/cancel", middleware.DenyImpersonation(), account.CancelDeletion)
+		meApi.POST("/exports", middleware.DenyImpersonation(), exports.RequestExport)
+		meApi.GET("/exports", middleware.DenyImpersonation(), exports.ListExports)
+		meApi.GET("/exports/:id", middleware.DenyImpersonation(), exports.GetExport)
+		meApi.GET("/exports/:id/download", middleware.DenyImpersonation(), exports.DownloadExport)
+	}
+
+	// Single Sign-On Routes (OpenID Connect)
+	oidcApi := r.Group("/api/auth/oidc")
+	{
+		oidcApi.GET("/providers", oidcLogin.ListProviders)
+		oidcApi.POST("/:provider/authorize", oidcLogin.Authorize)
+		oidcApi.POST("/:provider/link", middleware.RequireAuth(svc), middleware.DenyImpersonation(), oidcLogin.Link)
+		oidcApi.POST("/:provider/callback", oidcLogin.Callback)
+		oidcApi.GET("/identities", middleware.RequireAuth(svc), oidcLogin.ListIdentities)
+		oidcApi.DELETE("/identities/:id", middleware.RequireAuth(svc), middleware.DenyImpersonation(), oidcLogin.Unlink)
+	}
+
+	// Two-Factor Authentication Routes
+	mfaApi := r.Group("/api/auth/2fa")
+	mfaApi.Use(middleware.RequireAuth(svc), middleware.DenyImpersonation())
+	{
+		mfaApi.POST("/setup", mfa.SetupTOTP)
+		mfaApi.POST("/confirm", mfa.ConfirmTOTP)
+		mfaApi.POST("/disable", mfa.DisableTOTP)
+		mfaApi.POST("/recovery-codes", mfa.RegenerateRecoveryCodes)
+	}
+
+	// API Key Routes (personal access tokens for scripts and CI)
+	keysApi := r.Group("/api/auth/api-keys")
+	keysApi.Use(middleware.RequireAuth(svc))
+	{
+		keysApi.POST("", middleware.DenyImpersonation(), apiKeys.CreateApiKey)
+		keysApi.GET("", apiKeys.ListApiKeys)
+		keysApi.DELETE("/:id", middleware.DenyImpersonation(), apiKeys.RevokeApiKey)
+	}
+
+	// Organization Routes (teams that share links)
+	orgsApi := r.Group("/api/auth/orgs")
+	orgsApi.Use(middleware.RequireAuth(svc))
+	{
+		orgsApi.POST("", orgs.CreateOrg)
+		orgsApi.GET("", orgs.ListOrgs)
+		orgsApi.GET("/:id", orgs.GetOrg)
+		orgsApi.PATCH("/:id", orgs.RenameOrg)
+		orgsApi.GET("/:id/members", orgs.ListMembers)
+		orgsApi.PUT("/:id/members/:userId", orgs.UpdateMember)
+		orgsApi.DELETE("/:id/members/:userId", orgs.RemoveMember)
+		orgsApi.POST("/:id/invites", invites.CreateInvite)
+		orgsApi.GET("/:id/invites", invites.ListInvites)
+		orgsApi.DELETE("/:id/invites/:inviteId", invites.RevokeInvite)
+	}
+
+	// Admin Routes
+	adminApi := r.Group("/api/auth/admin")
+	adminApi.Use(middleware.RequireAuth(svc), middleware.DenyImpersonation())
+	{
+		keysAdmin := adminApi.Group("/keys", middleware.RequirePermission(models.PermKeysManage))
+		keysAdmin.GET("", admin.ListKeys)
+		keysAdmin.POST("/rotate", admin.RotateKey)
+
+		usersAdmin := adminApi.Group("/users", middleware.RequirePermission(models.PermUsersManage))
+		usersAdmin.GET("", admin.ListUsers)
+		usersAdmin.GET("/:id", admin.GetUser)
+		usersAdmin.PUT("/:id/role", admin.ChangeRole)
+		usersAdmin.POST("/:id/disable", admin.DisableUser)
+		usersAdmin.POST("/:id/enable", admin.EnableUser)
+		usersAdmin.POST("/:id/logout", admin.ForceLogout)
+		usersAdmin.POST("/:id/impersonate", admin.Impersonate)
+		usersAdmin.POST("/:id/2fa/reset", admin.ResetMFA)
+		usersAdmin.POST("/:id/delete", admin.DeleteUser)
+		usersAdmin.POST("/:id/delete/cancel", admin.CancelDeletion)
+
+		adminApi.GET("/events", middleware.RequirePermission(models.PermUsersManage), eventLog.ListEvents)
+
+		registrationAdmin := adminApi.Group("/registration", middleware.RequirePermission(models.PermUsersManage))
+		registrationAdmin.GET("", registration.GetSettings)
+		registrationAdmin.PUT("", registration.UpdateSettings)
+		registrationAdmin.GET("/codes", registration.ListCodes)
+		registrationAdmin.POST("/codes", registration.CreateCode)
+		registrationAdmin.DELETE("/codes/:id", registration.RevokeCode)
+
+		rolesAdmin := adminApi.Group("", middleware.RequirePermission(models.PermRolesManage))
+		rolesAdmin.GET("/roles", admin.ListRoles)
+		rolesAdmin.PUT("/roles/:name", admin.PutRole)
+		rolesAdmin.GET("/permissions", admin.ListPermissions)
+	}
+
+	// Token introspection (RFC 7662) for services that cannot verify tokens
+	// themselves, authenticated with client credentials
+	if len(cfg.ServiceClients) > 0 {
+		r.POST("/api/auth/introspect", middleware.RequireServiceClient(cfg.ServiceClients), introspection.Introspect)
+	}
+
+	// SCIM provisioning, authenticated with per-tenant bearer tokens
+	if len(cfg.ScimTenants) > 0 {
+		scimRoutes := r.Group("/scim/v2", middleware.RequireScimTenant(cfg.ScimTenants))
+		{
+			scimRoutes.GET("/Users", scimApi.ListUsers)
+			scimRoutes.POST("/Users", scimApi.CreateUser)
+			scimRoutes.GET("/Users/:id", scimApi.GetUser)
+			scimRoutes.PATCH("/Users/:id", scimApi.PatchUser)
+			scimRoutes.DELETE("/Users/:id", scimApi.DeleteUser)
+			scimRoutes.GET("/Groups", scimApi.ListGroups)
+			scimRoutes.POST("/Groups", scimApi.CreateGroup)
+			scimRoutes.GET("/Groups/:id", scimApi.GetGroup)
+			scimRoutes.PATCH("/Groups/:id", scimApi.PatchGroup)
+			scimRoutes.DELETE("/Groups/:id", scimApi.DeleteGroup)
+		}
 	}
 
 	// Start server
@@ -53,3 +283,73 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// newAuthenticator builds the password check from AUTH_BACKEND, e.g. "ldap"
+// or "ldap,local" to keep local accounts working next to the directory.
+func newAuthenticator(cfg *config.Config, hasher *password.Hasher) authn.Authenticator {
+	var chain authn.Chain
+	for _, name := range cfg.AuthBackends {
+		switch name {
+		case "local":
+			chain = append(chain, authn.Local{Passwords: hasher})
+		case "ldap":
+			if cfg.LDAP.URL == "" || cfg.LDAP.BaseDN == "" {
+				log.Fatalf("AUTH_BACKEND includes ldap but LDAP_URL or LDAP_BASE_DN is not set")
+			}
+			chain = append(chain, &authn.LDAP{Config: cfg.LDAP})
+		case "":
+		default:
+			log.Fatalf("Unknown AUTH_BACKEND %q", name)
+		}
+	}
+	if len(chain) == 0 {
+		return authn.Local{Passwords: hasher}
+	}
+	if len(chain) == 1 {
+		return chain[0]
+	}
+	return chain
+}
+
+// newKEK loads KEY_ENCRYPTION_KEY(_FILE). There is no default: a key kept
+// next to the data it protects would protect nothing.
+func newKEK(cfg *config.Config) *kek.KEK {
+	current := cfg.KeyEncryptionKey
+	if current == "" && cfg.KeyEncryptionKeyFile != "" {
+		data, err := os.ReadFile(cfg.KeyEncryptionKeyFile)
+		if err != nil {
+			log.Fatalf("Failed to read KEY_ENCRYPTION_KEY_FILE: %v", err)
+		}
+		current = string(data)
+	}
+	secrets, err := kek.Parse(current, cfg.PreviousKEKs)
+	if err != nil {
+		log.Fatalf("Invalid KEY_ENCRYPTION_KEY: %v (generate one with `openssl rand -base64 32`)", err)
+	}
+	return secrets
+}
+
+func newLockoutStore(cfg *config.Config, db *sql.DB) lockout.Store {
+	// Forget counters once neither policy would still count them
+	maxAge := cfg.AccountLockout.Window
+	if cfg.IPLockout.Window > maxAge {
+		maxAge = cfg.IPLockout.Window
+	}
+
+	if cfg.LockoutStore == "memory" {
+		return lockout.NewMemoryStore(maxAge)
+	}
+	if cfg.LockoutStore != "sql" {
+		log.Printf("Unknown LOGIN_LOCKOUT_STORE %q, using sql", cfg.LockoutStore)
+	}
+
+	store := repository.NewLoginAttemptRepository(db)
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := store.Prune(time.Now().UTC().Add(-maxAge)); err != nil {
+				log.Printf("Failed to prune login attempts: %v", err)
+			}
+		}
+	}()
+	return store
+}
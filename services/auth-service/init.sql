@@ -10,3 +10,516 @@ BEGIN
     );
 END
 GO
+
+-- Create Sessions table (one row per login / refresh token family)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='Sessions' and xtype='U')
+BEGIN
+    CREATE TABLE Sessions (
+        ID NVARCHAR(64) PRIMARY KEY,
+        UserID INT NOT NULL FOREIGN KEY REFERENCES Users(ID),
+        CreatedAt DATETIME DEFAULT GETUTCDATE(),
+        ExpiresAt DATETIME NOT NULL,
+        RevokedAt DATETIME NULL
+    );
+
+    CREATE INDEX IX_Sessions_UserID ON Sessions(UserID);
+END
+GO
+
+-- Create RefreshTokens table (only SHA-256 hashes are stored)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='RefreshTokens' and xtype='U')
+BEGIN
+    CREATE TABLE RefreshTokens (
+        ID INT IDENTITY(1,1) PRIMARY KEY,
+        SessionID NVARCHAR(64) NOT NULL FOREIGN KEY REFERENCES Sessions(ID),
+        TokenHash NVARCHAR(64) NOT NULL UNIQUE,
+        ExpiresAt DATETIME NOT NULL,
+        UsedAt DATETIME NULL,
+        CreatedAt DATETIME DEFAULT GETUTCDATE()
+    );
+END
+GO
+
+-- Create SigningKeys table (Ed25519 keys for access tokens, sealed with auth-service's key encryption key)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='SigningKeys' and xtype='U')
+BEGIN
+    CREATE TABLE SigningKeys (
+        Kid NVARCHAR(64) PRIMARY KEY,
+        PrivateKey NVARCHAR(MAX) NOT NULL,
+        ActivatesAt DATETIME NOT NULL,
+        RetiresAt DATETIME NULL,
+        CreatedAt DATETIME DEFAULT GETUTCDATE()
+    );
+END
+GO
+
+-- Create ApiKeys table (personal access tokens; only SHA-256 hashes are stored)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='ApiKeys' and xtype='U')
+BEGIN
+    CREATE TABLE ApiKeys (
+        ID INT IDENTITY(1,1) PRIMARY KEY,
+        UserID INT NOT NULL FOREIGN KEY REFERENCES Users(ID),
+        Name NVARCHAR(100) NOT NULL,
+        Prefix NVARCHAR(20) NOT NULL,
+        KeyHash NVARCHAR(64) NOT NULL UNIQUE,
+        Scopes NVARCHAR(200) NOT NULL, -- Comma separated, e.g. links:read,links:write
+        CreatedAt DATETIME DEFAULT GETUTCDATE(),
+        ExpiresAt DATETIME NULL,
+        LastUsedAt DATETIME NULL,
+        RevokedAt DATETIME NULL
+    );
+
+    CREATE INDEX IX_ApiKeys_UserID ON ApiKeys(UserID);
+END
+GO
+
+-- Create UserTokens table (single-use tokens such as password resets; only SHA-256 hashes are stored)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='UserTokens' and xtype='U')
+BEGIN
+    CREATE TABLE UserTokens (
+        ID INT IDENTITY(1,1) PRIMARY KEY,
+        UserID INT NOT NULL FOREIGN KEY REFERENCES Users(ID),
+        Purpose NVARCHAR(30) NOT NULL,
+        TokenHash NVARCHAR(64) NOT NULL UNIQUE,
+        ExpiresAt DATETIME NOT NULL,
+        UsedAt DATETIME NULL,
+        CreatedAt DATETIME DEFAULT GETUTCDATE()
+    );
+
+    CREATE INDEX IX_UserTokens_UserID ON UserTokens(UserID, Purpose);
+END
+GO
+
+-- Add email columns to Users
+IF NOT EXISTS (SELECT * FROM sys.columns WHERE object_id = OBJECT_ID('Users') AND name = 'Email')
+BEGIN
+    ALTER TABLE Users ADD Email NVARCHAR(255) NULL;
+    ALTER TABLE Users ADD EmailVerified BIT NOT NULL DEFAULT 0;
+END
+GO
+
+IF NOT EXISTS (SELECT * FROM sys.indexes WHERE name = 'UX_Users_Email')
+BEGIN
+    CREATE UNIQUE INDEX UX_Users_Email ON Users(Email) WHERE Email IS NOT NULL;
+END
+GO
+
+-- Tokens can carry purpose specific data (e.g. the address being verified)
+IF NOT EXISTS (SELECT * FROM sys.columns WHERE object_id = OBJECT_ID('UserTokens') AND name = 'Data')
+BEGIN
+    ALTER TABLE UserTokens ADD Data NVARCHAR(255) NULL;
+END
+GO
+
+-- Create EmailChanges table (both the old and the new address must confirm)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='EmailChanges' and xtype='U')
+BEGIN
+    CREATE TABLE EmailChanges (
+        ID INT IDENTITY(1,1) PRIMARY KEY,
+        UserID INT NOT NULL FOREIGN KEY REFERENCES Users(ID),
+        NewEmail NVARCHAR(255) NOT NULL,
+        OldTokenHash NVARCHAR(64) NOT NULL UNIQUE,
+        NewTokenHash NVARCHAR(64) NOT NULL UNIQUE,
+        OldConfirmedAt DATETIME NULL,
+        NewConfirmedAt DATETIME NULL,
+        ExpiresAt DATETIME NOT NULL,
+        CompletedAt DATETIME NULL,
+        CancelledAt DATETIME NULL,
+        CreatedAt DATETIME DEFAULT GETUTCDATE()
+    );
+
+    CREATE INDEX IX_EmailChanges_UserID ON EmailChanges(UserID);
+END
+GO
+
+-- Add TOTP two-factor columns to Users
+IF NOT EXISTS (SELECT * FROM sys.columns WHERE object_id = OBJECT_ID('Users') AND name = 'TOTPEnabled')
+BEGIN
+    ALTER TABLE Users ADD TOTPEnabled BIT NOT NULL DEFAULT 0;
+    ALTER TABLE Users ADD TOTPSecret NVARCHAR(64) NULL;
+    ALTER TABLE Users ADD TOTPLastStep BIGINT NULL;
+END
+GO
+
+-- Create RecoveryCodes table (one-time 2FA backup codes; only SHA-256 hashes are stored)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='RecoveryCodes' and xtype='U')
+BEGIN
+    CREATE TABLE RecoveryCodes (
+        ID INT IDENTITY(1,1) PRIMARY KEY,
+        UserID INT NOT NULL FOREIGN KEY REFERENCES Users(ID),
+        CodeHash NVARCHAR(64) NOT NULL,
+        UsedAt DATETIME NULL,
+        CreatedAt DATETIME DEFAULT GETUTCDATE()
+    );
+
+    CREATE INDEX IX_RecoveryCodes_UserID ON RecoveryCodes(UserID);
+END
+GO
+
+-- Create LoginAttempts table (failed login counters and lockouts, keyed by account or client IP)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='LoginAttempts' and xtype='U')
+BEGIN
+    CREATE TABLE LoginAttempts (
+        AttemptKey NVARCHAR(300) PRIMARY KEY,
+        Failures INT NOT NULL,
+        LastFailureAt DATETIME NOT NULL,
+        LockedUntil DATETIME NULL
+    );
+END
+GO
+
+-- Add DisabledAt to Users (set by admins; disabled accounts cannot log in)
+IF NOT EXISTS (SELECT * FROM sys.columns WHERE object_id = OBJECT_ID('Users') AND name = 'DisabledAt')
+BEGIN
+    ALTER TABLE Users ADD DisabledAt DATETIME NULL;
+END
+GO
+
+-- Create role-based access control tables. Users.Role names a row in Roles;
+-- what a role may do is the set of its RolePermissions.
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='Roles' and xtype='U')
+BEGIN
+    CREATE TABLE Roles (
+        Name NVARCHAR(20) PRIMARY KEY,
+        Description NVARCHAR(200) NOT NULL DEFAULT ''
+    );
+
+    CREATE TABLE Permissions (
+        Name NVARCHAR(50) PRIMARY KEY,
+        Description NVARCHAR(200) NOT NULL DEFAULT ''
+    );
+
+    CREATE TABLE RolePermissions (
+        RoleName NVARCHAR(20) NOT NULL FOREIGN KEY REFERENCES Roles(Name) ON DELETE CASCADE,
+        PermissionName NVARCHAR(50) NOT NULL FOREIGN KEY REFERENCES Permissions(Name),
+        PRIMARY KEY (RoleName, PermissionName)
+    );
+END
+GO
+
+-- Permissions known to the services (safe to re-run; new ones are added)
+INSERT INTO Permissions (Name, Description)
+SELECT v.Name, v.Description
+FROM (VALUES
+    ('links:create', 'Create short links'),
+    ('links:custom_alias', 'Choose a custom alias for a link'),
+    ('links:permanent', 'Create links that do not expire'),
+    ('links:unrestricted', 'Exempt from link quotas and the verified email requirement'),
+    ('links:update_any', 'Edit links owned by other users'),
+    ('links:delete_any', 'Delete links owned by other users'),
+    ('users:manage', 'List, disable and change the role of users'),
+    ('roles:manage', 'Create and edit roles'),
+    ('keys:manage', 'View and rotate token signing keys')
+) AS v(Name, Description)
+WHERE NOT EXISTS (SELECT 1 FROM Permissions p WHERE p.Name = v.Name);
+GO
+
+-- Seed the built-in roles once; admins may edit them afterwards
+IF NOT EXISTS (SELECT * FROM Roles)
+BEGIN
+    INSERT INTO Roles (Name, Description) VALUES
+        ('Guest', 'Anonymous visitors'),
+        ('User', 'Registered users'),
+        ('Admin', 'Full access');
+
+    INSERT INTO RolePermissions (RoleName, PermissionName) VALUES
+        ('Guest', 'links:create'),
+        ('User', 'links:create'),
+        ('User', 'links:custom_alias'),
+        ('User', 'links:permanent'),
+        ('Admin', 'links:create'),
+        ('Admin', 'links:custom_alias'),
+        ('Admin', 'links:permanent'),
+        ('Admin', 'links:unrestricted'),
+        ('Admin', 'links:update_any'),
+        ('Admin', 'links:delete_any'),
+        ('Admin', 'users:manage'),
+        ('Admin', 'roles:manage'),
+        ('Admin', 'keys:manage');
+END
+GO
+
+-- Create Organizations and OrgMembers tables (teams that share links; roles are owner, admin, editor, viewer)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='Organizations' and xtype='U')
+BEGIN
+    CREATE TABLE Organizations (
+        ID INT IDENTITY(1,1) PRIMARY KEY,
+        Name NVARCHAR(100) NOT NULL,
+        CreatedBy INT NOT NULL FOREIGN KEY REFERENCES Users(ID),
+        CreatedAt DATETIME DEFAULT GETUTCDATE()
+    );
+
+    CREATE TABLE OrgMembers (
+        OrgID INT NOT NULL FOREIGN KEY REFERENCES Organizations(ID),
+        UserID INT NOT NULL FOREIGN KEY REFERENCES Users(ID),
+        Role NVARCHAR(20) NOT NULL CHECK (Role IN ('owner', 'admin', 'editor', 'viewer')),
+        CreatedAt DATETIME DEFAULT GETUTCDATE(),
+        PRIMARY KEY (OrgID, UserID)
+    );
+
+    CREATE INDEX IX_OrgMembers_UserID ON OrgMembers(UserID);
+END
+GO
+
+-- Create OrgInvites table (single-use invitations bound to a username or email; only SHA-256 hashes are stored)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='OrgInvites' and xtype='U')
+BEGIN
+    CREATE TABLE OrgInvites (
+        ID INT IDENTITY(1,1) PRIMARY KEY,
+        OrgID INT NOT NULL FOREIGN KEY REFERENCES Organizations(ID),
+        Role NVARCHAR(20) NOT NULL,
+        Username NVARCHAR(50) NULL,
+        Email NVARCHAR(255) NULL,
+        TokenHash NVARCHAR(64) NOT NULL UNIQUE,
+        InvitedBy INT NOT NULL FOREIGN KEY REFERENCES Users(ID),
+        ExpiresAt DATETIME NOT NULL,
+        AcceptedAt DATETIME NULL,
+        AcceptedBy INT NULL FOREIGN KEY REFERENCES Users(ID),
+        RevokedAt DATETIME NULL,
+        CreatedAt DATETIME DEFAULT GETUTCDATE()
+    );
+
+    CREATE INDEX IX_OrgInvites_OrgID ON OrgInvites(OrgID);
+END
+GO
+
+-- Create ExternalIdentities table (OpenID Connect accounts linked to local users)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='ExternalIdentities' and xtype='U')
+BEGIN
+    CREATE TABLE ExternalIdentities (
+        ID INT IDENTITY(1,1) PRIMARY KEY,
+        UserID INT NOT NULL FOREIGN KEY REFERENCES Users(ID),
+        Provider NVARCHAR(50) NOT NULL,
+        Subject NVARCHAR(255) NOT NULL,
+        Email NVARCHAR(255) NULL,
+        CreatedAt DATETIME DEFAULT GETUTCDATE(),
+        LastLoginAt DATETIME NULL,
+        CONSTRAINT UX_ExternalIdentities_Subject UNIQUE (Provider, Subject)
+    );
+
+    CREATE INDEX IX_ExternalIdentities_UserID ON ExternalIdentities(UserID);
+END
+GO
+
+-- Create OIDCStates table (pending sign-ins: state, nonce and PKCE verifier until the callback)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='OIDCStates' and xtype='U')
+BEGIN
+    CREATE TABLE OIDCStates (
+        StateHash NVARCHAR(64) PRIMARY KEY,
+        Provider NVARCHAR(50) NOT NULL,
+        Nonce NVARCHAR(64) NOT NULL,
+        CodeVerifier NVARCHAR(128) NOT NULL,
+        UserID INT NULL FOREIGN KEY REFERENCES Users(ID),
+        ExpiresAt DATETIME NOT NULL
+    );
+END
+GO
+
+-- Create ScimUsers table (accounts provisioned by a SCIM tenant, which may then manage them)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='ScimUsers' and xtype='U')
+BEGIN
+    CREATE TABLE ScimUsers (
+        Tenant NVARCHAR(50) NOT NULL,
+        UserID INT NOT NULL FOREIGN KEY REFERENCES Users(ID),
+        ExternalID NVARCHAR(255) NULL,
+        CreatedAt DATETIME DEFAULT GETUTCDATE(),
+        PRIMARY KEY (Tenant, UserID)
+    );
+
+    CREATE INDEX IX_ScimUsers_UserID ON ScimUsers(UserID);
+END
+GO
+
+-- Create ScimGroups and ScimGroupMembers tables (groups pushed by a SCIM tenant, optionally mapped to roles)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='ScimGroups' and xtype='U')
+BEGIN
+    CREATE TABLE ScimGroups (
+        ID INT IDENTITY(1,1) PRIMARY KEY,
+        Tenant NVARCHAR(50) NOT NULL,
+        DisplayName NVARCHAR(255) NOT NULL,
+        ExternalID NVARCHAR(255) NULL,
+        CreatedAt DATETIME DEFAULT GETUTCDATE(),
+        UpdatedAt DATETIME DEFAULT GETUTCDATE(),
+        CONSTRAINT UX_ScimGroups_Name UNIQUE (Tenant, DisplayName)
+    );
+END
+GO
+
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='ScimGroupMembers' and xtype='U')
+BEGIN
+    CREATE TABLE ScimGroupMembers (
+        GroupID INT NOT NULL FOREIGN KEY REFERENCES ScimGroups(ID),
+        UserID INT NOT NULL FOREIGN KEY REFERENCES Users(ID),
+        PRIMARY KEY (GroupID, UserID)
+    );
+
+    CREATE INDEX IX_ScimGroupMembers_UserID ON ScimGroupMembers(UserID);
+END
+GO
+
+-- Create SecurityEvents table (append-only log of logins and account changes).
+-- UserID has no foreign key so the history outlives the account.
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='SecurityEvents' and xtype='U')
+BEGIN
+    CREATE TABLE SecurityEvents (
+        ID BIGINT IDENTITY(1,1) PRIMARY KEY,
+        EventType NVARCHAR(50) NOT NULL,
+        Outcome NVARCHAR(20) NOT NULL,
+        UserID INT NULL,
+        Username NVARCHAR(50) NULL,
+        ActorID INT NULL,
+        IPAddress NVARCHAR(45) NULL,
+        UserAgent NVARCHAR(512) NULL,
+        Detail NVARCHAR(255) NULL,
+        CreatedAt DATETIME NOT NULL DEFAULT GETUTCDATE()
+    );
+
+    CREATE INDEX IX_SecurityEvents_UserID ON SecurityEvents(UserID, ID);
+    CREATE INDEX IX_SecurityEvents_CreatedAt ON SecurityEvents(CreatedAt);
+    CREATE INDEX IX_SecurityEvents_IPAddress ON SecurityEvents(IPAddress);
+END
+GO
+
+IF NOT EXISTS (SELECT * FROM sys.triggers WHERE name = 'TR_SecurityEvents_AppendOnly')
+    EXEC('CREATE TRIGGER TR_SecurityEvents_AppendOnly ON SecurityEvents INSTEAD OF UPDATE, DELETE AS
+        THROW 50000, ''SecurityEvents is append-only'', 1;');
+GO
+
+-- Add DisplayName to Users (free-form name shown instead of the username)
+IF NOT EXISTS (SELECT * FROM sys.columns WHERE object_id = OBJECT_ID('Users') AND name = 'DisplayName')
+BEGIN
+    ALTER TABLE Users ADD DisplayName NVARCHAR(100) NULL;
+END
+GO
+
+-- Add account deletion columns to Users. DeletionScheduledAt is set during the
+-- grace period; DeletedAt marks the anonymized row left once it has passed.
+IF NOT EXISTS (SELECT * FROM sys.columns WHERE object_id = OBJECT_ID('Users') AND name = 'DeletionScheduledAt')
+BEGIN
+    ALTER TABLE Users ADD DeletionScheduledAt DATETIME NULL;
+    ALTER TABLE Users ADD DeletedAt DATETIME NULL;
+END
+GO
+
+IF NOT EXISTS (SELECT * FROM sys.indexes WHERE name = 'IX_Users_DeletionScheduledAt')
+BEGIN
+    CREATE INDEX IX_Users_DeletionScheduledAt ON Users(DeletionScheduledAt) WHERE DeletionScheduledAt IS NOT NULL;
+END
+GO
+
+-- Create UserEvents table (outbox of account lifecycle events such as user.deleted;
+-- other services read it in ID order and keep their own position)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='UserEvents' and xtype='U')
+BEGIN
+    CREATE TABLE UserEvents (
+        ID BIGINT IDENTITY(1,1) PRIMARY KEY,
+        EventType NVARCHAR(50) NOT NULL,
+        UserID INT NOT NULL,
+        CreatedAt DATETIME NOT NULL DEFAULT GETUTCDATE()
+    );
+END
+GO
+
+-- Create DataExports table (personal data export jobs; link-management adds its
+-- part in LinkExports and the finished ZIP is kept here until ExpiresAt)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='DataExports' and xtype='U')
+BEGIN
+    CREATE TABLE DataExports (
+        ID NVARCHAR(64) PRIMARY KEY,
+        UserID INT NOT NULL FOREIGN KEY REFERENCES Users(ID),
+        Status NVARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, ready or failed
+        Error NVARCHAR(255) NULL,
+        Archive VARBINARY(MAX) NULL,
+        CreatedAt DATETIME NOT NULL DEFAULT GETUTCDATE(),
+        CompletedAt DATETIME NULL,
+        ExpiresAt DATETIME NULL
+    );
+
+    CREATE INDEX IX_DataExports_UserID ON DataExports(UserID);
+    CREATE INDEX IX_DataExports_Status ON DataExports(Status);
+END
+GO
+
+-- Create RegistrationSettings table (a single row; once an admin changes who may
+-- sign up it overrides REGISTRATION_MODE and REGISTRATION_ALLOWED_DOMAINS)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='RegistrationSettings' and xtype='U')
+BEGIN
+    CREATE TABLE RegistrationSettings (
+        ID INT PRIMARY KEY CHECK (ID = 1),
+        Mode NVARCHAR(20) NOT NULL CHECK (Mode IN ('open', 'invite', 'closed', 'domain')),
+        AllowedDomains NVARCHAR(MAX) NOT NULL DEFAULT '', -- Comma separated
+        UpdatedBy INT NULL FOREIGN KEY REFERENCES Users(ID),
+        UpdatedAt DATETIME NOT NULL DEFAULT GETUTCDATE()
+    );
+END
+GO
+
+-- Create InviteCodes table (sign-up codes with a use limit and expiry; only SHA-256 hashes are stored)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='InviteCodes' and xtype='U')
+BEGIN
+    CREATE TABLE InviteCodes (
+        ID INT IDENTITY(1,1) PRIMARY KEY,
+        CodeHash NVARCHAR(64) NOT NULL UNIQUE,
+        Note NVARCHAR(255) NULL,
+        MaxUses INT NOT NULL,
+        Uses INT NOT NULL DEFAULT 0,
+        CreatedBy INT NOT NULL FOREIGN KEY REFERENCES Users(ID),
+        ExpiresAt DATETIME NOT NULL,
+        RevokedAt DATETIME NULL,
+        CreatedAt DATETIME DEFAULT GETUTCDATE()
+    );
+END
+GO
+
+-- Sessions an admin opened to see the service as a user record who did so
+IF NOT EXISTS (SELECT * FROM sys.columns WHERE object_id = OBJECT_ID('Sessions') AND name = 'ImpersonatorID')
+BEGIN
+    ALTER TABLE Sessions ADD ImpersonatorID INT NULL FOREIGN KEY REFERENCES Users(ID);
+END
+GO
+
+-- Accounts created by the LDAP backend remember the stable ID of their directory
+-- entry, so a directory login never lands in an account someone else registered
+IF NOT EXISTS (SELECT * FROM sys.columns WHERE object_id = OBJECT_ID('Users') AND name = 'DirectoryID')
+BEGIN
+    ALTER TABLE Users ADD DirectoryID NVARCHAR(255) NULL;
+END
+GO
+
+IF NOT EXISTS (SELECT * FROM sys.indexes WHERE name = 'UX_Users_DirectoryID')
+BEGIN
+    CREATE UNIQUE INDEX UX_Users_DirectoryID ON Users(DirectoryID) WHERE DirectoryID IS NOT NULL;
+END
+GO
+
+-- Accounts remember how they were created, so the LDAP backend only adopts
+-- accounts it provisioned and never one created by SSO or SCIM. Existing
+-- passwordless accounts not linked to an identity provider or SCIM tenant
+-- were created by the directory.
+IF NOT EXISTS (SELECT * FROM sys.columns WHERE object_id = OBJECT_ID('Users') AND name = 'Source')
+BEGIN
+    ALTER TABLE Users ADD Source NVARCHAR(20) NOT NULL CONSTRAINT DF_Users_Source DEFAULT 'local';
+    EXEC('UPDATE Users SET Source = CASE
+            WHEN DirectoryID IS NOT NULL THEN ''directory''
+            WHEN EXISTS (SELECT 1 FROM ScimUsers s WHERE s.UserID = Users.ID) THEN ''scim''
+            WHEN EXISTS (SELECT 1 FROM ExternalIdentities e WHERE e.UserID = Users.ID) THEN ''oidc''
+            WHEN PasswordHash = '''' AND DeletedAt IS NULL THEN ''directory''
+            ELSE ''local''
+        END');
+END
+GO
+
+-- Accounts let in by their email domain stay inactive until that address is verified
+IF NOT EXISTS (SELECT * FROM sys.columns WHERE object_id = OBJECT_ID('Users') AND name = 'AdmittedByDomain')
+BEGIN
+    ALTER TABLE Users ADD AdmittedByDomain BIT NOT NULL DEFAULT 0;
+END
+GO
+
+-- TOTP secrets are sealed with auth-service's key encryption key, which makes
+-- them longer than the plaintext secret the column was sized for
+IF EXISTS (SELECT * FROM sys.columns WHERE object_id = OBJECT_ID('Users') AND name = 'TOTPSecret' AND max_length < 510)
+BEGIN
+    ALTER TABLE Users ALTER COLUMN TOTPSecret NVARCHAR(255) NULL;
+END
+GO
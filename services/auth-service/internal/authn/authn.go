@@ -0,0 +1,70 @@
+// Package authn checks usernames and passwords. AuthService delegates to an
+// Authenticator so credentials can live in the Users table or in a directory.
+package authn
+
+import (
+	"errors"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+// ErrInvalidCredentials means the username or password is wrong. Any other
+// error means the backend could not decide (e.g. the directory is down).
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Identity describes the person who just authenticated.
+type Identity struct {
+	Username string
+	Email    string // Trusted as verified when set
+	Role     string // Role to assign, or "" to leave the local role alone
+	// DirectoryID is the stable ID of the directory entry; the account must
+	// belong to it, or be created for it
+	DirectoryID string
+	Rehash      bool // The local password hash is outdated and should be replaced
+}
+
+type Authenticator interface {
+	// Authenticate checks a password. user is the matching local account, or
+	// nil if none exists yet; backends that can vouch for unknown users let
+	// AuthService provision them on first login.
+	Authenticate(user *models.User, username, password string) (*Identity, error)
+}
+
+// Directory is implemented by backends that own usernames. Local accounts
+// cannot take a name the directory has, or a later directory login for it
+// would find the wrong account.
+type Directory interface {
+	HasUser(username string) (bool, error)
+}
+
+// Chain tries each authenticator in turn, so a directory can be backed by
+// local accounts (for example a break-glass admin).
+type Chain []Authenticator
+
+func (c Chain) Authenticate(user *models.User, username, password string) (*Identity, error) {
+	err := ErrInvalidCredentials
+	for _, a := range c {
+		identity, aerr := a.Authenticate(user, username, password)
+		if aerr == nil {
+			return identity, nil
+		}
+		// A definite "wrong password" does not hide an earlier backend failure
+		if !errors.Is(aerr, ErrInvalidCredentials) || err == ErrInvalidCredentials {
+			err = aerr
+		}
+	}
+	return nil, err
+}
+
+// HasUser asks every directory in the chain.
+func (c Chain) HasUser(username string) (bool, error) {
+	for _, a := range c {
+		if d, ok := a.(Directory); ok {
+			found, err := d.HasUser(username)
+			if err != nil || found {
+				return found, err
+			}
+		}
+	}
+	return false, nil
+}
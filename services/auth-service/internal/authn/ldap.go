@@ -0,0 +1,196 @@
+package authn
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+// ldapTimeout bounds connecting to the directory and each request.
+const ldapTimeout = 10 * time.Second
+
+// GroupRole maps members of a directory group to a role.
+type GroupRole struct {
+	GroupDN string
+	Role    string
+}
+
+type LDAPConfig struct {
+	URL          string // ldap://host:389 or ldaps://host:636
+	StartTLS     bool
+	BindDN       string // Service account used to look users up
+	BindPassword string
+	BaseDN       string
+	UserFilter   string // %s is replaced by the escaped username, e.g. (&(objectClass=user)(sAMAccountName=%s))
+	IDAttr       string // Attribute that never changes for an entry, entryUUID or objectGUID on Active Directory
+	EmailAttr    string
+	GroupAttr    string      // Attribute listing the user's group DNs, memberOf on Active Directory
+	GroupRoles   []GroupRole // First matching group wins
+	DefaultRole  string      // Role for users in no mapped group; "" refuses them
+}
+
+// LDAP binds as the service account, finds the user, then binds as the user
+// to check the password. Roles come from group membership on every login.
+// The entry must own the local account: one created for it, never one
+// that merely has the same username.
+type LDAP struct {
+	Config LDAPConfig
+}
+
+func (l *LDAP) Authenticate(user *models.User, username, password string) (*Identity, error) {
+	// An empty password would be an unauthenticated bind, which most servers accept
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := l.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entries, err := l.search(conn, username)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	entry := entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap user bind failed: %w", err)
+	}
+
+	directoryID := entryID(entry, l.Config.IDAttr)
+	if directoryID == "" {
+		return nil, fmt.Errorf("ldap entry %s has no %s", entry.DN, l.Config.IDAttr)
+	}
+	if user != nil && !owns(user, directoryID) {
+		log.Printf("Refusing directory login of %s into local account %d it does not own", entry.DN, user.ID)
+		return nil, ErrInvalidCredentials
+	}
+
+	role := l.roleFor(entry.GetAttributeValues(l.Config.GroupAttr))
+	if role == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Keep the local spelling of the username once the account exists
+	name := username
+	if user != nil {
+		name = user.Username
+	}
+	return &Identity{Username: name, Email: entry.GetAttributeValue(l.Config.EmailAttr), Role: role, DirectoryID: directoryID}, nil
+}
+
+// HasUser reports whether the directory has an entry for username.
+func (l *LDAP) HasUser(username string) (bool, error) {
+	conn, err := l.dial()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	entries, err := l.search(conn, username)
+	if err != nil {
+		return false, err
+	}
+	return len(entries) > 0, nil
+}
+
+// search binds as the service account and looks the username up.
+func (l *LDAP) search(conn *ldap.Conn, username string) ([]*ldap.Entry, error) {
+	if err := conn.Bind(l.Config.BindDN, l.Config.BindPassword); err != nil {
+		return nil, fmt.Errorf("ldap service bind failed: %w", err)
+	}
+	search := ldap.NewSearchRequest(
+		l.Config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 10, false,
+		fmt.Sprintf(l.Config.UserFilter, ldap.EscapeFilter(username)),
+		[]string{"dn", l.Config.IDAttr, l.Config.EmailAttr, l.Config.GroupAttr},
+		nil,
+	)
+	res, err := conn.Search(search)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("ldap user search failed: %w", err)
+	}
+	if res == nil {
+		return nil, nil
+	}
+	return res.Entries, nil
+}
+
+// owns reports whether the directory entry may sign in to user. Accounts the
+// directory created before IDs were stored are adopted; accounts registered
+// locally or provisioned by SSO or SCIM belong to someone else, with or
+// without a password.
+func owns(user *models.User, directoryID string) bool {
+	if user.FromDirectory() {
+		return user.DirectoryID == directoryID
+	}
+	return user.Source == models.SourceDirectory
+}
+
+// entryID reads the ID attribute. Binary IDs such as objectGUID are hex encoded.
+func entryID(entry *ldap.Entry, attr string) string {
+	raw := entry.GetRawAttributeValue(attr)
+	if utf8.Valid(raw) {
+		return string(raw)
+	}
+	return hex.EncodeToString(raw)
+}
+
+func (l *LDAP) roleFor(groups []string) string {
+	for _, mapping := range l.Config.GroupRoles {
+		for _, g := range groups {
+			if strings.EqualFold(normalizeDN(g), normalizeDN(mapping.GroupDN)) {
+				return mapping.Role
+			}
+		}
+	}
+	return l.Config.DefaultRole
+}
+
+func (l *LDAP) dial() (*ldap.Conn, error) {
+	if l.Config.URL == "" {
+		return nil, errors.New("ldap url is not configured")
+	}
+	conn, err := ldap.DialURL(l.Config.URL, ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}))
+	if err != nil {
+		return nil, fmt.Errorf("ldap connect failed: %w", err)
+	}
+	conn.SetTimeout(ldapTimeout)
+	if l.Config.StartTLS {
+		host := strings.TrimPrefix(strings.TrimPrefix(l.Config.URL, "ldap://"), "ldaps://")
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap starttls failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// normalizeDN drops the spaces directories like to put after commas.
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, p := range parts {
+		parts[i] = strings.TrimSpace(p)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,27 @@
+package authn
+
+import (
+	"testing"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+func TestOwns(t *testing.T) {
+	tests := []struct {
+		name string
+		user models.User
+		want bool
+	}{
+		{"own entry", models.User{Source: models.SourceDirectory, DirectoryID: "entry-1"}, true},
+		{"other entry", models.User{Source: models.SourceDirectory, DirectoryID: "entry-2"}, false},
+		{"directory account from before ids", models.User{Source: models.SourceDirectory}, true},
+		{"local account", models.User{Source: models.SourceLocal, PasswordHash: "hash"}, false},
+		{"sso account without password", models.User{Source: models.SourceOIDC}, false},
+		{"scim account without password", models.User{Source: models.SourceSCIM}, false},
+	}
+	for _, tt := range tests {
+		if got := owns(&tt.user, "entry-1"); got != tt.want {
+			t.Errorf("%s: owns = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
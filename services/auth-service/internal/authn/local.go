@@ -0,0 +1,30 @@
+package authn
+
+import (
+	"log"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/password"
+)
+
+// Local checks the password hash stored in Users.PasswordHash.
+type Local struct {
+	Passwords *password.Hasher
+}
+
+func (l Local) Authenticate(user *models.User, username, pw string) (*Identity, error) {
+	// Accounts created through SSO or a directory have no local password, and
+	// directory accounts must not sign in with one they were given later
+	if user == nil || user.PasswordHash == "" || user.FromDirectory() {
+		return nil, ErrInvalidCredentials
+	}
+	ok, err := l.Passwords.Verify(user.PasswordHash, pw)
+	if err != nil {
+		log.Printf("Unreadable password hash for user %d: %v", user.ID, err)
+		return nil, ErrInvalidCredentials
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return &Identity{Username: user.Username, Rehash: l.Passwords.NeedsRehash(user.PasswordHash)}, nil
+}
@@ -1,27 +1,272 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/authn"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/lockout"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/oidc"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/password"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/scim"
 )
 
 type Config struct {
-	Port         string
-	DBHost       string
-	DBName       string
-	DBUser       string
-	DBPassword   string
-	JWTSecret    string
+	Port                 string
+	DBHost               string
+	DBName               string
+	DBUser               string
+	DBPassword           string
+	JWTPrivateKey        string // PEM encoded Ed25519 key, imported into the keyring on startup
+	JWTPrivateKeyFile    string
+	KeyEncryptionKey     string   // Base64 AES-256 key sealing signing keys and TOTP secrets in the database
+	KeyEncryptionKeyFile string   // Alternative to KeyEncryptionKey
+	PreviousKEKs         []string // Older key encryption keys; values sealed with them are resealed on read
+	KeyRotationInterval  time.Duration
+	KeyPrepublishPeriod  time.Duration // How long a new key is published before it starts signing
+	AccessTokenTTL       time.Duration
+	RefreshTokenTTL      time.Duration
+	AppBaseURL           string // Frontend URL used in links sent to users
+	PasswordResetTTL     time.Duration
+	EmailVerificationTTL time.Duration
+	TOTPIssuer           string // Shown in authenticator apps
+	OrgInviteTTL         time.Duration
+	Notifier             string // "file" or "smtp"
+	NotifyOutbox         string
+	SMTPHost             string
+	SMTPPort             string
+	SMTPUser             string
+	SMTPPassword         string
+	SMTPFrom             string
+	LockoutStore         string // "sql" (shared by all replicas) or "memory"
+	TrustedProxies       string // Comma separated; client IPs for lockouts come from X-Forwarded-For set by these
+	AccountLockout       lockout.Policy
+	IPLockout            lockout.Policy
+	OIDCProviders        []oidc.Config
+	AuthBackends         []string // Password checkers tried in order: "local" and/or "ldap"
+	LDAP                 authn.LDAPConfig
+	ScimTenants          []scim.Tenant
+	ReservedUsernames    []string // Lower case; nobody can register or rename to these
+	SecurityEventSink    string   // "none", "log" or "file"; events are always stored in the database
+	SecurityEventFile    string
+	AccountDeletionGrace time.Duration // Time to change one's mind before an account is deleted
+	DataExportTTL        time.Duration // How long a personal data export can be downloaded
+	DataExportTimeout    time.Duration // How long an export may stay pending before failing
+	PasswordHash         password.Params
+	PasswordPolicy       password.Policy // Breached is opened from PasswordBreachedFile at startup
+	PasswordBreachedFile string          // Sorted SHA-1 hash file, see password.BreachedList; "" skips the check
+	RegistrationMode     string          // open, invite, closed or domain; admins can change it at runtime
+	RegistrationDomains  []string        // Lower case; email domains allowed to sign up in domain mode
+	InviteCodeTTL        time.Duration
+	ImpersonationTTL     time.Duration // Lifetime of an admin's session as another user; it cannot be refreshed
+	ServiceClients       []ServiceClient
+	IntrospectionCache   time.Duration // Longest time callers may cache a token introspection
+}
+
+// ServiceClient is another service allowed to call internal endpoints such
+// as token introspection, authenticating with HTTP Basic.
+type ServiceClient struct {
+	ID     string
+	Secret string
 }
 
 func LoadConfig() *Config {
-	return &Config{
-		Port:       getEnv("PORT", "8080"),
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBName:     getEnv("DB_NAME", "UrlShortenerDb"),
-		DBUser:     getEnv("DB_USER", "sa"),
-		DBPassword: getEnv("DB_PASSWORD", "yourStrong(!)Password"),
-		JWTSecret:  getEnv("JWT_SECRET", "super-secret-key"),
+	cfg := &Config{
+		Port:                 getEnv("PORT", "8080"),
+		DBHost:               getEnv("DB_HOST", "localhost"),
+		DBName:               getEnv("DB_NAME", "UrlShortenerDb"),
+		DBUser:               getEnv("DB_USER", "sa"),
+		DBPassword:           getEnv("DB_PASSWORD", "yourStrong(!)Password"),
+		JWTPrivateKey:        getEnv("JWT_PRIVATE_KEY", ""),
+		JWTPrivateKeyFile:    getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		KeyEncryptionKey:     getEnv("KEY_ENCRYPTION_KEY", ""),
+		KeyEncryptionKeyFile: getEnv("KEY_ENCRYPTION_KEY_FILE", ""),
+		KeyRotationInterval:  getDurationEnv("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour),
+		KeyPrepublishPeriod:  getDurationEnv("JWT_KEY_PREPUBLISH_PERIOD", 15*time.Minute),
+		AccessTokenTTL:       getDurationEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:      getDurationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		AppBaseURL:           getEnv("APP_BASE_URL", "http://localhost:5173"),
+		PasswordResetTTL:     getDurationEnv("PASSWORD_RESET_TTL", time.Hour),
+		EmailVerificationTTL: getDurationEnv("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+		TOTPIssuer:           getEnv("TOTP_ISSUER", "URL Shortener"),
+		OrgInviteTTL:         getDurationEnv("ORG_INVITE_TTL", 7*24*time.Hour),
+		Notifier:             getEnv("NOTIFIER", "file"),
+		NotifyOutbox:         getEnv("NOTIFY_OUTBOX", "outbox.log"),
+		SMTPHost:             getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:             getEnv("SMTP_PORT", "25"),
+		SMTPUser:             getEnv("SMTP_USER", ""),
+		SMTPPassword:         getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:             getEnv("SMTP_FROM", "no-reply@localhost"),
+		LockoutStore:         getEnv("LOGIN_LOCKOUT_STORE", "sql"),
+		TrustedProxies:       getEnv("TRUSTED_PROXIES", ""),
+		SecurityEventSink:    getEnv("SECURITY_EVENT_SINK", "none"),
+		SecurityEventFile:    getEnv("SECURITY_EVENT_FILE", "security-events.log"),
+		AccountDeletionGrace: getDurationEnv("ACCOUNT_DELETION_GRACE", 30*24*time.Hour),
+		DataExportTTL:        getDurationEnv("DATA_EXPORT_TTL", 7*24*time.Hour),
+		DataExportTimeout:    getDurationEnv("DATA_EXPORT_TIMEOUT", time.Hour),
+		AccountLockout: lockout.Policy{
+			Threshold:   getIntEnv("LOGIN_ACCOUNT_MAX_FAILURES", 5),
+			BaseLockout: getDurationEnv("LOGIN_LOCKOUT_BASE", 30*time.Second),
+			MaxLockout:  getDurationEnv("LOGIN_LOCKOUT_MAX", time.Hour),
+			Window:      getDurationEnv("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		},
+		// OWASP's minimum argon2id settings; raising them upgrades hashes as users log in
+		PasswordHash: password.Params{
+			Algorithm:   getEnv("PASSWORD_HASH_ALGORITHM", password.Argon2id),
+			Memory:      uint32(getIntEnv("PASSWORD_ARGON2_MEMORY_KIB", 19456)),
+			Iterations:  uint32(getIntEnv("PASSWORD_ARGON2_ITERATIONS", 2)),
+			Parallelism: uint8(getIntEnv("PASSWORD_ARGON2_PARALLELISM", 1)),
+			BcryptCost:  getIntEnv("PASSWORD_BCRYPT_COST", 10),
+		},
+		PasswordPolicy: password.Policy{
+			MinLength:      getIntEnv("PASSWORD_MIN_LENGTH", 8),
+			MaxLength:      getIntEnv("PASSWORD_MAX_LENGTH", 128),
+			MinScore:       getIntEnv("PASSWORD_MIN_SCORE", 2),
+			RejectUsername: getEnv("PASSWORD_REJECT_USERNAME", "true") == "true",
+		},
+		PasswordBreachedFile: getEnv("PASSWORD_BREACHED_FILE", ""),
+		RegistrationMode:     getEnv("REGISTRATION_MODE", "open"),
+		InviteCodeTTL:        getDurationEnv("INVITE_CODE_TTL", 7*24*time.Hour),
+		ImpersonationTTL:     getDurationEnv("IMPERSONATION_TTL", 15*time.Minute),
+		IntrospectionCache:   getDurationEnv("INTROSPECTION_CACHE_TTL", 30*time.Second),
+		// Higher threshold since many users can share an IP behind NAT
+		IPLockout: lockout.Policy{
+			Threshold:   getIntEnv("LOGIN_IP_MAX_FAILURES", 20),
+			BaseLockout: getDurationEnv("LOGIN_LOCKOUT_BASE", 30*time.Second),
+			MaxLockout:  getDurationEnv("LOGIN_LOCKOUT_MAX", time.Hour),
+			Window:      getDurationEnv("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		},
+	}
+	cfg.OIDCProviders = loadOIDCProviders(cfg.AppBaseURL)
+	cfg.PreviousKEKs = strings.Split(strings.ReplaceAll(getEnv("KEY_ENCRYPTION_KEYS_PREVIOUS", ""), " ", ""), ",")
+	cfg.AuthBackends = strings.Split(strings.ReplaceAll(getEnv("AUTH_BACKEND", "local"), " ", ""), ",")
+	cfg.LDAP = loadLDAPConfig()
+	cfg.ScimTenants = loadScimTenants()
+	cfg.ServiceClients = loadServiceClients()
+	cfg.RegistrationDomains = []string{}
+	if domains := strings.ToLower(strings.ReplaceAll(getEnv("REGISTRATION_ALLOWED_DOMAINS", ""), " ", "")); domains != "" {
+		cfg.RegistrationDomains = strings.Split(domains, ",")
 	}
+	cfg.ReservedUsernames = strings.Split(strings.ToLower(strings.ReplaceAll(getEnv("RESERVED_USERNAMES",
+		"admin,administrator,root,system,support,help,security,abuse,postmaster,webmaster,noreply,no-reply,api,auth,me,www,guest,anonymous,null,undefined"),
+		" ", "")), ",")
+	return cfg
+}
+
+// loadLDAPConfig reads the LDAP_* settings used when AUTH_BACKEND includes
+// "ldap". LDAP_GROUP_ROLES maps groups to roles, e.g.
+// "Admin:cn=admins,ou=groups,dc=example,dc=com;User:cn=staff,ou=groups,dc=example,dc=com".
+func loadLDAPConfig() authn.LDAPConfig {
+	cfg := authn.LDAPConfig{
+		URL:          getEnv("LDAP_URL", ""),
+		StartTLS:     getEnv("LDAP_START_TLS", "false") == "true",
+		BindDN:       getEnv("LDAP_BIND_DN", ""),
+		BindPassword: getEnv("LDAP_BIND_PASSWORD", ""),
+		BaseDN:       getEnv("LDAP_BASE_DN", ""),
+		UserFilter:   getEnv("LDAP_USER_FILTER", "(&(objectClass=person)(uid=%s))"),
+		IDAttr:       getEnv("LDAP_ID_ATTR", "entryUUID"),
+		EmailAttr:    getEnv("LDAP_EMAIL_ATTR", "mail"),
+		GroupAttr:    getEnv("LDAP_GROUP_ATTR", "memberOf"),
+		DefaultRole:  getEnv("LDAP_DEFAULT_ROLE", ""),
+	}
+	for _, mapping := range strings.Split(getEnv("LDAP_GROUP_ROLES", ""), ";") {
+		role, group, ok := strings.Cut(strings.TrimSpace(mapping), ":")
+		if !ok || role == "" || group == "" {
+			if mapping != "" {
+				log.Printf("Ignoring LDAP_GROUP_ROLES entry %q: expected Role:groupDN", mapping)
+			}
+			continue
+		}
+		cfg.GroupRoles = append(cfg.GroupRoles, authn.GroupRole{GroupDN: strings.TrimSpace(group), Role: strings.TrimSpace(role)})
+	}
+	return cfg
+}
+
+// loadOIDCProviders reads OIDC_PROVIDERS (e.g. "mock,okta") and, for each
+// name, OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL, _SCOPES
+// and _TRUSTED_SIGNUP.
+func loadOIDCProviders(appBaseURL string) []oidc.Config {
+	var providers []oidc.Config
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		p := oidc.Config{
+			Name:          name,
+			Issuer:        getEnv(prefix+"ISSUER", ""),
+			ClientID:      getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret:  getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:   getEnv(prefix+"REDIRECT_URL", appBaseURL+"/oidc/callback/"+name),
+			Scopes:        strings.Fields(getEnv(prefix+"SCOPES", "")),
+			TrustedSignup: getEnv(prefix+"TRUSTED_SIGNUP", "false") == "true",
+		}
+		if p.Issuer == "" || p.ClientID == "" {
+			log.Printf("Skipping OIDC provider %s: %sISSUER and %sCLIENT_ID are required", name, prefix, prefix)
+			continue
+		}
+		providers = append(providers, p)
+	}
+	return providers
+}
+
+// loadServiceClients reads SERVICE_CLIENTS, e.g. "redirect-service,analytics",
+// and each client's SERVICE_<ID>_SECRET.
+func loadServiceClients() []ServiceClient {
+	var clients []ServiceClient
+	for _, id := range strings.Split(getEnv("SERVICE_CLIENTS", ""), ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		key := "SERVICE_" + strings.ToUpper(strings.ReplaceAll(id, "-", "_")) + "_SECRET"
+		secret := getEnv(key, "")
+		if len(secret) < 32 {
+			log.Printf("Skipping service client %s: %s must be at least 32 characters", id, key)
+			continue
+		}
+		clients = append(clients, ServiceClient{ID: id, Secret: secret})
+	}
+	return clients
+}
+
+// loadScimTenants reads SCIM_TENANTS (e.g. "corp") and, for each name,
+// SCIM_<NAME>_TOKEN, _DEFAULT_ROLE and _GROUP_ROLES ("Admin:Shortener Admins;User:Staff").
+func loadScimTenants() []scim.Tenant {
+	var tenants []scim.Tenant
+	for _, name := range strings.Split(getEnv("SCIM_TENANTS", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "SCIM_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		t := scim.Tenant{
+			Name:        name,
+			Token:       getEnv(prefix+"TOKEN", ""),
+			DefaultRole: getEnv(prefix+"DEFAULT_ROLE", "User"),
+		}
+		// Short tokens could be guessed; generate one with e.g. `openssl rand -base64 32`
+		if len(t.Token) < 32 {
+			log.Printf("Skipping SCIM tenant %s: %sTOKEN must be at least 32 characters", name, prefix)
+			continue
+		}
+		for _, mapping := range strings.Split(getEnv(prefix+"GROUP_ROLES", ""), ";") {
+			role, group, ok := strings.Cut(strings.TrimSpace(mapping), ":")
+			if !ok || role == "" || group == "" {
+				if mapping != "" {
+					log.Printf("Ignoring %sGROUP_ROLES entry %q: expected Role:group", prefix, mapping)
+				}
+				continue
+			}
+			t.GroupRoles = append(t.GroupRoles, scim.GroupRole{Group: strings.TrimSpace(group), Role: strings.TrimSpace(role)})
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants
 }
 
 func getEnv(key, fallback string) string {
@@ -30,3 +275,29 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration for %s (%q), using default %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+func getIntEnv(key string, fallback int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid integer for %s (%q), using default %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
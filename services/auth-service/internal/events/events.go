@@ -0,0 +1,67 @@
+// Package events forwards security events to systems outside the database,
+// such as a log shipper or SIEM.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+// Sink receives every security event after it has been stored.
+type Sink interface {
+	Emit(event *models.SecurityEvent) error
+}
+
+// LogSink writes events to the service log as JSON.
+type LogSink struct{}
+
+func (LogSink) Emit(event *models.SecurityEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	log.Printf("security_event %s", b)
+	return nil
+}
+
+// FileSink appends events to a file as JSON lines.
+type FileSink struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (s *FileSink) Emit(event *models.SecurityEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open event file: %w", err)
+	}
+	defer f.Close()
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+// New picks a sink by name ("log" or "file"), or returns nil for "none".
+func New(kind, path string) Sink {
+	switch kind {
+	case "", "none":
+		return nil
+	case "log":
+		return LogSink{}
+	case "file":
+		return &FileSink{Path: path}
+	}
+	log.Printf("Unknown security event sink %q, events are only stored in the database", kind)
+	return nil
+}
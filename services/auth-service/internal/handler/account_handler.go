@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
+)
+
+type AccountHandler struct {
+	Service  *service.AccountService
+	Deletion *service.AccountDeletionService
+	Events   *service.SecurityEventService
+}
+
+func NewAccountHandler(svc *service.AccountService, deletion *service.AccountDeletionService, events *service.SecurityEventService) *AccountHandler {
+	return &AccountHandler{Service: svc, Deletion: deletion, Events: events}
+}
+
+func accountError(c *gin.Context, err error) {
+	if usernameRejected(c, err) {
+		return
+	}
+	switch err.Error() {
+	case "user not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case "username already exists":
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+	case "username managed by directory":
+		c.JSON(http.StatusConflict, gin.H{"error": "Your username is managed by your organization's directory"})
+	case "session not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+	case "account disabled":
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+	case "account managed by directory":
+		c.JSON(http.StatusConflict, gin.H{"error": "Your account is managed by your organization's directory"})
+	case "invalid credentials":
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+	case "reauthentication required":
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign in again to confirm"})
+	case "no deletion pending":
+		c.JSON(http.StatusConflict, gin.H{"error": "No account deletion is pending"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
+
+func (h *AccountHandler) GetMe(c *gin.Context) {
+	user, err := h.Service.GetProfile(c.GetInt("userID"))
+	if err != nil {
+		accountError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AccountHandler) UpdateMe(c *gin.Context) {
+	var req models.UpdateProfileRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	// The username is what the user signs in with
+	if _, ok := c.Get("actorID"); ok && req.Username != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed while impersonating a user"})
+		return
+	}
+
+	oldUser, err := h.Service.GetProfile(c.GetInt("userID"))
+	if err != nil {
+		accountError(c, err)
+		return
+	}
+	user, tokens, err := h.Service.UpdateProfile(oldUser.ID, c.GetString("sessionID"), &req)
+	if err != nil {
+		accountError(c, err)
+		return
+	}
+
+	resp := models.ProfileResponse{User: *user}
+	if tokens != nil {
+		recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventUsernameChange, Outcome: models.OutcomeSuccess, UserID: &user.ID, Detail: "from=" + oldUser.Username})
+		resp.Token, resp.RefreshToken, resp.ExpiresIn = tokens.AccessToken, tokens.RefreshToken, tokens.ExpiresIn
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *AccountHandler) ListSessions(c *gin.Context) {
+	sessions, err := h.Service.ListSessions(c.GetInt("userID"), c.GetString("sessionID"))
+	if err != nil {
+		accountError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+func (h *AccountHandler) RevokeSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	if err := h.Service.RevokeSession(c.GetInt("userID"), sessionID); err != nil {
+		accountError(c, err)
+		return
+	}
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventSessionRevoke, Outcome: models.OutcomeSuccess, UserID: userRef(c.GetInt("userID")), Detail: "session=" + sessionID})
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RequestDeletion schedules the account for deletion after the grace period
+// and signs the user out everywhere.
+func (h *AccountHandler) RequestDeletion(c *gin.Context) {
+	var req models.DeleteAccountRequest
+	// The body is optional for users without a password
+	if c.Request.ContentLength > 0 && !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.Deletion.RequestDeletion(c.GetInt("userID"), c.GetString("sessionID"), req.Password)
+	if err != nil {
+		if err.Error() == "invalid credentials" {
+			recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventDeletionRequest, Outcome: models.OutcomeFailure, UserID: userRef(c.GetInt("userID"))})
+		}
+		accountError(c, err)
+		return
+	}
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventDeletionRequest, Outcome: models.OutcomeSuccess, UserID: &user.ID, Detail: "at=" + user.DeletionScheduledAt.Format(time.RFC3339)})
+	c.JSON(http.StatusAccepted, user)
+}
+
+func (h *AccountHandler) CancelDeletion(c *gin.Context) {
+	user, err := h.Deletion.CancelDeletion(c.GetInt("userID"))
+	if err != nil {
+		accountError(c, err)
+		return
+	}
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventDeletionCancel, Outcome: models.OutcomeSuccess, UserID: &user.ID})
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,328 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
+)
+
+type AdminHandler struct {
+	Keys     *service.KeyService
+	MFA      *service.MFAService
+	Users    *service.UserAdminService
+	Deletion *service.AccountDeletionService
+	Roles    *service.RoleService
+	Events   *service.SecurityEventService
+}
+
+func NewAdminHandler(keys *service.KeyService, mfa *service.MFAService, users *service.UserAdminService, deletion *service.AccountDeletionService, roles *service.RoleService, events *service.SecurityEventService) *AdminHandler {
+	return &AdminHandler{Keys: keys, MFA: mfa, Users: users, Deletion: deletion, Roles: roles, Events: events}
+}
+
+// recordAdminEvent logs a change an admin made to another account.
+func (h *AdminHandler) recordAdminEvent(c *gin.Context, eventType string, userID int, detail string) {
+	recordEvent(c, h.Events, models.SecurityEvent{
+		Type:    eventType,
+		Outcome: models.OutcomeSuccess,
+		UserID:  &userID,
+		ActorID: userRef(c.GetInt("userID")),
+		Detail:  detail,
+	})
+}
+
+func adminUserError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "user not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case "invalid role":
+		c.JSON(http.StatusBadRequest, gin.H{"errors": gin.H{"Role": "Unknown role"}})
+	case "cannot modify own account":
+		c.JSON(http.StatusConflict, gin.H{"error": "Admins cannot change the role or status of their own account"})
+	case "no deletion pending":
+		c.JSON(http.StatusConflict, gin.H{"error": "No account deletion is pending"})
+	case "account disabled":
+		c.JSON(http.StatusConflict, gin.H{"error": "Account is disabled"})
+	case "user has more permissions":
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot manage a user with permissions you do not have"})
+	case "role has more permissions":
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot grant a role with permissions you do not have"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
+
+// userIDParam parses :id and writes a 400 if it is not a number.
+func userIDParam(c *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return 0, false
+	}
+	return userID, true
+}
+
+func (h *AdminHandler) ListKeys(c *gin.Context) {
+	keys, err := h.Keys.ListKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if keys == nil {
+		keys = []models.SigningKeyRecord{}
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *AdminHandler) RotateKey(c *gin.Context) {
+	var req models.RotateKeyRequest
+	// The body is optional; an empty body means a graceful rotation
+	if c.Request.ContentLength > 0 && !bindJSON(c, &req) {
+		return
+	}
+
+	key, err := h.Keys.Rotate(req.Immediate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+func (h *AdminHandler) ResetMFA(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Users.CheckManage(c.GetInt("userID"), userID); err != nil {
+		adminUserError(c, err)
+		return
+	}
+	if err := h.MFA.ResetForUser(userID); err != nil {
+		if err.Error() == "user not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	h.recordAdminEvent(c, models.EventMFAReset, userID, "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	var req models.ListUsersRequest
+	if !bindQuery(c, &req) {
+		return
+	}
+
+	page, err := h.Users.ListUsers(&req)
+	if err != nil {
+		adminUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.Users.GetUser(userID)
+	if err != nil {
+		adminUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AdminHandler) ChangeRole(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	var req models.ChangeRoleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.Users.ChangeRole(c.GetInt("userID"), userID, req.Role)
+	if err != nil {
+		adminUserError(c, err)
+		return
+	}
+	h.recordAdminEvent(c, models.EventRoleChange, userID, "role="+user.Role)
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AdminHandler) DisableUser(c *gin.Context) {
+	h.setDisabled(c, true)
+}
+
+func (h *AdminHandler) EnableUser(c *gin.Context) {
+	h.setDisabled(c, false)
+}
+
+func (h *AdminHandler) setDisabled(c *gin.Context, disabled bool) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.Users.SetDisabled(c.GetInt("userID"), userID, disabled)
+	if err != nil {
+		adminUserError(c, err)
+		return
+	}
+	if disabled {
+		h.recordAdminEvent(c, models.EventAccountDisable, userID, "")
+	} else {
+		h.recordAdminEvent(c, models.EventAccountEnable, userID, "")
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AdminHandler) ForceLogout(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Users.ForceLogout(c.GetInt("userID"), userID); err != nil {
+		adminUserError(c, err)
+		return
+	}
+	h.recordAdminEvent(c, models.EventSessionsRevoke, userID, "")
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions revoked"})
+}
+
+// Impersonate returns an access token for acting as the user. It expires
+// after IMPERSONATION_TTL and cannot be refreshed.
+func (h *AdminHandler) Impersonate(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	var req models.ImpersonateRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	resp, err := h.Users.Impersonate(c.GetInt("userID"), userID, req.Reason)
+	if err != nil {
+		adminUserError(c, err)
+		return
+	}
+	h.recordAdminEvent(c, models.EventImpersonation, userID, "reason="+req.Reason)
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteUser disables the account and deletes it after the grace period.
+// An optional body of {"immediate": true} deletes it now.
+func (h *AdminHandler) DeleteUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	var req models.DeleteUserRequest
+	if c.Request.ContentLength > 0 && !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.Users.CheckManage(c.GetInt("userID"), userID); err != nil {
+		adminUserError(c, err)
+		return
+	}
+	user, err := h.Deletion.DeleteUser(c.GetInt("userID"), userID, req.Immediate)
+	if err != nil {
+		adminUserError(c, err)
+		return
+	}
+	if user.Deleted() {
+		h.recordAdminEvent(c, models.EventAccountDelete, userID, "source=admin")
+		c.JSON(http.StatusOK, user)
+		return
+	}
+	h.recordAdminEvent(c, models.EventDeletionRequest, userID, "at="+user.DeletionScheduledAt.Format(time.RFC3339))
+	c.JSON(http.StatusAccepted, user)
+}
+
+func (h *AdminHandler) CancelDeletion(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Users.CheckManage(c.GetInt("userID"), userID); err != nil {
+		adminUserError(c, err)
+		return
+	}
+	user, err := h.Deletion.CancelDeletion(userID)
+	if err != nil {
+		adminUserError(c, err)
+		return
+	}
+	h.recordAdminEvent(c, models.EventDeletionCancel, userID, "")
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AdminHandler) ListRoles(c *gin.Context) {
+	roles, err := h.Roles.ListRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+func (h *AdminHandler) ListPermissions(c *gin.Context) {
+	perms, err := h.Roles.ListPermissions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, perms)
+}
+
+func (h *AdminHandler) PutRole(c *gin.Context) {
+	var req models.PutRoleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	role, err := h.Roles.PutRole(c.GetString("role"), c.Param("name"), &req)
+	if err != nil {
+		if err.Error() == "cannot remove own role management" {
+			c.JSON(http.StatusConflict, gin.H{"error": "You cannot remove roles:manage from your own role"})
+			return
+		}
+		if err.Error() == "role has more permissions" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot edit a role to or from permissions you do not have"})
+			return
+		}
+		if err.Error() == "no role manager left" {
+			c.JSON(http.StatusConflict, gin.H{"error": "At least one active user must keep the roles:manage permission"})
+			return
+		}
+		if err.Error() == "invalid role name" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Role names must start with a letter and contain at most 20 letters, digits, - or _"})
+			return
+		}
+		if strings.HasPrefix(err.Error(), "unknown permission: ") {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": gin.H{"Permissions": "Unknown permission " + strings.TrimPrefix(err.Error(), "unknown permission: ")}})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, role)
+}
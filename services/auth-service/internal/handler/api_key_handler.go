@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
+)
+
+type ApiKeyHandler struct {
+	Service *service.ApiKeyService
+	Events  *service.SecurityEventService
+}
+
+func NewApiKeyHandler(svc *service.ApiKeyService, events *service.SecurityEventService) *ApiKeyHandler {
+	return &ApiKeyHandler{Service: svc, Events: events}
+}
+
+func (h *ApiKeyHandler) CreateApiKey(c *gin.Context) {
+	var req models.CreateApiKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	resp, err := h.Service.CreateApiKey(c.GetInt("userID"), &req)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid scope") || err.Error() == "at least one scope is required" ||
+			err.Error() == "expires_in_days must not be negative" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventAPIKeyCreate, Outcome: models.OutcomeSuccess, UserID: userRef(c.GetInt("userID")), Detail: "key=" + strconv.Itoa(resp.ApiKey.ID)})
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *ApiKeyHandler) ListApiKeys(c *gin.Context) {
+	keys, err := h.Service.ListApiKeys(c.GetInt("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *ApiKeyHandler) RevokeApiKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid api key id"})
+		return
+	}
+
+	if err := h.Service.RevokeApiKey(id, c.GetInt("userID")); err != nil {
+		if err.Error() == "api key not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventAPIKeyRevoke, Outcome: models.OutcomeSuccess, UserID: userRef(c.GetInt("userID")), Detail: "key=" + strconv.Itoa(id)})
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
@@ -2,20 +2,29 @@ package handler
 
 import (
 	"errors"
+	"log"
+	"math"
 	"net/http"
+	"reflect"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/lockout"
 	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/password"
 	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
 )
 
 type AuthHandler struct {
-	Service *service.AuthService
+	Service      *service.AuthService
+	Registration *service.RegistrationService
+	Email        *service.EmailService
+	Events       *service.SecurityEventService
 }
 
-func NewAuthHandler(svc *service.AuthService) *AuthHandler {
-	return &AuthHandler{Service: svc}
+func NewAuthHandler(svc *service.AuthService, registration *service.RegistrationService, email *service.EmailService, events *service.SecurityEventService) *AuthHandler {
+	return &AuthHandler{Service: svc, Registration: registration, Email: email, Events: events}
 }
 
 func getErrorMsg(fe validator.FieldError) string {
@@ -23,62 +32,226 @@ func getErrorMsg(fe validator.FieldError) string {
 	case "required":
 		return "This field is required"
 	case "min":
+		if fe.Kind() == reflect.Int {
+			return "Must be at least " + fe.Param()
+		}
 		return "Must be at least " + fe.Param() + " characters"
+	case "max":
+		if fe.Kind() == reflect.Int {
+			return "Must be at most " + fe.Param()
+		}
+		return "Must be at most " + fe.Param() + " characters"
 	case "email":
 		return "Invalid email format"
 	}
 	return "Unknown error"
 }
 
+// bindQuery binds query parameters and writes the validation error response on failure.
+func bindQuery(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindQuery(req); err != nil {
+		writeBindError(c, err)
+		return false
+	}
+	return true
+}
+
+// bindJSON binds the request body and writes the validation error response on failure.
+func bindJSON(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		writeBindError(c, err)
+		return false
+	}
+	return true
+}
+
+func writeBindError(c *gin.Context, err error) {
+	var ve validator.ValidationErrors
+	if errors.As(err, &ve) {
+		out := make(map[string]string)
+		for _, fe := range ve {
+			out[fe.Field()] = getErrorMsg(fe)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"errors": out})
+		return
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// usernameRejected writes a 400 if err is a username rule violation.
+func usernameRejected(c *gin.Context, err error) bool {
+	switch err.Error() {
+	case "invalid username":
+		c.JSON(http.StatusBadRequest, gin.H{"errors": gin.H{"Username": "Use 3-50 letters, digits, dots, dashes or underscores"}})
+	case "username reserved":
+		c.JSON(http.StatusBadRequest, gin.H{"errors": gin.H{"Username": "This username is reserved"}})
+	default:
+		return false
+	}
+	return true
+}
+
+// passwordRejected writes a 400 if err is a password policy violation. The
+// field carries the first message for forms with one line per field, and
+// "<field>.<rule>" one entry per broken rule.
+func passwordRejected(c *gin.Context, err error, field string) bool {
+	var policy *password.PolicyError
+	if !errors.As(err, &policy) {
+		return false
+	}
+	out := gin.H{field: policy.Violations[0].Message}
+	for _, v := range policy.Violations {
+		out[field+"."+v.Rule] = v.Message
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"errors": out})
+	return true
+}
+
+// registrationRejected writes a 403 if the registration mode turned the
+// sign-up away.
+func registrationRejected(c *gin.Context, err error) bool {
+	switch err.Error() {
+	case "registration closed":
+		c.JSON(http.StatusForbidden, gin.H{"error": "Registration is closed"})
+	case "invite code required":
+		c.JSON(http.StatusForbidden, gin.H{"errors": gin.H{"InviteCode": "An invite code is required to sign up"}})
+	case "invalid invite code":
+		c.JSON(http.StatusForbidden, gin.H{"errors": gin.H{"InviteCode": "Invalid, expired or used up invite code"}})
+	case "email domain not allowed":
+		c.JSON(http.StatusForbidden, gin.H{"errors": gin.H{"Email": "Sign-up is limited to approved email domains"}})
+	default:
+		return false
+	}
+	return true
+}
+
+// lockedOut writes a 429 with Retry-After if err is a login lockout.
+func lockedOut(c *gin.Context, err error) bool {
+	var locked *lockout.LockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+	seconds := int(math.Ceil(locked.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later", "retry_after": seconds})
+	return true
+}
+
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req models.RegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		var ve validator.ValidationErrors
-		if errors.As(err, &ve) {
-			out := make(map[string]string)
-			for _, fe := range ve {
-				out[fe.Field()] = getErrorMsg(fe)
-			}
-			c.JSON(http.StatusBadRequest, gin.H{"errors": out})
-			return
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	user, err := h.Service.Register(&req)
+	user, err := h.Registration.Register(&req)
 	if err != nil {
+		if registrationRejected(c, err) {
+			return
+		}
 		if err.Error() == "username already exists" {
 			c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
 			return
 		}
+		if err.Error() == "email already in use" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+			return
+		}
+		if usernameRejected(c, err) || passwordRejected(c, err, "Password") {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventRegister, Outcome: models.OutcomeSuccess, UserID: &user.ID})
+
+	// The account exists either way; the user can ask for another link later
+	if user.Email != "" {
+		if err := h.Email.SendVerification(user); err != nil {
+			log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+		}
+	}
+
 	c.JSON(http.StatusCreated, user)
 }
 
+// RegistrationInfo tells the sign-up form which mode is active.
+func (h *AuthHandler) RegistrationInfo(c *gin.Context) {
+	info, err := h.Registration.Info()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		var ve validator.ValidationErrors
-		if errors.As(err, &ve) {
-			out := make(map[string]string)
-			for _, fe := range ve {
-				out[fe.Field()] = getErrorMsg(fe)
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := h.Service.Login(&req, c.ClientIP())
+	if err != nil {
+		if outcome := loginOutcome(err); outcome != "" {
+			recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventLogin, Outcome: outcome, Username: req.Username})
+		}
+		if lockedOut(c, err) {
+			return
+		}
+		if err.Error() == "invalid credentials" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			return
+		}
+		if err.Error() == "account disabled" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+			return
+		}
+		if err.Error() == "email not verified" {
+			// The password was right, so send a fresh link in case the first one got lost
+			if user, err := h.Service.Repo.GetUserByUsername(req.Username); err == nil && user != nil {
+				if err := h.Email.SendVerification(user); err != nil {
+					log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+				}
 			}
-			c.JSON(http.StatusBadRequest, gin.H{"errors": out})
+			c.JSON(http.StatusForbidden, gin.H{"error": "Verify your email address before signing in, we sent you a new link"})
 			return
 		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
-	token, user, err := h.Service.Login(&req)
+	// Second step: POST /api/auth/login/mfa with the challenge token
+	if result.Challenge != nil {
+		recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventLogin, Outcome: models.OutcomePending, UserID: &result.User.ID})
+		c.JSON(http.StatusOK, result.Challenge)
+		return
+	}
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventLogin, Outcome: models.OutcomeSuccess, UserID: &result.User.ID})
+
+	c.JSON(http.StatusOK, models.AuthResponse{
+		Token:        result.Tokens.AccessToken,
+		RefreshToken: result.Tokens.RefreshToken,
+		ExpiresIn:    result.Tokens.ExpiresIn,
+		User:         *result.User,
+	})
+}
+
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tokens, user, err := h.Service.Refresh(&req)
 	if err != nil {
-		if err.Error() == "invalid credentials" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		if err.Error() == "invalid refresh token" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
@@ -86,7 +259,33 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, models.AuthResponse{
-		Token: token,
-		User:  *user,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         *user,
 	})
 }
+
+// JWKS publishes the public keys other services use to verify access tokens.
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.Service.Keys.JWKS())
+}
+
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	userID, err := h.Service.Logout(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if userID != 0 {
+		recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventLogout, Outcome: models.OutcomeSuccess, UserID: &userID})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
+)
+
+type DataExportHandler struct {
+	Service *service.DataExportService
+	Events  *service.SecurityEventService
+}
+
+func NewDataExportHandler(svc *service.DataExportService, events *service.SecurityEventService) *DataExportHandler {
+	return &DataExportHandler{Service: svc, Events: events}
+}
+
+func exportError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "export not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+	case "export not ready":
+		c.JSON(http.StatusConflict, gin.H{"error": "Export is not ready"})
+	case "export already in progress":
+		c.JSON(http.StatusConflict, gin.H{"error": "An export is already in progress"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
+
+// RequestExport starts collecting the caller's data; poll GetExport until it is ready.
+func (h *DataExportHandler) RequestExport(c *gin.Context) {
+	export, err := h.Service.RequestExport(c.GetInt("userID"))
+	if err != nil {
+		exportError(c, err)
+		return
+	}
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventDataExport, Outcome: models.OutcomePending, UserID: userRef(c.GetInt("userID")), Detail: "export=" + export.ID})
+	c.Header("Location", "/api/auth/me/exports/"+export.ID)
+	c.JSON(http.StatusAccepted, export)
+}
+
+func (h *DataExportHandler) ListExports(c *gin.Context) {
+	exports, err := h.Service.ListExports(c.GetInt("userID"))
+	if err != nil {
+		exportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, exports)
+}
+
+func (h *DataExportHandler) GetExport(c *gin.Context) {
+	export, err := h.Service.GetExport(c.GetInt("userID"), c.Param("id"))
+	if err != nil {
+		exportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, export)
+}
+
+func (h *DataExportHandler) DownloadExport(c *gin.Context) {
+	id := c.Param("id")
+	archive, err := h.Service.Download(c.GetInt("userID"), id)
+	if err != nil {
+		exportError(c, err)
+		return
+	}
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventDataExportDownload, Outcome: models.OutcomeSuccess, UserID: userRef(c.GetInt("userID")), Detail: "export=" + id})
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="data-export-%s.zip"`, time.Now().UTC().Format("20060102")))
+	c.Data(http.StatusOK, "application/zip", archive)
+}
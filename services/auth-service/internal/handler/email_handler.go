@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
+)
+
+type EmailHandler struct {
+	Service *service.EmailService
+	Events  *service.SecurityEventService
+}
+
+func NewEmailHandler(svc *service.EmailService, events *service.SecurityEventService) *EmailHandler {
+	return &EmailHandler{Service: svc, Events: events}
+}
+
+func (h *EmailHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.Service.VerifyEmail(&req); err != nil {
+		if err.Error() == "invalid or expired token" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+func (h *EmailHandler) ResendVerification(c *gin.Context) {
+	if err := h.Service.ResendVerification(c.GetInt("userID")); err != nil {
+		switch err.Error() {
+		case "no email address on account", "email already verified":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case "user not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+}
+
+func (h *EmailHandler) ChangeEmail(c *gin.Context) {
+	var req models.ChangeEmailRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	status, err := h.Service.RequestEmailChange(c.GetInt("userID"), &req)
+	if err != nil {
+		switch err.Error() {
+		case "invalid credentials":
+			recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventEmailChange, Outcome: models.OutcomeFailure, UserID: userRef(c.GetInt("userID"))})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		case "email already in use":
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+		case "email unchanged":
+			c.JSON(http.StatusBadRequest, gin.H{"error": "New email is the same as the current one"})
+		case "user not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	// Accounts without a verified address switch at once; others wait for both confirmations
+	outcome := models.OutcomeSuccess
+	if status == "confirmation_sent" {
+		outcome = models.OutcomePending
+	}
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventEmailChange, Outcome: outcome, UserID: userRef(c.GetInt("userID"))})
+
+	c.JSON(http.StatusAccepted, gin.H{"status": status})
+}
+
+func (h *EmailHandler) ConfirmEmailChange(c *gin.Context) {
+	var req models.ConfirmEmailChangeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	status, userID, err := h.Service.ConfirmEmailChange(&req)
+	if err != nil {
+		switch err.Error() {
+		case "invalid or expired token":
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		case "email already in use":
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	if status == "completed" {
+		recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventEmailChange, Outcome: models.OutcomeSuccess, UserID: &userID, Detail: "confirmed"})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": status})
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
+)
+
+type IntrospectionHandler struct {
+	Service  *service.IntrospectionService
+	CacheTTL time.Duration
+}
+
+func NewIntrospectionHandler(svc *service.IntrospectionService, cacheTTL time.Duration) *IntrospectionHandler {
+	return &IntrospectionHandler{Service: svc, CacheTTL: cacheTTL}
+}
+
+// Introspect answers RFC 7662 token introspection requests, sent as a form
+// or as JSON.
+func (h *IntrospectionHandler) Introspect(c *gin.Context) {
+	var req models.IntrospectRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+
+	result, err := h.Service.Introspect(req.Token)
+	if err != nil {
+		log.Printf("Failed to introspect token for %s: %v", c.GetString("serviceClient"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	// Only the caller may keep the answer, and never past the token's expiry.
+	// The short TTL bounds how long a revoked session keeps working.
+	maxAge := h.CacheTTL
+	if result.Active && result.Exp > 0 {
+		if left := time.Until(time.Unix(result.Exp, 0)); left < maxAge {
+			maxAge = left
+		}
+	}
+	if seconds := int(maxAge.Seconds()); seconds > 0 {
+		c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", seconds))
+	} else {
+		c.Header("Cache-Control", "no-store")
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
+)
+
+type InviteHandler struct {
+	Service *service.InviteService
+}
+
+func NewInviteHandler(svc *service.InviteService) *InviteHandler {
+	return &InviteHandler{Service: svc}
+}
+
+func inviteError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "exactly one of username or email is required":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either a username or an email address"})
+	case "invalid or expired invite":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invite"})
+	case "invite is for someone else":
+		c.JSON(http.StatusForbidden, gin.H{"error": "This invite was issued to a different account"})
+	case "already a member":
+		c.JSON(http.StatusConflict, gin.H{"error": "Already a member of this organization"})
+	case "invite not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+	case "username already exists":
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+	case "email already in use":
+		c.JSON(http.StatusConflict, gin.H{"error": "An account with this email already exists, log in to accept the invite"})
+	default:
+		if usernameRejected(c, err) || passwordRejected(c, err, "Password") || registrationRejected(c, err) {
+			return
+		}
+		orgError(c, err)
+	}
+}
+
+func (h *InviteHandler) CreateInvite(c *gin.Context) {
+	orgID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+	var req models.CreateInviteRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	resp, err := h.Service.CreateInvite(c.GetInt("userID"), orgID, &req)
+	if err != nil {
+		inviteError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *InviteHandler) ListInvites(c *gin.Context) {
+	orgID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	invites, err := h.Service.ListInvites(c.GetInt("userID"), orgID)
+	if err != nil {
+		inviteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, invites)
+}
+
+func (h *InviteHandler) RevokeInvite(c *gin.Context) {
+	orgID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+	inviteID, ok := intParam(c, "inviteId")
+	if !ok {
+		return
+	}
+
+	if err := h.Service.RevokeInvite(c.GetInt("userID"), orgID, inviteID); err != nil {
+		inviteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Invite revoked"})
+}
+
+func (h *InviteHandler) AcceptInvite(c *gin.Context) {
+	var req models.AcceptInviteRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	org, err := h.Service.AcceptInvite(c.GetInt("userID"), &req)
+	if err != nil {
+		inviteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, org)
+}
+
+// RegisterWithInvite is the accept step for people without an account.
+func (h *InviteHandler) RegisterWithInvite(c *gin.Context) {
+	var req models.RegisterInviteRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tokens, user, err := h.Service.RegisterWithInvite(&req)
+	if err != nil {
+		inviteError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, models.AuthResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         *user,
+	})
+}
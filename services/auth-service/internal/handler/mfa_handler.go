@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
+)
+
+type MFAHandler struct {
+	Service *service.MFAService
+	Events  *service.SecurityEventService
+}
+
+func NewMFAHandler(svc *service.MFAService, events *service.SecurityEventService) *MFAHandler {
+	return &MFAHandler{Service: svc, Events: events}
+}
+
+func mfaError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "invalid code":
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+	case "invalid credentials":
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+	case "invalid or expired challenge":
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge, please log in again"})
+	case "2fa already enabled", "2fa not enabled", "2fa setup not started":
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case "account disabled":
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+	case "user not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
+
+func (h *MFAHandler) LoginMFA(c *gin.Context) {
+	var req models.MFALoginRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": gin.H{"Code": "Either code or recovery_code is required"}})
+		return
+	}
+
+	tokens, user, err := h.Service.CompleteLogin(&req, c.ClientIP())
+	if err != nil {
+		if outcome := loginOutcome(err); outcome != "" {
+			recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventLoginMFA, Outcome: outcome, UserID: userRef(h.Service.ChallengeUser(req.MFAToken))})
+		}
+		if lockedOut(c, err) {
+			return
+		}
+		mfaError(c, err)
+		return
+	}
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventLoginMFA, Outcome: models.OutcomeSuccess, UserID: &user.ID})
+
+	c.JSON(http.StatusOK, models.AuthResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         *user,
+	})
+}
+
+func (h *MFAHandler) SetupTOTP(c *gin.Context) {
+	resp, err := h.Service.SetupTOTP(c.GetInt("userID"))
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	var req models.TOTPCodeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	resp, err := h.Service.ConfirmTOTP(c.GetInt("userID"), &req)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventMFAEnable, Outcome: models.OutcomeSuccess, UserID: userRef(c.GetInt("userID"))})
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	var req models.DisableTOTPRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.Service.DisableTOTP(c.GetInt("userID"), &req); err != nil {
+		if err.Error() == "invalid credentials" || err.Error() == "invalid code" {
+			recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventMFADisable, Outcome: models.OutcomeFailure, UserID: userRef(c.GetInt("userID"))})
+		}
+		mfaError(c, err)
+		return
+	}
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventMFADisable, Outcome: models.OutcomeSuccess, UserID: userRef(c.GetInt("userID"))})
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.TOTPCodeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	resp, err := h.Service.RegenerateRecoveryCodes(c.GetInt("userID"), &req)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
+)
+
+// Holds the state of a sign-in in progress, so only the browser that started
+// it can finish it
+const oidcStateCookie = "oidc_state"
+
+type OIDCHandler struct {
+	Service      *service.OIDCService
+	Events       *service.SecurityEventService
+	SecureCookie bool // Set when the app is served over HTTPS
+}
+
+func NewOIDCHandler(svc *service.OIDCService, events *service.SecurityEventService, secureCookie bool) *OIDCHandler {
+	return &OIDCHandler{Service: svc, Events: events, SecureCookie: secureCookie}
+}
+
+// setStateCookie stores state for the callback, or clears it when empty. It
+// lives for the browser session; the state itself expires server-side.
+func (h *OIDCHandler) setStateCookie(c *gin.Context, state string) {
+	maxAge := 0
+	if state == "" {
+		maxAge = -1
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, maxAge, "/api/auth/oidc", "", h.SecureCookie, true)
+}
+
+func oidcError(c *gin.Context, err error) {
+	if registrationRejected(c, err) {
+		return
+	}
+	switch err.Error() {
+	case "unknown provider":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+	case "invalid or expired state":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sign-in session expired, please start again"})
+	case "sign-in with provider failed":
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign-in with the identity provider failed"})
+	case "identity linked to another account":
+		c.JSON(http.StatusConflict, gin.H{"error": "This identity is already linked to another account"})
+	case "identity not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identity not found"})
+	case "cannot remove last sign-in method":
+		c.JSON(http.StatusConflict, gin.H{"error": "Set a password or link another provider before removing this one"})
+	case "account disabled":
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+	case "email not verified":
+		c.JSON(http.StatusForbidden, gin.H{"error": "Verify your email address before signing in"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
+
+func (h *OIDCHandler) ListProviders(c *gin.Context) {
+	names := h.Service.ProviderNames()
+	sort.Strings(names)
+	c.JSON(http.StatusOK, gin.H{"providers": names})
+}
+
+// Authorize returns the provider URL the frontend should navigate to.
+func (h *OIDCHandler) Authorize(c *gin.Context) {
+	url, state, err := h.Service.Authorize(c.Param("provider"), 0)
+	if err != nil {
+		oidcError(c, err)
+		return
+	}
+	h.setStateCookie(c, state)
+	c.JSON(http.StatusOK, models.OIDCAuthorizeResponse{AuthorizationURL: url})
+}
+
+// Link is Authorize for a signed-in user adding a provider to their account.
+func (h *OIDCHandler) Link(c *gin.Context) {
+	url, state, err := h.Service.Authorize(c.Param("provider"), c.GetInt("userID"))
+	if err != nil {
+		oidcError(c, err)
+		return
+	}
+	h.setStateCookie(c, state)
+	c.JSON(http.StatusOK, models.OIDCAuthorizeResponse{AuthorizationURL: url})
+}
+
+// Callback receives the code and state the provider redirected the browser with.
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	var req models.OIDCCallbackRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	provider := c.Param("provider")
+	browserState, _ := c.Cookie(oidcStateCookie)
+	h.setStateCookie(c, "")
+	result, err := h.Service.Callback(provider, &req, browserState)
+	if err != nil {
+		if err.Error() == "account disabled" {
+			recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventLoginOIDC, Outcome: models.OutcomeDenied, Detail: provider})
+		}
+		oidcError(c, err)
+		return
+	}
+
+	if result.Linked != nil {
+		recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventIdentityLink, Outcome: models.OutcomeSuccess, UserID: &result.Linked.UserID, Detail: provider})
+		c.JSON(http.StatusOK, result.Linked)
+		return
+	}
+	login := result.Login
+	// Second step: POST /api/auth/login/mfa with the challenge token, as for a password login
+	if login.Challenge != nil {
+		recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventLoginOIDC, Outcome: models.OutcomePending, UserID: &login.User.ID, Detail: provider})
+		c.JSON(http.StatusOK, login.Challenge)
+		return
+	}
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventLoginOIDC, Outcome: models.OutcomeSuccess, UserID: &login.User.ID, Detail: provider})
+	c.JSON(http.StatusOK, models.AuthResponse{
+		Token:        login.Tokens.AccessToken,
+		RefreshToken: login.Tokens.RefreshToken,
+		ExpiresIn:    login.Tokens.ExpiresIn,
+		User:         *login.User,
+	})
+}
+
+func (h *OIDCHandler) ListIdentities(c *gin.Context) {
+	identities, err := h.Service.ListIdentities(c.GetInt("userID"))
+	if err != nil {
+		oidcError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, identities)
+}
+
+func (h *OIDCHandler) Unlink(c *gin.Context) {
+	id, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.Service.Unlink(c.GetInt("userID"), id); err != nil {
+		oidcError(c, err)
+		return
+	}
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventIdentityUnlink, Outcome: models.OutcomeSuccess, UserID: userRef(c.GetInt("userID")), Detail: "identity=" + strconv.Itoa(id)})
+	c.JSON(http.StatusOK, gin.H{"message": "Identity unlinked"})
+}
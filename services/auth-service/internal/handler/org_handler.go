@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
+)
+
+type OrgHandler struct {
+	Service *service.OrgService
+}
+
+func NewOrgHandler(svc *service.OrgService) *OrgHandler {
+	return &OrgHandler{Service: svc}
+}
+
+func orgError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "organization not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case "member not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+	case "forbidden":
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your role in this organization does not allow this"})
+	case "organization needs an owner":
+		c.JSON(http.StatusConflict, gin.H{"error": "An organization must keep at least one owner"})
+	case "invalid org role":
+		c.JSON(http.StatusBadRequest, gin.H{"errors": gin.H{"Role": "Must be one of owner, admin, editor, viewer"}})
+	case "name is required":
+		c.JSON(http.StatusBadRequest, gin.H{"errors": gin.H{"Name": "This field is required"}})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
+
+// intParam parses a numeric path parameter and writes a 400 if it is invalid.
+func intParam(c *gin.Context, name string) (int, bool) {
+	n, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+		return 0, false
+	}
+	return n, true
+}
+
+func (h *OrgHandler) CreateOrg(c *gin.Context) {
+	var req models.OrgRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	org, err := h.Service.CreateOrg(c.GetInt("userID"), &req)
+	if err != nil {
+		orgError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, org)
+}
+
+func (h *OrgHandler) ListOrgs(c *gin.Context) {
+	orgs, err := h.Service.ListOrgs(c.GetInt("userID"))
+	if err != nil {
+		orgError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, orgs)
+}
+
+func (h *OrgHandler) GetOrg(c *gin.Context) {
+	orgID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	org, err := h.Service.GetOrg(c.GetInt("userID"), orgID)
+	if err != nil {
+		orgError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, org)
+}
+
+func (h *OrgHandler) RenameOrg(c *gin.Context) {
+	orgID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+	var req models.OrgRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	org, err := h.Service.RenameOrg(c.GetInt("userID"), orgID, &req)
+	if err != nil {
+		orgError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, org)
+}
+
+func (h *OrgHandler) ListMembers(c *gin.Context) {
+	orgID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	members, err := h.Service.ListMembers(c.GetInt("userID"), orgID)
+	if err != nil {
+		orgError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, members)
+}
+
+func (h *OrgHandler) UpdateMember(c *gin.Context) {
+	orgID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+	memberID, ok := intParam(c, "userId")
+	if !ok {
+		return
+	}
+	var req models.UpdateMemberRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	member, err := h.Service.UpdateMemberRole(c.GetInt("userID"), orgID, memberID, req.Role)
+	if err != nil {
+		orgError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+func (h *OrgHandler) RemoveMember(c *gin.Context) {
+	orgID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+	memberID, ok := intParam(c, "userId")
+	if !ok {
+		return
+	}
+
+	if err := h.Service.RemoveMember(c.GetInt("userID"), orgID, memberID); err != nil {
+		orgError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
+)
+
+type PasswordHandler struct {
+	Service *service.PasswordService
+	Events  *service.SecurityEventService
+}
+
+func NewPasswordHandler(svc *service.PasswordService, events *service.SecurityEventService) *PasswordHandler {
+	return &PasswordHandler{Service: svc, Events: events}
+}
+
+func (h *PasswordHandler) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tokens, user, err := h.Service.ChangePassword(c.GetInt("userID"), &req)
+	if err != nil {
+		if err.Error() == "invalid credentials" {
+			recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventPasswordChange, Outcome: models.OutcomeFailure, UserID: userRef(c.GetInt("userID"))})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+			return
+		}
+		if err.Error() == "user not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if passwordRejected(c, err, "NewPassword") {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventPasswordChange, Outcome: models.OutcomeSuccess, UserID: &user.ID})
+
+	c.JSON(http.StatusOK, models.AuthResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         *user,
+	})
+}
+
+func (h *PasswordHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.Service.ForgotPassword(&req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists, a reset link has been sent"})
+}
+
+func (h *PasswordHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	userID, err := h.Service.ResetPassword(&req)
+	if err != nil {
+		if err.Error() == "invalid or expired token" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+		if passwordRejected(c, err, "NewPassword") {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	recordEvent(c, h.Events, models.SecurityEvent{Type: models.EventPasswordReset, Outcome: models.OutcomeSuccess, UserID: &userID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
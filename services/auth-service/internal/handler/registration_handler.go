@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
+)
+
+// RegistrationHandler is the admin side of who may sign up.
+type RegistrationHandler struct {
+	Service *service.RegistrationService
+}
+
+func NewRegistrationHandler(svc *service.RegistrationService) *RegistrationHandler {
+	return &RegistrationHandler{Service: svc}
+}
+
+func (h *RegistrationHandler) GetSettings(c *gin.Context) {
+	settings, err := h.Service.Settings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *RegistrationHandler) UpdateSettings(c *gin.Context) {
+	var req models.UpdateRegistrationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	settings, err := h.Service.UpdateSettings(c.GetInt("userID"), &req)
+	if err != nil {
+		switch err.Error() {
+		case "invalid registration mode":
+			c.JSON(http.StatusBadRequest, gin.H{"errors": gin.H{"Mode": "Must be open, invite, closed or domain"}})
+		case "invalid domain":
+			c.JSON(http.StatusBadRequest, gin.H{"errors": gin.H{"AllowedDomains": "Must be domain names such as example.com"}})
+		case "allowed domains required":
+			c.JSON(http.StatusBadRequest, gin.H{"errors": gin.H{"AllowedDomains": "Domain mode needs at least one domain"}})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *RegistrationHandler) ListCodes(c *gin.Context) {
+	codes, err := h.Service.ListCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, codes)
+}
+
+func (h *RegistrationHandler) CreateCode(c *gin.Context) {
+	var req models.CreateInviteCodeRequest
+	// The body is optional; an empty body creates a single-use code
+	if c.Request.ContentLength > 0 && !bindJSON(c, &req) {
+		return
+	}
+
+	resp, err := h.Service.CreateCode(c.GetInt("userID"), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *RegistrationHandler) RevokeCode(c *gin.Context) {
+	id, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.Service.RevokeCode(c.GetInt("userID"), id); err != nil {
+		if err.Error() == "invite code not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite code not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Invite code revoked"})
+}
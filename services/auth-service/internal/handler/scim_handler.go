@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/scim"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
+)
+
+// ScimHandler serves /scim/v2. Responses follow RFC 7644, including its error
+// format, instead of the {"error": ...} bodies used elsewhere.
+type ScimHandler struct {
+	Service *service.ScimService
+}
+
+func NewScimHandler(svc *service.ScimService) *ScimHandler {
+	return &ScimHandler{Service: svc}
+}
+
+func scimJSON(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(status, body)
+}
+
+func scimFail(c *gin.Context, status int, scimType, detail string) {
+	scimJSON(c, status, models.ScimError{
+		Schemas:  []string{models.ScimErrorSchema},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+func scimError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "user not found":
+		scimFail(c, http.StatusNotFound, "", "User not found")
+	case "group not found":
+		scimFail(c, http.StatusNotFound, "", "Group not found")
+	case "username already exists":
+		scimFail(c, http.StatusConflict, "uniqueness", "userName is already taken")
+	case "email already in use":
+		scimFail(c, http.StatusConflict, "uniqueness", "Email address is already in use")
+	case "group already exists":
+		scimFail(c, http.StatusConflict, "uniqueness", "displayName is already taken")
+	case "invalid filter":
+		scimFail(c, http.StatusBadRequest, "invalidFilter", "Only `attribute eq \"value\"` filters on supported attributes are allowed")
+	case "invalid path":
+		scimFail(c, http.StatusBadRequest, "invalidPath", "Unsupported patch path")
+	case "invalid patch":
+		scimFail(c, http.StatusBadRequest, "invalidSyntax", "Patch op must be add, replace or remove")
+	case "invalid value":
+		scimFail(c, http.StatusBadRequest, "invalidValue", "Invalid attribute value")
+	case "unknown member":
+		scimFail(c, http.StatusBadRequest, "invalidValue", "Members must be users provisioned by this tenant")
+	default:
+		scimFail(c, http.StatusInternalServerError, "", "Internal server error")
+	}
+}
+
+// scimBind decodes a JSON body and writes a SCIM error if it is malformed.
+func scimBind(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		scimFail(c, http.StatusBadRequest, "invalidSyntax", "Malformed request body")
+		return false
+	}
+	return true
+}
+
+// scimID parses :id; ids that cannot exist are simply not found.
+func scimID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		scimFail(c, http.StatusNotFound, "", "Resource not found")
+		return 0, false
+	}
+	return id, true
+}
+
+func scimTenant(c *gin.Context) *scim.Tenant {
+	return c.MustGet("scimTenant").(*scim.Tenant)
+}
+
+func userResource(u *models.ScimUser) models.ScimUserResource {
+	active := !u.Disabled()
+	res := models.ScimUserResource{
+		Schemas:    []string{models.ScimUserSchema},
+		ID:         strconv.Itoa(u.ID),
+		ExternalID: u.ExternalID,
+		UserName:   u.Username,
+		Active:     &active,
+		Meta: &models.ScimMeta{
+			ResourceType: "User",
+			Created:      u.CreatedAt,
+			Location:     "/scim/v2/Users/" + strconv.Itoa(u.ID),
+		},
+	}
+	if u.Email != "" {
+		res.Emails = []models.ScimEmail{{Value: u.Email, Type: "work", Primary: true}}
+	}
+	return res
+}
+
+func groupResource(g *models.ScimGroup) models.ScimGroupResource {
+	members := make([]models.ScimMemberRef, 0, len(g.Members))
+	for _, m := range g.Members {
+		members = append(members, models.ScimMemberRef{Value: strconv.Itoa(m.UserID), Display: m.Username})
+	}
+	updated := g.UpdatedAt
+	return models.ScimGroupResource{
+		Schemas:     []string{models.ScimGroupSchema},
+		ID:          strconv.Itoa(g.ID),
+		ExternalID:  g.ExternalID,
+		DisplayName: g.DisplayName,
+		Members:     members,
+		Meta: &models.ScimMeta{
+			ResourceType: "Group",
+			Created:      g.CreatedAt,
+			LastModified: &updated,
+			Location:     "/scim/v2/Groups/" + strconv.Itoa(g.ID),
+		},
+	}
+}
+
+func listResponse(req *models.ScimListRequest, total int, resources interface{}, n int) models.ScimListResponse {
+	return models.ScimListResponse{
+		Schemas:      []string{models.ScimListSchema},
+		TotalResults: total,
+		StartIndex:   req.StartIndex,
+		ItemsPerPage: n,
+		Resources:    resources,
+	}
+}
+
+func (h *ScimHandler) ListUsers(c *gin.Context) {
+	var req models.ScimListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		scimFail(c, http.StatusBadRequest, "invalidValue", "startIndex and count must be numbers")
+		return
+	}
+	users, total, err := h.Service.ListUsers(scimTenant(c), &req)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	resources := make([]models.ScimUserResource, 0, len(users))
+	for i := range users {
+		resources = append(resources, userResource(&users[i]))
+	}
+	scimJSON(c, http.StatusOK, listResponse(&req, total, resources, len(resources)))
+}
+
+func (h *ScimHandler) GetUser(c *gin.Context) {
+	id, ok := scimID(c)
+	if !ok {
+		return
+	}
+	user, err := h.Service.GetUser(scimTenant(c), id)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, userResource(user))
+}
+
+func (h *ScimHandler) CreateUser(c *gin.Context) {
+	var req models.ScimUserResource
+	if !scimBind(c, &req) {
+		return
+	}
+	user, err := h.Service.CreateUser(scimTenant(c), &req)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	res := userResource(user)
+	c.Header("Location", res.Meta.Location)
+	scimJSON(c, http.StatusCreated, res)
+}
+
+func (h *ScimHandler) PatchUser(c *gin.Context) {
+	id, ok := scimID(c)
+	if !ok {
+		return
+	}
+	var req models.ScimPatchRequest
+	if !scimBind(c, &req) {
+		return
+	}
+	user, err := h.Service.PatchUser(scimTenant(c), id, req.Operations)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, userResource(user))
+}
+
+// DeleteUser deactivates the account; see ScimService.DeleteUser.
+func (h *ScimHandler) DeleteUser(c *gin.Context) {
+	id, ok := scimID(c)
+	if !ok {
+		return
+	}
+	if err := h.Service.DeleteUser(scimTenant(c), id); err != nil {
+		scimError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ScimHandler) ListGroups(c *gin.Context) {
+	var req models.ScimListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		scimFail(c, http.StatusBadRequest, "invalidValue", "startIndex and count must be numbers")
+		return
+	}
+	groups, total, err := h.Service.ListGroups(scimTenant(c), &req)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	resources := make([]models.ScimGroupResource, 0, len(groups))
+	for i := range groups {
+		resources = append(resources, groupResource(&groups[i]))
+	}
+	scimJSON(c, http.StatusOK, listResponse(&req, total, resources, len(resources)))
+}
+
+func (h *ScimHandler) GetGroup(c *gin.Context) {
+	id, ok := scimID(c)
+	if !ok {
+		return
+	}
+	group, err := h.Service.GetGroup(scimTenant(c), id)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, groupResource(group))
+}
+
+func (h *ScimHandler) CreateGroup(c *gin.Context) {
+	var req models.ScimGroupResource
+	if !scimBind(c, &req) {
+		return
+	}
+	group, err := h.Service.CreateGroup(scimTenant(c), &req)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	res := groupResource(group)
+	c.Header("Location", res.Meta.Location)
+	scimJSON(c, http.StatusCreated, res)
+}
+
+func (h *ScimHandler) PatchGroup(c *gin.Context) {
+	id, ok := scimID(c)
+	if !ok {
+		return
+	}
+	var req models.ScimPatchRequest
+	if !scimBind(c, &req) {
+		return
+	}
+	group, err := h.Service.PatchGroup(scimTenant(c), id, req.Operations)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, groupResource(group))
+}
+
+func (h *ScimHandler) DeleteGroup(c *gin.Context) {
+	id, ok := scimID(c)
+	if !ok {
+		return
+	}
+	if err := h.Service.DeleteGroup(scimTenant(c), id); err != nil {
+		scimError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
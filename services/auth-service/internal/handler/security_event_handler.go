@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/lockout"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
+)
+
+type SecurityEventHandler struct {
+	Service *service.SecurityEventService
+}
+
+func NewSecurityEventHandler(svc *service.SecurityEventService) *SecurityEventHandler {
+	return &SecurityEventHandler{Service: svc}
+}
+
+// MyEvents lists the caller's own login history and account changes.
+func (h *SecurityEventHandler) MyEvents(c *gin.Context) {
+	var req models.SecurityEventQuery
+	if !bindQuery(c, &req) {
+		return
+	}
+	page, err := h.Service.ListForUser(c.GetInt("userID"), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// ListEvents lets admins search across all users.
+func (h *SecurityEventHandler) ListEvents(c *gin.Context) {
+	var req models.SecurityEventQuery
+	if !bindQuery(c, &req) {
+		return
+	}
+	page, err := h.Service.Query(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// recordEvent adds the caller's IP and user agent to an event and stores it.
+// Changes made while impersonating are attributed to the admin.
+func recordEvent(c *gin.Context, events *service.SecurityEventService, e models.SecurityEvent) {
+	if actorID := c.GetInt("actorID"); actorID != 0 && e.ActorID == nil {
+		e.ActorID = &actorID
+	}
+	e.IP = c.ClientIP()
+	e.UserAgent = c.Request.UserAgent()
+	events.Record(&e)
+}
+
+// userRef turns a user id into the optional form events use.
+func userRef(id int) *int {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// loginOutcome classifies a failed sign-in, or returns "" for server errors
+// that say nothing about the credentials.
+func loginOutcome(err error) string {
+	var locked *lockout.LockedError
+	switch {
+	case errors.As(err, &locked):
+		return models.OutcomeLocked
+	case err.Error() == "account disabled", err.Error() == "email not verified":
+		return models.OutcomeDenied
+	case err.Error() == "invalid credentials", err.Error() == "invalid code", err.Error() == "invalid or expired challenge":
+		return models.OutcomeFailure
+	}
+	return ""
+}
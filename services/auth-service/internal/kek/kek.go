@@ -0,0 +1,123 @@
+// Package kek encrypts secrets that auth-service keeps in the shared
+// database, such as token signing keys and TOTP secrets, with a key
+// encryption key (KEK) only auth-service holds. Other services open the same
+// database with the same credentials; they can read the rows but not the
+// secrets in them.
+package kek
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sealed values look like "kek1:<key id>:<base64 nonce and ciphertext>".
+const prefix = "kek1:"
+
+type key struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// KEK seals with the current key and opens with the current or a previous
+// one, so the key can be rotated: values sealed with a previous key are
+// resealed as they are read.
+type KEK struct {
+	current  key
+	previous []key
+}
+
+// Parse takes base64 encoded 32 byte keys, e.g. from `openssl rand -base64 32`.
+func Parse(current string, previous []string) (*KEK, error) {
+	if current == "" {
+		return nil, errors.New("key encryption key required")
+	}
+	cur, err := parseKey(current)
+	if err != nil {
+		return nil, err
+	}
+	k := &KEK{current: cur}
+	for _, p := range previous {
+		if p == "" {
+			continue
+		}
+		prev, err := parseKey(p)
+		if err != nil {
+			return nil, fmt.Errorf("previous key: %w", err)
+		}
+		k.previous = append(k.previous, prev)
+	}
+	return k, nil
+}
+
+func parseKey(encoded string) (key, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(raw) != 32 {
+		return key{}, errors.New("key encryption key must be 32 bytes, base64 encoded")
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return key{}, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return key{}, err
+	}
+	sum := sha256.Sum256(raw)
+	return key{id: base64.RawURLEncoding.EncodeToString(sum[:6]), aead: aead}, nil
+}
+
+// Seal encrypts plaintext with AES-256-GCM. The context, e.g. the table and
+// row the value is stored in, must be given again to open it, so a sealed
+// value copied into another row does not open.
+func (k *KEK) Seal(plaintext []byte, context string) (string, error) {
+	nonce := make([]byte, k.current.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := k.current.aead.Seal(nonce, nonce, plaintext, []byte(context))
+	return prefix + k.current.id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value made by Seal with the same context.
+func (k *KEK) Open(sealed, context string) ([]byte, error) {
+	id, data, ok := strings.Cut(strings.TrimPrefix(sealed, prefix), ":")
+	if !ok || !IsSealed(sealed) {
+		return nil, errors.New("value is not sealed")
+	}
+	var aead cipher.AEAD
+	for _, candidate := range append([]key{k.current}, k.previous...) {
+		if candidate.id == id {
+			aead = candidate.aead
+			break
+		}
+	}
+	if aead == nil {
+		return nil, fmt.Errorf("value is sealed with unknown key %s", id)
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return nil, errors.New("malformed sealed value")
+	}
+	plaintext, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], []byte(context))
+	if err != nil {
+		return nil, errors.New("failed to open sealed value")
+	}
+	return plaintext, nil
+}
+
+// IsSealed tells sealed values from plaintext stored before encryption.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Current reports whether sealed was made with the current key, i.e. needs
+// no resealing.
+func (k *KEK) Current(sealed string) bool {
+	return strings.HasPrefix(sealed, prefix+k.current.id+":")
+}
@@ -0,0 +1,77 @@
+package keys
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Keyring holds every key that is pending, signing or still verifying.
+// It is safe for concurrent use and is refreshed from the database by
+// the key service.
+type Keyring struct {
+	mu   sync.RWMutex
+	keys []*SigningKey
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{}
+}
+
+// Replace swaps in a freshly loaded key set.
+func (k *Keyring) Replace(keys []*SigningKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = keys
+}
+
+// SigningKey returns the most recently activated key that has not retired.
+func (k *Keyring) SigningKey() (*SigningKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := time.Now().UTC()
+	var current *SigningKey
+	for _, key := range k.keys {
+		if key.ActivatesAt.After(now) || key.Retired(now) {
+			continue
+		}
+		if current == nil || key.ActivatesAt.After(current.ActivatesAt) {
+			current = key
+		}
+	}
+	if current == nil {
+		return nil, errors.New("no signing key available")
+	}
+	return current, nil
+}
+
+// Key looks up a verification key by kid.
+func (k *Keyring) Key(kid string) *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := time.Now().UTC()
+	for _, key := range k.keys {
+		if key.ID == kid && !key.Retired(now) {
+			return key
+		}
+	}
+	return nil
+}
+
+// JWKS publishes pending keys as well as active ones so that verifiers
+// already know a key by the time auth-service starts signing with it.
+func (k *Keyring) JWKS() JWKS {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := time.Now().UTC()
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range k.keys {
+		if !key.Retired(now) {
+			set.Keys = append(set.Keys, key.JWK())
+		}
+	}
+	return set
+}
@@ -0,0 +1,101 @@
+package keys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SigningKey is an Ed25519 key used to sign access tokens (alg EdDSA).
+// A key signs from ActivatesAt until a newer key activates, and keeps
+// verifying until RetiresAt so tokens it already signed stay valid.
+type SigningKey struct {
+	ID          string
+	PrivateKey  ed25519.PrivateKey
+	ActivatesAt time.Time
+	RetiresAt   *time.Time
+}
+
+// JWK is the public half of a signing key as published in the JWKS document.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func GenerateSigningKey() (*SigningKey, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return newSigningKey(priv), nil
+}
+
+// ParseSigningKey reads a PKCS#8 PEM encoded Ed25519 private key,
+// e.g. one created with `openssl genpkey -algorithm ed25519`.
+func ParseSigningKey(pemData []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	priv, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not Ed25519")
+	}
+	return newSigningKey(priv), nil
+}
+
+func (k *SigningKey) EncodePEM() (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+func (k *SigningKey) Retired(now time.Time) bool {
+	return k.RetiresAt != nil && !now.Before(*k.RetiresAt)
+}
+
+func newSigningKey(priv ed25519.PrivateKey) *SigningKey {
+	return &SigningKey{
+		ID:         thumbprint(priv.Public().(ed25519.PublicKey)),
+		PrivateKey: priv,
+	}
+}
+
+func (k *SigningKey) JWK() JWK {
+	return JWK{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(k.PrivateKey.Public().(ed25519.PublicKey)),
+		Kid: k.ID,
+		Use: "sig",
+		Alg: "EdDSA",
+	}
+}
+
+// thumbprint derives the key ID from the RFC 7638 JWK thumbprint so the same
+// key always gets the same kid, whichever replica loaded it.
+func thumbprint(pub ed25519.PublicKey) string {
+	x := base64.RawURLEncoding.EncodeToString(pub)
+	sum := sha256.Sum256([]byte(`{"crv":"Ed25519","kty":"OKP","x":"` + x + `"}`))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
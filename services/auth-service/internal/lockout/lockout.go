@@ -0,0 +1,145 @@
+// Package lockout throttles password guessing. Failed logins are counted per
+// account and per client IP; past a threshold the key is locked out for an
+// exponentially growing period.
+package lockout
+
+import (
+	"strings"
+	"time"
+)
+
+// Record is the failure state tracked for a single key.
+type Record struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// Store persists failure records. Implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns nil when nothing is tracked for key.
+	Get(key string) (*Record, error)
+	// RecordFailure adds a failure and returns the new count. Counting starts
+	// over when the previous failure is older than window.
+	RecordFailure(key string, now time.Time, window time.Duration) (int, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+type Policy struct {
+	Threshold   int           // Failures allowed before the first lockout
+	BaseLockout time.Duration // Lockout after the first failure over the threshold, doubled for each one after
+	MaxLockout  time.Duration
+	Window      time.Duration // Failures older than this are forgotten
+}
+
+// LockedError is returned while a key is locked out.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return "too many attempts"
+}
+
+type Guard struct {
+	Store   Store
+	Account Policy
+	IP      Policy
+}
+
+// AccountKey folds case and trailing spaces the way SQL Server compares
+// usernames, so "alice " counts against the same lockout as "alice".
+func AccountKey(username string) string {
+	return "account:" + strings.ToLower(strings.TrimRight(username, " "))
+}
+
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns a *LockedError if the account or the IP is locked out. Call
+// it before verifying the password so locked requests cost no hashing work.
+func (g *Guard) Check(username, ip string) error {
+	now := time.Now().UTC()
+	var longest time.Duration
+	for _, key := range g.keys(username, ip) {
+		rec, err := g.Store.Get(key)
+		if err != nil {
+			return err
+		}
+		if rec != nil && rec.LockedUntil.After(now) {
+			if wait := rec.LockedUntil.Sub(now); wait > longest {
+				longest = wait
+			}
+		}
+	}
+	if longest > 0 {
+		return &LockedError{RetryAfter: longest}
+	}
+	return nil
+}
+
+// Fail records a failed attempt and returns how long the caller now has to
+// wait, or zero if it may retry straight away.
+func (g *Guard) Fail(username, ip string) (time.Duration, error) {
+	now := time.Now().UTC()
+	var longest time.Duration
+	for _, key := range g.keys(username, ip) {
+		policy := g.Account
+		if strings.HasPrefix(key, "ip:") {
+			policy = g.IP
+		}
+
+		failures, err := g.Store.RecordFailure(key, now, policy.Window)
+		if err != nil {
+			return 0, err
+		}
+		lockout := policy.lockoutFor(failures)
+		if lockout == 0 {
+			continue
+		}
+		if err := g.Store.Lock(key, now.Add(lockout)); err != nil {
+			return 0, err
+		}
+		if lockout > longest {
+			longest = lockout
+		}
+	}
+	return longest, nil
+}
+
+// Succeed clears the account's failures. The IP record is left alone so a
+// sprayer cannot reset its counter by logging into an account it owns.
+func (g *Guard) Succeed(username string) error {
+	if username == "" {
+		return nil
+	}
+	return g.Store.Reset(AccountKey(username))
+}
+
+func (g *Guard) keys(username, ip string) []string {
+	var keys []string
+	if username != "" {
+		keys = append(keys, AccountKey(username))
+	}
+	if ip != "" {
+		keys = append(keys, IPKey(ip))
+	}
+	return keys
+}
+
+func (p Policy) lockoutFor(failures int) time.Duration {
+	over := failures - p.Threshold
+	if p.Threshold <= 0 || over <= 0 {
+		return 0
+	}
+	lockout := p.BaseLockout
+	for i := 1; i < over && lockout < p.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > p.MaxLockout {
+		lockout = p.MaxLockout
+	}
+	return lockout
+}
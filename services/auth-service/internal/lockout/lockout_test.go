@@ -0,0 +1,66 @@
+package lockout
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestGuard() *Guard {
+	policy := Policy{Threshold: 3, BaseLockout: time.Minute, MaxLockout: time.Hour, Window: time.Hour}
+	return &Guard{Store: NewMemoryStore(time.Hour), Account: policy, IP: Policy{}}
+}
+
+func TestGuardLocksAccountAfterThreshold(t *testing.T) {
+	g := newTestGuard()
+	for i := 0; i < 3; i++ {
+		if wait, err := g.Fail("alice", "10.0.0.1"); err != nil || wait != 0 {
+			t.Fatalf("failure %d: wait = %v, err = %v", i+1, wait, err)
+		}
+	}
+	if err := g.Check("alice", "10.0.0.2"); err != nil {
+		t.Fatalf("Check before lockout: %v", err)
+	}
+	if wait, err := g.Fail("alice", "10.0.0.1"); err != nil || wait != time.Minute {
+		t.Fatalf("failure over threshold: wait = %v, err = %v", wait, err)
+	}
+
+	var locked *LockedError
+	if err := g.Check("alice", "10.0.0.2"); !errors.As(err, &locked) {
+		t.Fatalf("Check after lockout = %v, want *LockedError", err)
+	}
+}
+
+func TestGuardIgnoresCaseAndTrailingSpaces(t *testing.T) {
+	g := newTestGuard()
+	for i := 0; i < 4; i++ {
+		if _, err := g.Fail("alice", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// SQL Server matches all of these to the account "alice"
+	for _, name := range []string{"alice", "Alice", "alice ", "ALICE   "} {
+		var locked *LockedError
+		if err := g.Check(name, ""); !errors.As(err, &locked) {
+			t.Errorf("Check(%q) = %v, want *LockedError", name, err)
+		}
+	}
+	if err := g.Check(" alice", ""); err != nil {
+		t.Errorf("Check(%q) = %v, want nil", " alice", err)
+	}
+}
+
+func TestGuardCountsPaddedFailuresTogether(t *testing.T) {
+	g := newTestGuard()
+	for _, name := range []string{"alice", "alice ", "alice  ", "Alice   "} {
+		if _, err := g.Fail(name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var locked *LockedError
+	if err := g.Check("alice", ""); !errors.As(err, &locked) {
+		t.Fatalf("Check = %v, want *LockedError", err)
+	}
+}
@@ -0,0 +1,73 @@
+package lockout
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps records in process memory. State is per replica and
+// lost on restart, so use the SQL store when running more than one replica.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+	maxAge  time.Duration
+}
+
+// NewMemoryStore forgets records that have seen no failure for maxAge.
+func NewMemoryStore(maxAge time.Duration) *MemoryStore {
+	return &MemoryStore{records: map[string]*Record{}, maxAge: maxAge}
+}
+
+func (m *MemoryStore) Get(key string) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.records[key]
+	if !ok {
+		return nil, nil
+	}
+	copied := *rec
+	return &copied, nil
+}
+
+func (m *MemoryStore) RecordFailure(key string, now time.Time, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prune(now)
+	rec, ok := m.records[key]
+	if !ok || now.Sub(rec.LastFailureAt) > window {
+		rec = &Record{}
+		m.records[key] = rec
+	}
+	rec.Failures++
+	rec.LastFailureAt = now
+	return rec.Failures, nil
+}
+
+func (m *MemoryStore) Lock(key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rec, ok := m.records[key]; ok {
+		rec.LockedUntil = until
+	}
+	return nil
+}
+
+func (m *MemoryStore) Reset(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.records, key)
+	return nil
+}
+
+// prune drops stale records so memory stays bounded. Callers hold the lock.
+func (m *MemoryStore) prune(now time.Time) {
+	for key, rec := range m.records {
+		if now.Sub(rec.LastFailureAt) > m.maxAge && now.After(rec.LockedUntil) {
+			delete(m.records, key)
+		}
+	}
+}
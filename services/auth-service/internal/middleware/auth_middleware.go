@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/service"
+)
+
+// RequireAuth rejects requests without a valid access token and exposes the
+// caller as userID, role, permissions and sessionID in the gin context. When
+// an admin is impersonating the user, actorID is the admin.
+func RequireAuth(svc *service.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
+		claims, err := svc.ValidateAccessToken(strings.TrimPrefix(authHeader, "Bearer "))
+		if err != nil {
+			if err.Error() == "invalid token" || err.Error() == "session revoked" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			}
+			c.Abort()
+			return
+		}
+
+		// JWT numbers are float64 by default
+		if sub, ok := claims["sub"].(float64); ok {
+			c.Set("userID", int(sub))
+		}
+		if role, ok := claims["role"].(string); ok {
+			c.Set("role", role)
+		}
+		if sid, ok := claims["sid"].(string); ok {
+			c.Set("sessionID", sid)
+		}
+		if act, ok := claims["act"].(map[string]interface{}); ok {
+			if sub, ok := act["sub"].(float64); ok {
+				c.Set("actorID", int(sub))
+			}
+		}
+		perms := []string{}
+		if list, ok := claims["perms"].([]interface{}); ok {
+			for _, p := range list {
+				if s, ok := p.(string); ok {
+					perms = append(perms, s)
+				}
+			}
+		}
+		c.Set("permissions", perms)
+
+		c.Next()
+	}
+}
+
+// DenyImpersonation keeps admins acting as a user away from the user's
+// credentials, profile, sessions, data exports, org memberships, account
+// deletion and admin endpoints. It must run after RequireAuth.
+func DenyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("actorID"); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed while impersonating a user"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission must run after RequireAuth.
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, p := range c.GetStringSlice("permissions") {
+			if p == perm {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		c.Abort()
+	}
+}
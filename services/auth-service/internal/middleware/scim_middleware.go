@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/scim"
+)
+
+// RequireScimTenant identifies the tenant by its bearer token and exposes it
+// as scimTenant in the gin context.
+func RequireScimTenant(tenants []scim.Tenant) gin.HandlerFunc {
+	// Compare digests so the time taken does not depend on the token length
+	digests := make([][32]byte, len(tenants))
+	for i, t := range tenants {
+		digests[i] = sha256.Sum256([]byte(t.Token))
+	}
+
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		presented := sha256.Sum256([]byte(strings.TrimPrefix(authHeader, "Bearer ")))
+
+		match := -1
+		for i := range digests {
+			if subtle.ConstantTimeCompare(presented[:], digests[i][:]) == 1 {
+				match = i
+			}
+		}
+		if !strings.HasPrefix(authHeader, "Bearer ") || match < 0 {
+			c.Header("Content-Type", "application/scim+json")
+			c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ScimError{
+				Schemas: []string{models.ScimErrorSchema},
+				Status:  "401",
+				Detail:  "Invalid bearer token",
+			})
+			return
+		}
+
+		c.Set("scimTenant", &tenants[match])
+		c.Next()
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/config"
+)
+
+// RequireServiceClient accepts other services by their HTTP Basic client
+// credentials and exposes the client id as serviceClient in the gin context.
+func RequireServiceClient(clients []config.ServiceClient) gin.HandlerFunc {
+	// Compare digests so the time taken does not depend on the secret length
+	digests := make([][32]byte, len(clients))
+	for i, cl := range clients {
+		digests[i] = sha256.Sum256([]byte(cl.ID + ":" + cl.Secret))
+	}
+
+	return func(c *gin.Context) {
+		id, secret, ok := c.Request.BasicAuth()
+		presented := sha256.Sum256([]byte(id + ":" + secret))
+
+		match := -1
+		for i := range digests {
+			if subtle.ConstantTimeCompare(presented[:], digests[i][:]) == 1 {
+				match = i
+			}
+		}
+		if !ok || match < 0 {
+			c.Header("WWW-Authenticate", `Basic realm="auth-service"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+			return
+		}
+
+		c.Set("serviceClient", clients[match].ID)
+		c.Next()
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+type ListUsersRequest struct {
+	Query    string `form:"q"` // Matches username or email
+	Role     string `form:"role"`
+	Page     int    `form:"page,default=1" binding:"min=1"`
+	PageSize int    `form:"page_size,default=20" binding:"min=1,max=100"`
+}
+
+type UserPage struct {
+	Users    []User `json:"users"`
+	Total    int    `json:"total"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"page_size"`
+}
+
+type ChangeRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// ImpersonateRequest records why support needs to act as the user.
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+// ImpersonationResponse has no refresh token; the admin starts over once
+// the access token expires.
+type ImpersonationResponse struct {
+	Token     string `json:"token"`
+	ExpiresIn int64  `json:"expires_in"`
+	User      User   `json:"user"`
+}
+
+// DeleteUserRequest skips the grace period when Immediate is set.
+type DeleteUserRequest struct {
+	Immediate bool `json:"immediate"`
+}
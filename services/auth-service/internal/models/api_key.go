@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Scopes an API key can be granted. JWT sessions are not scoped.
+var ApiKeyScopes = []string{"links:read", "links:write", "links:delete"}
+
+type ApiKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // First characters of the key, to tell keys apart
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type CreateApiKeyRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required"`
+	ExpiresInDays int      `json:"expires_in_days"` // 0 means the key never expires
+}
+
+type CreateApiKeyResponse struct {
+	Key    string `json:"key"` // Only returned once, at creation
+	ApiKey ApiKey `json:"api_key"`
+}
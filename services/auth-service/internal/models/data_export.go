@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Data export states
+const (
+	ExportPending = "pending" // Waiting for link-management's part
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// DataExport is a ZIP archive of everything stored about a user.
+type DataExport struct {
+	ID          string     `json:"id"`
+	UserID      int        `json:"-"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	Size        int64      `json:"size,omitempty"` // Archive size in bytes once ready
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // The archive is deleted after this
+}
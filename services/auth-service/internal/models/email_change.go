@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// EmailChange is a pending switch to a new address. It completes once both
+// the current and the new address have confirmed.
+type EmailChange struct {
+	ID             int
+	UserID         int
+	NewEmail       string
+	OldTokenHash   string
+	NewTokenHash   string
+	OldConfirmedAt *time.Time
+	NewConfirmedAt *time.Time
+	ExpiresAt      time.Time
+	CompletedAt    *time.Time
+	CreatedAt      time.Time
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
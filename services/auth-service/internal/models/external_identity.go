@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// ExternalIdentity links an account at an OpenID Connect provider to a local user.
+type ExternalIdentity struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"-"`
+	Provider    string     `json:"provider"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// OIDCState is kept between redirecting to the provider and its callback.
+// UserID is set when a signed-in user is linking a new identity.
+type OIDCState struct {
+	StateHash    string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	UserID       *int
+	ExpiresAt    time.Time
+}
+
+type OIDCAuthorizeResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// OIDCCallbackRequest carries what the provider sent to the frontend redirect URL.
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// OIDCResult is either a login, which may still need the second factor, or,
+// for a link flow, the newly linked identity.
+type OIDCResult struct {
+	Login  *LoginResult
+	Linked *ExternalIdentity
+}
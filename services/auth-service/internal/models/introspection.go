@@ -0,0 +1,28 @@
+package models
+
+// IntrospectRequest is RFC 7662's form; the hint is accepted and ignored
+// since the token format says what it is.
+type IntrospectRequest struct {
+	Token         string `form:"token" json:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint" json:"token_type_hint"`
+}
+
+// Introspection describes a token (RFC 7662). Inactive tokens only carry
+// Active, so callers learn nothing about why a token was rejected.
+type Introspection struct {
+	Active      bool     `json:"active"`
+	TokenType   string   `json:"token_type,omitempty"` // "access_token" or "api_key"
+	Sub         string   `json:"sub,omitempty"`
+	Username    string   `json:"username,omitempty"`
+	Role        string   `json:"role,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	// Scope lists an API key's scopes, space separated; session tokens are
+	// not scoped and leave it empty
+	Scope string              `json:"scope,omitempty"`
+	Exp   int64               `json:"exp,omitempty"` // Unix time; API keys without an expiry leave it out
+	Act   *IntrospectionActor `json:"act,omitempty"` // The admin impersonating the user
+}
+
+type IntrospectionActor struct {
+	Sub string `json:"sub"`
+}
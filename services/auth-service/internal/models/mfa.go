@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// MFAChallenge is returned by login instead of tokens when 2FA is enabled.
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// LoginResult carries either a token pair or an MFA challenge.
+type LoginResult struct {
+	Tokens    *TokenPair
+	User      *User
+	Challenge *MFAChallenge
+}
+
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"` // Render as a QR code for authenticator apps
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTOTPRequest struct {
+	Password     string `json:"password" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type RecoveryCode struct {
+	ID        int
+	UserID    int
+	CodeHash  string
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
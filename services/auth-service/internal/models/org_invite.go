@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// OrgInvite lets one specific person join an organization. It is bound to a
+// username or an email address and can be accepted once.
+type OrgInvite struct {
+	ID         int        `json:"id"`
+	OrgID      int        `json:"org_id"`
+	Role       string     `json:"role"`
+	Username   string     `json:"username,omitempty"`
+	Email      string     `json:"email,omitempty"`
+	TokenHash  string     `json:"-"`
+	InvitedBy  int        `json:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreateInviteRequest struct {
+	Username       string `json:"username"`
+	Email          string `json:"email" binding:"omitempty,email"`
+	Role           string `json:"role" binding:"required"`
+	ExpiresInHours int    `json:"expires_in_hours" binding:"omitempty,min=1,max=720"` // Defaults to ORG_INVITE_TTL
+}
+
+type CreateInviteResponse struct {
+	Token  string    `json:"token,omitempty"` // Only returned once, for username invites; email invites are only emailed
+	Invite OrgInvite `json:"invite"`
+}
+
+type AcceptInviteRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RegisterInviteRequest creates an account and accepts the invite in one step.
+// Email invites set the account's email to the (now verified) invited address.
+type RegisterInviteRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	// InviteCode is an admin's sign-up code, needed on top of the org invite
+	// in invite mode, and in domain mode unless the invite went to an
+	// allowed domain
+	InviteCode string `json:"invite_code"`
+}
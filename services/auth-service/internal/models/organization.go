@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Membership roles, from least to most privileged
+const (
+	OrgRoleViewer = "viewer" // Sees the organization's links
+	OrgRoleEditor = "editor" // Creates, edits and deletes the organization's links
+	OrgRoleAdmin  = "admin"  // Manages members and settings
+	OrgRoleOwner  = "owner"  // Everything, including managing admins and owners
+)
+
+var orgRoleRank = map[string]int{OrgRoleViewer: 1, OrgRoleEditor: 2, OrgRoleAdmin: 3, OrgRoleOwner: 4}
+
+func ValidOrgRole(role string) bool {
+	return orgRoleRank[role] > 0
+}
+
+// OrgRoleAtLeast reports whether role grants everything min does.
+func OrgRoleAtLeast(role, min string) bool {
+	return orgRoleRank[role] >= orgRoleRank[min] && orgRoleRank[min] > 0
+}
+
+type Organization struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Role      string    `json:"role,omitempty"` // The caller's membership role
+}
+
+type OrgMember struct {
+	OrgID     int       `json:"-"`
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OrgRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// Registration modes
+const (
+	RegistrationOpen   = "open"   // Anyone can sign up
+	RegistrationInvite = "invite" // An invite code is required
+	RegistrationClosed = "closed" // Nobody can sign up; admins, SSO and SCIM still create accounts
+	RegistrationDomain = "domain" // The email must be in an allowed domain, or an invite code is given
+)
+
+func ValidRegistrationMode(mode string) bool {
+	switch mode {
+	case RegistrationOpen, RegistrationInvite, RegistrationClosed, RegistrationDomain:
+		return true
+	}
+	return false
+}
+
+// RegistrationSettings decide who may sign up. Until an admin changes them
+// they come from REGISTRATION_MODE and REGISTRATION_ALLOWED_DOMAINS.
+type RegistrationSettings struct {
+	Mode           string     `json:"mode"`
+	AllowedDomains []string   `json:"allowed_domains"`
+	UpdatedBy      *int       `json:"updated_by,omitempty"`
+	UpdatedAt      *time.Time `json:"updated_at,omitempty"`
+}
+
+type UpdateRegistrationRequest struct {
+	Mode           string   `json:"mode" binding:"required"`
+	AllowedDomains []string `json:"allowed_domains"`
+}
+
+// RegistrationInfo is what the sign-up form needs to know.
+type RegistrationInfo struct {
+	Mode               string `json:"mode"`
+	InviteCodeRequired bool   `json:"invite_code_required"`
+}
+
+// InviteCode admits a limited number of sign-ups until it expires. Only the
+// SHA-256 hash of the code is stored.
+type InviteCode struct {
+	ID        int        `json:"id"`
+	Note      string     `json:"note,omitempty"`
+	CodeHash  string     `json:"-"`
+	MaxUses   int        `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	CreatedBy int        `json:"created_by"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Usable reports whether the code can admit another sign-up.
+func (c *InviteCode) Usable(now time.Time) bool {
+	return c.RevokedAt == nil && c.Uses < c.MaxUses && now.Before(c.ExpiresAt)
+}
+
+type CreateInviteCodeRequest struct {
+	Note           string `json:"note" binding:"max=255"`
+	MaxUses        int    `json:"max_uses" binding:"omitempty,min=1,max=10000"`        // Defaults to 1
+	ExpiresInHours int    `json:"expires_in_hours" binding:"omitempty,min=1,max=8760"` // Defaults to INVITE_CODE_TTL
+}
+
+type CreateInviteCodeResponse struct {
+	Code       string     `json:"code"` // Only returned once
+	InviteCode InviteCode `json:"invite_code"`
+}
@@ -0,0 +1,27 @@
+package models
+
+// Permissions checked by auth-service. Link permissions are checked by
+// link-management but are granted to roles here, so all of them live in the
+// Permissions table.
+const (
+	PermUsersManage = "users:manage"
+	PermRolesManage = "roles:manage"
+	PermKeysManage  = "keys:manage"
+)
+
+// Role is a named set of permissions. Users.Role refers to Roles.Name.
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type PutRoleRequest struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
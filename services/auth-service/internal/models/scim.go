@@ -0,0 +1,111 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ScimUserSchema  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	ScimGroupSchema = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ScimListSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	ScimPatchSchema = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ScimErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// ScimUser is a local account provisioned by a SCIM tenant.
+type ScimUser struct {
+	User
+	ExternalID string
+}
+
+type ScimGroup struct {
+	ID          int
+	DisplayName string
+	ExternalID  string
+	Members     []ScimMember
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type ScimMember struct {
+	UserID   int
+	Username string
+}
+
+// ScimFilter is a single `attribute eq "value"` comparison, the only filter
+// identity providers send when looking up accounts.
+type ScimFilter struct {
+	Attribute string // Lower case, e.g. "username" or "emails.value"
+	Value     string
+}
+
+type ScimListRequest struct {
+	Filter     string `form:"filter"`
+	StartIndex int    `form:"startIndex,default=1"` // 1-based
+	Count      int    `form:"count,default=100"`
+}
+
+type ScimMeta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      time.Time  `json:"created"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location"`
+}
+
+type ScimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type ScimUserResource struct {
+	Schemas    []string    `json:"schemas"`
+	ID         string      `json:"id,omitempty"`
+	ExternalID string      `json:"externalId,omitempty"`
+	UserName   string      `json:"userName" binding:"required,max=50"`
+	Active     *bool       `json:"active,omitempty"`
+	Emails     []ScimEmail `json:"emails,omitempty"`
+	Password   string      `json:"password,omitempty"` // Write only; accounts without one sign in via SSO or LDAP
+	Meta       *ScimMeta   `json:"meta,omitempty"`
+}
+
+type ScimMemberRef struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+type ScimGroupResource struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id,omitempty"`
+	ExternalID  string          `json:"externalId,omitempty"`
+	DisplayName string          `json:"displayName" binding:"required,max=255"`
+	Members     []ScimMemberRef `json:"members"`
+	Meta        *ScimMeta       `json:"meta,omitempty"`
+}
+
+type ScimListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+type ScimPatchOperation struct {
+	Op    string          `json:"op" binding:"required"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+type ScimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []ScimPatchOperation `json:"Operations" binding:"required,min=1,dive"`
+}
+
+type ScimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
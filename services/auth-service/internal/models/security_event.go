@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// Security event types
+const (
+	EventLogin              = "login"
+	EventLoginMFA           = "login.mfa"
+	EventLoginOIDC          = "login.oidc"
+	EventLogout             = "logout"
+	EventRegister           = "register"
+	EventPasswordChange     = "password.change"
+	EventPasswordReset      = "password.reset"
+	EventEmailChange        = "email.change"
+	EventMFAEnable          = "2fa.enable"
+	EventMFADisable         = "2fa.disable"
+	EventMFAReset           = "2fa.reset"
+	EventRoleChange         = "role.change"
+	EventAccountDisable     = "account.disable"
+	EventAccountEnable      = "account.enable"
+	EventSessionsRevoke     = "sessions.revoke"
+	EventSessionRevoke      = "session.revoke"
+	EventUsernameChange     = "username.change"
+	EventIdentityLink       = "identity.link"
+	EventIdentityUnlink     = "identity.unlink"
+	EventAPIKeyCreate       = "api_key.create"
+	EventAPIKeyRevoke       = "api_key.revoke"
+	EventAccountProvision   = "account.provision"
+	EventDeletionRequest    = "account.deletion_request"
+	EventDeletionCancel     = "account.deletion_cancel"
+	EventAccountDelete      = "account.delete"
+	EventDataExport         = "data_export.request"
+	EventDataExportDownload = "data_export.download"
+	EventImpersonation      = "impersonation.start"
+)
+
+// Outcomes
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeLocked  = "locked"  // Rejected by the lockout before the password was checked
+	OutcomeDenied  = "denied"  // Correct credentials but the account may not sign in
+	OutcomePending = "pending" // Password accepted, second factor still required
+)
+
+// SecurityEvent is one row of the append-only security log.
+type SecurityEvent struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	Outcome   string    `json:"outcome"`
+	UserID    *int      `json:"user_id,omitempty"`
+	Username  string    `json:"username,omitempty"` // As typed, for failures against unknown accounts
+	ActorID   *int      `json:"actor_id,omitempty"` // Set when an admin acted on someone else's account
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SecurityEventQuery struct {
+	UserID   int        `form:"user_id"` // Admin queries only
+	Type     string     `form:"type"`
+	Outcome  string     `form:"outcome"`
+	IP       string     `form:"ip"`
+	Since    *time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until    *time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	Page     int        `form:"page,default=1" binding:"min=1"`
+	PageSize int        `form:"page_size,default=50" binding:"min=1,max=200"`
+}
+
+type SecurityEventPage struct {
+	Events   []SecurityEvent `json:"events"`
+	Total    int             `json:"total"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"page_size"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Session groups every refresh token issued from a single login (a token family).
+// Revoking the session invalidates all of its refresh tokens and access tokens.
+type Session struct {
+	ID             string     `json:"id"`
+	UserID         int        `json:"-"`
+	ImpersonatorID *int       `json:"impersonator_id,omitempty"` // The admin acting as the user
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+}
+
+// SessionInfo is a signed-in device as shown to its owner.
+type SessionInfo struct {
+	ID         string     `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"` // Last token refresh
+	Current    bool       `json:"current"`
+	// ImpersonatedBy is the admin who opened the session to act as the user
+	ImpersonatedBy *int `json:"impersonated_by,omitempty"`
+}
+
+type RefreshToken struct {
+	ID        int
+	SessionID string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+}
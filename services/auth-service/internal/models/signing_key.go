@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+type SigningKeyRecord struct {
+	Kid         string     `json:"kid"`
+	PrivateKey  string     `json:"-"` // PKCS#8 PEM sealed with the key encryption key, never returned by the API
+	ActivatesAt time.Time  `json:"activates_at"`
+	RetiresAt   *time.Time `json:"retires_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type RotateKeyRequest struct {
+	// Immediate also retires every other key right away, logging out all
+	// users. Use it when a key may have been compromised.
+	Immediate bool `json:"immediate"`
+}
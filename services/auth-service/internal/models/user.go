@@ -3,16 +3,65 @@ package models
 import "time"
 
 type User struct {
-	ID           int       `json:"id"`
-	Username     string    `json:"username"`
-	PasswordHash string    `json:"-"` // Don't return password hash in JSON
-	Role         string    `json:"role"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID            int        `json:"id"`
+	Username      string     `json:"username"`
+	DisplayName   string     `json:"display_name,omitempty"`
+	PasswordHash  string     `json:"-"` // Don't return password hash in JSON
+	Role          string     `json:"role"`
+	Email         string     `json:"email,omitempty"`
+	EmailVerified bool       `json:"email_verified"`
+	TOTPEnabled   bool       `json:"totp_enabled"`
+	TOTPSecret    string     `json:"-"` // Sealed with the key encryption key; set (but not yet enabled) while enrollment is pending
+	TOTPLastStep  int64      `json:"-"` // Last accepted time step, so a code cannot be replayed
+	DisabledAt    *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	DirectoryID   string     `json:"-"` // Stable ID of the LDAP entry for directory accounts, "" for local ones
+	Source        string     `json:"-"` // How the account was created, one of the Source constants
+	// AdmittedByDomain accounts signed up in domain mode on the strength of
+	// their email address, which they must verify before signing in
+	AdmittedByDomain bool `json:"-"`
+
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"` // Deleted at this time unless cancelled
+	DeletedAt           *time.Time `json:"deleted_at,omitempty"`            // Only an anonymized row is left
+}
+
+// How an account was created
+const (
+	SourceLocal     = "local" // Registered with a password
+	SourceDirectory = "directory"
+	SourceOIDC      = "oidc"
+	SourceSCIM      = "scim"
+)
+
+// Disabled accounts cannot log in and their sessions are revoked.
+func (u *User) Disabled() bool {
+	return u.DisabledAt != nil
+}
+
+// FromDirectory reports whether the account belongs to a directory entry.
+// Such accounts sign in through the directory only.
+func (u *User) FromDirectory() bool {
+	return u.DirectoryID != ""
+}
+
+// AwaitingVerification reports whether the account cannot sign in until its
+// email address is verified.
+func (u *User) AwaitingVerification() bool {
+	return u.AdmittedByDomain && !u.EmailVerified
+}
+
+// Deleted accounts keep their ID but nothing else about the person.
+func (u *User) Deleted() bool {
+	return u.DeletedAt != nil
 }
 
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
+	Email    string `json:"email" binding:"omitempty,email"`
+	// InviteCode is required in invite mode and lets people outside the
+	// allowed domains in in domain mode
+	InviteCode string `json:"invite_code"`
 }
 
 type LoginRequest struct {
@@ -20,7 +69,29 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// UpdateProfileRequest changes only the fields that are present.
+type UpdateProfileRequest struct {
+	Username    *string `json:"username" binding:"omitempty,min=3,max=50"`
+	DisplayName *string `json:"display_name" binding:"omitempty,max=100"`
+}
+
+// ProfileResponse carries new tokens when the change re-issued them.
+type ProfileResponse struct {
+	User         User   `json:"user"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+}
+
+// DeleteAccountRequest confirms a self-service deletion with the password.
+// Accounts without one confirm by signing in again just before instead.
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // Access token lifetime in seconds
+	User         User   `json:"user"`
 }
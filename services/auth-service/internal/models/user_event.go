@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// User event types published to the UserEvents outbox
+const (
+	UserEventDeleted = "user.deleted"
+)
+
+// UserEvent tells other services that something happened to an account.
+type UserEvent struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	UserID    int       `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Purposes for single-use tokens sent to users
+const (
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeEmailVerify   = "email_verify"
+	TokenPurposeMFAChallenge  = "mfa_challenge"
+)
+
+// UserToken is a single-use, expiring token delivered out of band.
+// Only its SHA-256 hash is stored.
+type UserToken struct {
+	ID        int
+	UserID    int
+	Purpose   string
+	TokenHash string
+	Data      string // Purpose specific, e.g. the address being verified
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers account messages such as password reset links.
+type Notifier interface {
+	Send(msg Message) error
+}
+
+// FileNotifier appends messages to a local file instead of sending them.
+// It stands in for a mail server during local development.
+type FileNotifier struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (n *FileNotifier) Send(msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open outbox: %w", err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n---\n",
+		time.Now().UTC().Format(time.RFC1123Z), msg.To, msg.Subject, msg.Body)
+	return err
+}
+
+type SMTPNotifier struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (n *SMTPNotifier) Send(msg Message) error {
+	var auth smtp.Auth
+	if n.Username != "" {
+		auth = smtp.PlainAuth("", n.Username, n.Password, n.Host)
+	}
+
+	body := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		n.From, msg.To, msg.Subject, msg.Body)
+	if err := smtp.SendMail(n.Host+":"+n.Port, auth, n.From, []string{msg.To}, []byte(body)); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
+
+// New picks a notifier by name ("smtp" or "file").
+func New(kind, outboxPath string, smtpNotifier *SMTPNotifier) Notifier {
+	if kind == "smtp" {
+		return smtpNotifier
+	}
+	if kind != "file" {
+		log.Printf("Unknown notifier %q, falling back to file outbox", kind)
+	}
+	return &FileNotifier{Path: outboxPath}
+}
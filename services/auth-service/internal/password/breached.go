@@ -0,0 +1,103 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// BreachedList looks passwords up in a local copy of a breached password
+// corpus such as Have I Been Pwned's. The file is what the k-anonymity range
+// API serves, every 5 character SHA-1 prefix's range concatenated in order
+// with the prefix put back in front: one "HASH:COUNT" line per password,
+// sorted by upper case hex hash. That is the single file format of the
+// pwned-passwords downloader. Lookups binary search the file on disk, so
+// it is never loaded into memory and the plain passwords never leave the
+// process.
+type BreachedList struct {
+	file *os.File
+	size int64
+}
+
+// OpenBreachedList opens the hash file at path.
+func OpenBreachedList(path string) (*BreachedList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &BreachedList{file: f, size: info.Size()}, nil
+}
+
+// Contains reports whether password appears in the list.
+func (b *BreachedList) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	target := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	// lo is always the start of a line; lines starting at or after hi sort
+	// after the target
+	lo, hi := int64(0), b.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, err := b.lineStart(mid)
+		if err != nil {
+			return false, err
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+		line, next, err := b.readLine(start)
+		if err != nil {
+			return false, err
+		}
+		hash := line
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			hash = line[:i]
+		}
+		switch cmp := strings.Compare(strings.ToUpper(hash), target); {
+		case cmp == 0:
+			return true, nil
+		case cmp < 0:
+			lo = next
+		default:
+			hi = mid
+		}
+	}
+	return false, nil
+}
+
+// lineStart returns the offset of the first line starting at or after off.
+func (b *BreachedList) lineStart(off int64) (int64, error) {
+	if off == 0 {
+		return 0, nil
+	}
+	r := bufio.NewReader(io.NewSectionReader(b.file, off-1, b.size-off+1))
+	skipped, err := r.ReadString('\n')
+	if err == io.EOF {
+		return b.size, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+	return off - 1 + int64(len(skipped)), nil
+}
+
+// readLine returns the line at off without its line ending and the offset
+// of the next line.
+func (b *BreachedList) readLine(off int64) (string, int64, error) {
+	r := bufio.NewReader(io.NewSectionReader(b.file, off, b.size-off))
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", 0, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), off + int64(len(line)), nil
+}
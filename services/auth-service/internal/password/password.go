@@ -0,0 +1,169 @@
+// Package password hashes, verifies and vets passwords. Hashes are
+// self-describing: argon2id hashes use the PHC string format
+// ($argon2id$v=19$m=19456,t=2,p=1$salt$hash) and bcrypt hashes their usual
+// $2a$ form, so the algorithm and parameters used for each stored hash are
+// known and outdated ones can be upgraded on the next login. New passwords
+// are checked against a Policy.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithms new hashes can be created with
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+const (
+	saltLength = 16
+	keyLength  = 32
+)
+
+// ErrUnknownFormat is returned for a stored hash this package cannot read.
+var ErrUnknownFormat = errors.New("unknown password hash format")
+
+var encoding = base64.RawStdEncoding
+
+// Params selects the algorithm for new hashes and its cost.
+type Params struct {
+	Algorithm   string
+	Memory      uint32 // argon2id memory in KiB
+	Iterations  uint32 // argon2id passes over the memory
+	Parallelism uint8  // argon2id lanes
+	BcryptCost  int
+}
+
+// Validate rejects settings new hashes cannot be created with.
+func (p Params) Validate() error {
+	switch p.Algorithm {
+	case Argon2id:
+		if p.Iterations < 1 || p.Parallelism < 1 || p.Memory < 8*uint32(p.Parallelism) {
+			return fmt.Errorf("argon2id needs at least 1 iteration, 1 lane and 8 KiB of memory per lane")
+		}
+	case Bcrypt:
+		if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return fmt.Errorf("unsupported password hash algorithm %q", p.Algorithm)
+	}
+	return nil
+}
+
+// Hasher creates hashes with its Params and verifies hashes made with any
+// supported algorithm and parameters.
+type Hasher struct {
+	Params Params
+}
+
+func NewHasher(params Params) *Hasher {
+	return &Hasher{Params: params}
+}
+
+// Hash returns the encoded hash of password.
+func (h *Hasher) Hash(password string) (string, error) {
+	switch h.Params.Algorithm {
+	case Argon2id:
+		salt := make([]byte, saltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		p := argon2Params{Memory: h.Params.Memory, Iterations: h.Params.Iterations, Parallelism: h.Params.Parallelism}
+		key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, keyLength)
+		return p.encode(salt, key), nil
+	case Bcrypt:
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.Params.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hashed), nil
+	default:
+		return "", fmt.Errorf("unsupported password hash algorithm %q", h.Params.Algorithm)
+	}
+}
+
+// Verify reports whether password matches the encoded hash. It only returns
+// an error if the hash cannot be read.
+func (h *Hasher) Verify(encoded, password string) (bool, error) {
+	if isBcrypt(encoded) {
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	p, salt, key, err := decodeArgon2(encoded)
+	if err != nil {
+		return false, err
+	}
+	actual := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(actual, key) == 1, nil
+}
+
+// NeedsRehash reports whether the hash was made with another algorithm or
+// other parameters than new hashes would be.
+func (h *Hasher) NeedsRehash(encoded string) bool {
+	switch h.Params.Algorithm {
+	case Argon2id:
+		p, _, _, err := decodeArgon2(encoded)
+		return err != nil || p.Memory != h.Params.Memory || p.Iterations != h.Params.Iterations || p.Parallelism != h.Params.Parallelism
+	case Bcrypt:
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost != h.Params.BcryptCost
+	default:
+		return false
+	}
+}
+
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+type argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+func (p argon2Params) encode(salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism, encoding.EncodeToString(salt), encoding.EncodeToString(key))
+}
+
+func decodeArgon2(encoded string) (argon2Params, []byte, []byte, error) {
+	var p argon2Params
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != Argon2id {
+		return p, nil, nil, ErrUnknownFormat
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrUnknownFormat
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrUnknownFormat
+	}
+	if p.Iterations == 0 || p.Parallelism == 0 {
+		return p, nil, nil, ErrUnknownFormat
+	}
+	salt, err := encoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrUnknownFormat
+	}
+	key, err := encoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrUnknownFormat
+	}
+	return p, salt, key, nil
+}
@@ -0,0 +1,94 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rules a password can break, used as keys in PolicyError
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleStrength  = "strength"
+	RuleUsername  = "username"
+	RuleBreached  = "breached"
+)
+
+// Policy decides which new passwords are accepted.
+type Policy struct {
+	MinLength int
+	MaxLength int
+	MinScore  int // Lowest accepted Strength, 0 turns the check off
+	// RejectUsername refuses passwords that contain the username, forwards
+	// or backwards
+	RejectUsername bool
+	Breached       *BreachedList // nil turns the check off
+}
+
+// Violation is one broken rule with a message for the user.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule a password broke, so they can all be fixed
+// in one go.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	rules := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		rules[i] = v.Rule
+	}
+	return "password rejected: " + strings.Join(rules, ", ")
+}
+
+// Check returns a *PolicyError if the password breaks any rule. Other errors
+// come from reading the breached password list. Passwords over MaxLength are
+// rejected before any other rule, since scoring one grows much faster than
+// its length.
+func (p *Policy) Check(password, username string) error {
+	length := utf8.RuneCountInString(password)
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return &PolicyError{Violations: []Violation{{RuleMaxLength, fmt.Sprintf("Must be at most %d characters", p.MaxLength)}}}
+	}
+	var violations []Violation
+	if length < p.MinLength {
+		violations = append(violations, Violation{RuleMinLength, fmt.Sprintf("Must be at least %d characters", p.MinLength)})
+	}
+	if p.RejectUsername && containsUsername(password, username) {
+		violations = append(violations, Violation{RuleUsername, "Must not contain your username"})
+	}
+	if p.MinScore > 0 && Strength(password, username) < p.MinScore {
+		violations = append(violations, Violation{RuleStrength, "Too easy to guess, add more words or avoid common patterns"})
+	}
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			violations = append(violations, Violation{RuleBreached, "This password has appeared in a data breach, choose another one"})
+		}
+	}
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+func containsUsername(password, username string) bool {
+	if utf8.RuneCountInString(username) < 3 {
+		return false
+	}
+	pw := strings.ToLower(password)
+	name := []rune(strings.ToLower(username))
+	reversed := make([]rune, len(name))
+	for i, r := range name {
+		reversed[len(name)-1-i] = r
+	}
+	return strings.Contains(pw, string(name)) || strings.Contains(pw, string(reversed))
+}
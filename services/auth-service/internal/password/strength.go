@@ -0,0 +1,230 @@
+package password
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Strength scores a password from 0 (trivial to guess) to 4 (very hard) the
+// way zxcvbn does: it splits the password into the cheapest sequence of
+// patterns an attacker would try (common passwords and words, with l33t
+// substitutions, keyboard walks, sequences, repeats, years and the user's
+// own details), sums their guess counts on a log scale and maps the total onto
+// zxcvbn's thresholds of 10^3, 10^6, 10^8 and 10^10 guesses.
+func Strength(password string, userInputs ...string) int {
+	guesses := log10Guesses(password, userInputs)
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// log10Guesses finds the cheapest way to cover the password with patterns,
+// falling back to guessing single characters by brute force.
+func log10Guesses(password string, userInputs []string) float64 {
+	runes := []rune(password)
+	n := len(runes)
+	if n == 0 {
+		return 0
+	}
+	lower := []rune(strings.ToLower(password))
+	plain := []rune(unleet(string(lower)))
+	words := dictionary(userInputs)
+	perChar := math.Log10(float64(cardinality(runes)))
+
+	// best[i] is the fewest guesses (log10) needed for the first i characters
+	best := make([]float64, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = math.Inf(1)
+	}
+	for i := 0; i < n; i++ {
+		best[i+1] = math.Min(best[i+1], best[i]+perChar)
+		for j := i + 3; j <= n; j++ {
+			cost, ok := patternGuesses(runes[i:j], lower[i:j], plain[i:j], words)
+			if ok && best[i]+cost < best[j] {
+				best[j] = best[i] + cost
+			}
+		}
+	}
+	return best[n]
+}
+
+// patternGuesses returns the log10 guesses for a segment matching a pattern.
+func patternGuesses(orig, lower, plain []rune, words map[string]int) (float64, bool) {
+	costs := []float64{}
+	if rank, ok := words[string(lower)]; ok {
+		costs = append(costs, math.Log10(float64(rank))+variations(orig, lower, plain))
+	} else if rank, ok := words[string(plain)]; ok {
+		costs = append(costs, math.Log10(float64(rank))+variations(orig, lower, plain))
+	}
+	if isRepeat(lower) {
+		costs = append(costs, math.Log10(float64(cardinality(orig[:1])*len(orig))))
+	}
+	if isSequence(lower) {
+		costs = append(costs, math.Log10(float64(4*len(orig))))
+	}
+	if isKeyboardWalk(lower) {
+		costs = append(costs, math.Log10(float64(40*len(orig))))
+	}
+	if isYear(lower) {
+		costs = append(costs, math.Log10(200))
+	}
+	if len(costs) == 0 {
+		return 0, false
+	}
+	min := costs[0]
+	for _, c := range costs[1:] {
+		min = math.Min(min, c)
+	}
+	return min, true
+}
+
+// variations adds the cost of guessing capitalisation and l33t substitutions.
+func variations(orig, lower, plain []rune) float64 {
+	extra := 0.0
+	if string(orig) != string(lower) {
+		extra += math.Log10(2)
+	}
+	if string(lower) != string(plain) {
+		extra += math.Log10(2)
+	}
+	return extra
+}
+
+func cardinality(runes []rune) int {
+	var lower, upper, digit, other bool
+	for _, r := range runes {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	n := 0
+	if lower {
+		n += 26
+	}
+	if upper {
+		n += 26
+	}
+	if digit {
+		n += 10
+	}
+	if other {
+		n += 33
+	}
+	return n
+}
+
+func isRepeat(s []rune) bool {
+	for _, r := range s[1:] {
+		if r != s[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// isSequence matches runs like "abcd", "4321" or "aceg".
+func isSequence(s []rune) bool {
+	step := s[1] - s[0]
+	if step == 0 || step > 2 || step < -2 {
+		return false
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i]-s[i-1] != step {
+			return false
+		}
+	}
+	return true
+}
+
+// isYear matches 1900 to 2099, which people like to append to words.
+func isYear(s []rune) bool {
+	if len(s) != 4 || !(s[0] == '1' && s[1] == '9' || s[0] == '2' && s[1] == '0') {
+		return false
+	}
+	return unicode.IsDigit(s[2]) && unicode.IsDigit(s[3])
+}
+
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./", "1qaz2wsx3edc4rfv5tgb6yhn7ujm8ik,9ol.0p;/"}
+
+// isKeyboardWalk matches runs along a row of a US keyboard in either
+// direction, plus the common diagonal walk.
+func isKeyboardWalk(s []rune) bool {
+	walk := string(s)
+	reversed := []rune(walk)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+	for _, row := range keyboardRows {
+		if strings.Contains(row, walk) || strings.Contains(row, string(reversed)) {
+			return true
+		}
+	}
+	return false
+}
+
+var leet = strings.NewReplacer("4", "a", "@", "a", "8", "b", "(", "c", "3", "e", "6", "g", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t", "+", "t", "2", "z")
+
+func unleet(s string) string {
+	return leet.Replace(s)
+}
+
+// commonPasswords are ranked by how often they show up in breaches; the rank
+// is the number of guesses an attacker needs to reach them.
+var commonPasswords = strings.Fields(`
+	password 123456 qwerty letmein welcome admin login abc123 monkey dragon
+	master sunshine princess football baseball iloveyou trustno1 shadow superman
+	michael jennifer hunter ranger buster soccer harley batman andrew tigger
+	charlie robert thomas hockey daniel starwars george computer
+	michelle jessica pepper freedom whatever secret summer winter spring autumn
+	hello azerty access flower cheese ginger mustang killer matrix passw0rd
+	changeme default guest user test qwertz zaq1 loveme nicole lovely
+	ashley purple orange banana chocolate cookie maggie jordan taylor samsung
+	internet service server shortener link links url company business office
+	january february march april june july august september october november december
+	monday tuesday wednesday thursday friday saturday sunday
+`)
+
+// dictionary ranks the user's own details first since they are the first
+// thing a targeted attacker tries.
+func dictionary(userInputs []string) map[string]int {
+	words := make(map[string]int, len(commonPasswords)+len(userInputs))
+	rank := 1
+	for _, input := range userInputs {
+		input = strings.ToLower(input)
+		if len(input) >= 3 {
+			words[input] = rank
+		}
+		for _, part := range strings.FieldsFunc(input, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len(part) >= 3 {
+				if _, ok := words[part]; !ok {
+					words[part] = rank
+				}
+			}
+		}
+	}
+	for i, w := range commonPasswords {
+		if _, ok := words[w]; !ok {
+			words[w] = rank + i + 1
+		}
+	}
+	return words
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+type ApiKeyRepository struct {
+	DB *sql.DB
+}
+
+func NewApiKeyRepository(db *sql.DB) *ApiKeyRepository {
+	return &ApiKeyRepository{DB: db}
+}
+
+func (r *ApiKeyRepository) CreateApiKey(key *models.ApiKey) error {
+	query := `
+		INSERT INTO ApiKeys (UserID, Name, Prefix, KeyHash, Scopes, ExpiresAt)
+		OUTPUT INSERTED.ID, INSERTED.CreatedAt
+		VALUES (@p1, @p2, @p3, @p4, @p5, @p6)
+	`
+	err := r.DB.QueryRow(query, key.UserID, key.Name, key.Prefix, key.KeyHash, strings.Join(key.Scopes, ","), key.ExpiresAt).
+		Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+	return nil
+}
+
+func (r *ApiKeyRepository) GetApiKeysByUserID(userID int) ([]models.ApiKey, error) {
+	query := `
+		SELECT ID, UserID, Name, Prefix, Scopes, CreatedAt, ExpiresAt, LastUsedAt, RevokedAt
+		FROM ApiKeys
+		WHERE UserID = @p1
+		ORDER BY CreatedAt DESC
+	`
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []models.ApiKey{}
+	for rows.Next() {
+		var k models.ApiKey
+		var scopes string
+		if err := rows.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &scopes, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt); err != nil {
+			return nil, err
+		}
+		k.Scopes = strings.Split(scopes, ",")
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *ApiKeyRepository) GetApiKeyByHash(hash string) (*models.ApiKey, error) {
+	query := `
+		SELECT ID, UserID, Name, Prefix, Scopes, CreatedAt, ExpiresAt, LastUsedAt, RevokedAt
+		FROM ApiKeys
+		WHERE KeyHash = @p1
+	`
+	var k models.ApiKey
+	var scopes string
+	err := r.DB.QueryRow(query, hash).Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &scopes, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	k.Scopes = strings.Split(scopes, ",")
+	return &k, nil
+}
+
+// RevokeApiKey reports whether a matching, not yet revoked key was found.
+func (r *ApiKeyRepository) RevokeApiKey(id, userID int) (bool, error) {
+	query := "UPDATE ApiKeys SET RevokedAt = GETUTCDATE() WHERE ID = @p1 AND UserID = @p2 AND RevokedAt IS NULL"
+	res, err := r.DB.Exec(query, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+type DataExportRepository struct {
+	DB *sql.DB
+}
+
+func NewDataExportRepository(db *sql.DB) *DataExportRepository {
+	return &DataExportRepository{DB: db}
+}
+
+// exportColumns must stay in sync with scanExport; the archive itself is only read by GetArchive
+const exportColumns = "ID, UserID, Status, Error, DATALENGTH(Archive), CreatedAt, CompletedAt, ExpiresAt"
+
+func scanExport(row rowScanner) (*models.DataExport, error) {
+	e := &models.DataExport{}
+	var errMsg sql.NullString
+	var size sql.NullInt64
+	if err := row.Scan(&e.ID, &e.UserID, &e.Status, &errMsg, &size, &e.CreatedAt, &e.CompletedAt, &e.ExpiresAt); err != nil {
+		return nil, err
+	}
+	e.Error = errMsg.String
+	e.Size = size.Int64
+	return e, nil
+}
+
+func (r *DataExportRepository) CreateExport(e *models.DataExport) error {
+	query := `
+		INSERT INTO DataExports (ID, UserID, Status)
+		OUTPUT INSERTED.CreatedAt
+		VALUES (@p1, @p2, @p3)
+	`
+	if err := r.DB.QueryRow(query, e.ID, e.UserID, e.Status).Scan(&e.CreatedAt); err != nil {
+		return fmt.Errorf("failed to create data export: %w", err)
+	}
+	return nil
+}
+
+func (r *DataExportRepository) GetExport(id string) (*models.DataExport, error) {
+	e, err := scanExport(r.DB.QueryRow("SELECT "+exportColumns+" FROM DataExports WHERE ID = @p1", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data export: %w", err)
+	}
+	return e, nil
+}
+
+// ListExports returns the user's exports, newest first.
+func (r *DataExportRepository) ListExports(userID int) ([]models.DataExport, error) {
+	return r.queryExports("WHERE UserID = @p1 ORDER BY CreatedAt DESC", userID)
+}
+
+func (r *DataExportRepository) ListPendingExports() ([]models.DataExport, error) {
+	return r.queryExports("WHERE Status = @p1 ORDER BY CreatedAt", models.ExportPending)
+}
+
+func (r *DataExportRepository) queryExports(where string, arg interface{}) ([]models.DataExport, error) {
+	rows, err := r.DB.Query("SELECT "+exportColumns+" FROM DataExports "+where, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list data exports: %w", err)
+	}
+	defer rows.Close()
+
+	exports := []models.DataExport{}
+	for rows.Next() {
+		e, err := scanExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, *e)
+	}
+	return exports, rows.Err()
+}
+
+func (r *DataExportRepository) GetArchive(id string) ([]byte, error) {
+	var archive []byte
+	err := r.DB.QueryRow("SELECT Archive FROM DataExports WHERE ID = @p1", id).Scan(&archive)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data export: %w", err)
+	}
+	return archive, nil
+}
+
+// GetLinkPart returns the files link-management contributed to the export as
+// a JSON object of file name to contents, or "" if it has not done so yet.
+func (r *DataExportRepository) GetLinkPart(id string) (string, error) {
+	var files string
+	err := r.DB.QueryRow("SELECT Files FROM LinkExports WHERE ExportID = @p1", id).Scan(&files)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read link export: %w", err)
+	}
+	return files, nil
+}
+
+// CompleteExport stores the archive of a pending export.
+func (r *DataExportRepository) CompleteExport(id string, archive []byte, expiresAt time.Time) error {
+	query := `
+		UPDATE DataExports SET Status = @p2, Archive = @p3, CompletedAt = GETUTCDATE(), ExpiresAt = @p4
+		WHERE ID = @p1 AND Status = @p5
+	`
+	if _, err := r.DB.Exec(query, id, models.ExportReady, archive, expiresAt, models.ExportPending); err != nil {
+		return fmt.Errorf("failed to complete data export: %w", err)
+	}
+	return nil
+}
+
+func (r *DataExportRepository) FailExport(id, reason string, expiresAt time.Time) error {
+	query := `
+		UPDATE DataExports SET Status = @p2, Error = @p3, CompletedAt = GETUTCDATE(), ExpiresAt = @p4
+		WHERE ID = @p1 AND Status = @p5
+	`
+	if _, err := r.DB.Exec(query, id, models.ExportFailed, reason, expiresAt, models.ExportPending); err != nil {
+		return fmt.Errorf("failed to mark data export failed: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired removes finished exports, archives included, past their expiry.
+func (r *DataExportRepository) DeleteExpired(now time.Time) error {
+	if _, err := r.DB.Exec("DELETE FROM DataExports WHERE ExpiresAt < @p1", now); err != nil {
+		return fmt.Errorf("failed to delete expired data exports: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+type EmailChangeRepository struct {
+	DB *sql.DB
+}
+
+func NewEmailChangeRepository(db *sql.DB) *EmailChangeRepository {
+	return &EmailChangeRepository{DB: db}
+}
+
+func (r *EmailChangeRepository) CreateChange(change *models.EmailChange) error {
+	query := `
+		INSERT INTO EmailChanges (UserID, NewEmail, OldTokenHash, NewTokenHash, ExpiresAt)
+		OUTPUT INSERTED.ID, INSERTED.CreatedAt
+		VALUES (@p1, @p2, @p3, @p4, @p5)
+	`
+	err := r.DB.QueryRow(query, change.UserID, change.NewEmail, change.OldTokenHash, change.NewTokenHash, change.ExpiresAt).
+		Scan(&change.ID, &change.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create email change: %w", err)
+	}
+	return nil
+}
+
+// GetPendingChangeByToken finds an open change by either of its confirmation tokens.
+func (r *EmailChangeRepository) GetPendingChangeByToken(hash string) (*models.EmailChange, error) {
+	c := &models.EmailChange{}
+	query := `
+		SELECT ID, UserID, NewEmail, OldTokenHash, NewTokenHash, OldConfirmedAt, NewConfirmedAt, ExpiresAt, CompletedAt, CreatedAt
+		FROM EmailChanges
+		WHERE (OldTokenHash = @p1 OR NewTokenHash = @p1) AND CompletedAt IS NULL AND CancelledAt IS NULL
+	`
+	err := r.DB.QueryRow(query, hash).Scan(&c.ID, &c.UserID, &c.NewEmail, &c.OldTokenHash, &c.NewTokenHash,
+		&c.OldConfirmedAt, &c.NewConfirmedAt, &c.ExpiresAt, &c.CompletedAt, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email change: %w", err)
+	}
+	return c, nil
+}
+
+// Confirm records the confirmation of the old or the new address and
+// returns both confirmation times as stored afterwards, so of two
+// confirmations arriving together one always sees the other. It returns
+// false if the change is no longer open.
+func (r *EmailChangeRepository) Confirm(change *models.EmailChange, oldAddress bool) (bool, error) {
+	column := "NewConfirmedAt"
+	if oldAddress {
+		column = "OldConfirmedAt"
+	}
+	query := `
+		UPDATE EmailChanges
+		SET ` + column + ` = COALESCE(` + column + `, GETUTCDATE())
+		OUTPUT INSERTED.OldConfirmedAt, INSERTED.NewConfirmedAt
+		WHERE ID = @p1 AND CompletedAt IS NULL AND CancelledAt IS NULL AND ExpiresAt > GETUTCDATE()
+	`
+	err := r.DB.QueryRow(query, change.ID).Scan(&change.OldConfirmedAt, &change.NewConfirmedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to confirm email change: %w", err)
+	}
+	return true, nil
+}
+
+// CompleteChange gives the user the new address once both sides confirmed.
+// It reports false if the change was already completed or cancelled, and
+// fails with "email already in use" if another account took the address in
+// the meantime.
+func (r *EmailChangeRepository) CompleteChange(change *models.EmailChange) (bool, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	complete := `
+		UPDATE EmailChanges SET CompletedAt = GETUTCDATE()
+		WHERE ID = @p1 AND CompletedAt IS NULL AND CancelledAt IS NULL
+			AND OldConfirmedAt IS NOT NULL AND NewConfirmedAt IS NOT NULL
+	`
+	res, err := tx.Exec(complete, change.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to complete email change: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return false, err
+	}
+
+	setEmail := `
+		UPDATE Users SET Email = @p1, EmailVerified = 1
+		WHERE ID = @p2 AND NOT EXISTS (SELECT 1 FROM Users WITH (UPDLOCK, HOLDLOCK) WHERE Email = @p1 AND ID <> @p2)
+	`
+	res, err = tx.Exec(setEmail, change.NewEmail, change.UserID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update email: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return false, err
+	} else if n != 1 {
+		return false, errors.New("email already in use")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *EmailChangeRepository) CancelPendingChanges(userID int) error {
+	query := "UPDATE EmailChanges SET CancelledAt = GETUTCDATE() WHERE UserID = @p1 AND CompletedAt IS NULL AND CancelledAt IS NULL"
+	if _, err := r.DB.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to cancel email changes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+type ExternalIdentityRepository struct {
+	DB *sql.DB
+}
+
+func NewExternalIdentityRepository(db *sql.DB) *ExternalIdentityRepository {
+	return &ExternalIdentityRepository{DB: db}
+}
+
+const identityColumns = "ID, UserID, Provider, Subject, Email, CreatedAt, LastLoginAt"
+
+func scanIdentity(row rowScanner) (*models.ExternalIdentity, error) {
+	var id models.ExternalIdentity
+	var email sql.NullString
+	if err := row.Scan(&id.ID, &id.UserID, &id.Provider, &id.Subject, &email, &id.CreatedAt, &id.LastLoginAt); err != nil {
+		return nil, err
+	}
+	id.Email = email.String
+	return &id, nil
+}
+
+func (r *ExternalIdentityRepository) CreateIdentity(id *models.ExternalIdentity) error {
+	query := `
+		INSERT INTO ExternalIdentities (UserID, Provider, Subject, Email)
+		OUTPUT INSERTED.ID, INSERTED.CreatedAt
+		VALUES (@p1, @p2, @p3, @p4)
+	`
+	err := r.DB.QueryRow(query, id.UserID, id.Provider, id.Subject, nullString(id.Email)).Scan(&id.ID, &id.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create external identity: %w", err)
+	}
+	return nil
+}
+
+func (r *ExternalIdentityRepository) GetIdentity(provider, subject string) (*models.ExternalIdentity, error) {
+	query := "SELECT " + identityColumns + " FROM ExternalIdentities WHERE Provider = @p1 AND Subject = @p2"
+	id, err := scanIdentity(r.DB.QueryRow(query, provider, subject))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get external identity: %w", err)
+	}
+	return id, nil
+}
+
+func (r *ExternalIdentityRepository) ListIdentities(userID int) ([]models.ExternalIdentity, error) {
+	query := "SELECT " + identityColumns + " FROM ExternalIdentities WHERE UserID = @p1 ORDER BY CreatedAt"
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list external identities: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []models.ExternalIdentity{}
+	for rows.Next() {
+		id, err := scanIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, *id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *ExternalIdentityRepository) TouchIdentity(id int, email string) error {
+	query := "UPDATE ExternalIdentities SET LastLoginAt = GETUTCDATE(), Email = @p2 WHERE ID = @p1"
+	if _, err := r.DB.Exec(query, id, nullString(email)); err != nil {
+		return fmt.Errorf("failed to update external identity: %w", err)
+	}
+	return nil
+}
+
+// DeleteIdentity reports whether the user had an identity with that ID.
+func (r *ExternalIdentityRepository) DeleteIdentity(id, userID int) (bool, error) {
+	res, err := r.DB.Exec("DELETE FROM ExternalIdentities WHERE ID = @p1 AND UserID = @p2", id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete external identity: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *ExternalIdentityRepository) CreateState(state *models.OIDCState) error {
+	query := `
+		INSERT INTO OIDCStates (StateHash, Provider, Nonce, CodeVerifier, UserID, ExpiresAt)
+		VALUES (@p1, @p2, @p3, @p4, @p5, @p6)
+	`
+	if _, err := r.DB.Exec(query, state.StateHash, state.Provider, state.Nonce, state.CodeVerifier, state.UserID, state.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to create oidc state: %w", err)
+	}
+	return nil
+}
+
+// ConsumeState deletes and returns the state in one statement, so a callback
+// can only be redeemed once. Expired states are cleaned up on the way.
+func (r *ExternalIdentityRepository) ConsumeState(hash string) (*models.OIDCState, error) {
+	if _, err := r.DB.Exec("DELETE FROM OIDCStates WHERE ExpiresAt < GETUTCDATE()"); err != nil {
+		return nil, fmt.Errorf("failed to clean up oidc states: %w", err)
+	}
+
+	var s models.OIDCState
+	query := `
+		DELETE FROM OIDCStates
+		OUTPUT DELETED.StateHash, DELETED.Provider, DELETED.Nonce, DELETED.CodeVerifier, DELETED.UserID, DELETED.ExpiresAt
+		WHERE StateHash = @p1
+	`
+	err := r.DB.QueryRow(query, hash).Scan(&s.StateHash, &s.Provider, &s.Nonce, &s.CodeVerifier, &s.UserID, &s.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume oidc state: %w", err)
+	}
+	return &s, nil
+}
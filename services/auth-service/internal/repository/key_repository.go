@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+type KeyRepository struct {
+	DB *sql.DB
+}
+
+func NewKeyRepository(db *sql.DB) *KeyRepository {
+	return &KeyRepository{DB: db}
+}
+
+// ListKeys returns every key that has not yet retired, oldest first.
+func (r *KeyRepository) ListKeys() ([]models.SigningKeyRecord, error) {
+	query := `
+		SELECT Kid, PrivateKey, ActivatesAt, RetiresAt, CreatedAt
+		FROM SigningKeys
+		WHERE RetiresAt IS NULL OR RetiresAt > GETUTCDATE()
+		ORDER BY ActivatesAt
+	`
+	rows, err := r.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.SigningKeyRecord
+	for rows.Next() {
+		var k models.SigningKeyRecord
+		if err := rows.Scan(&k.Kid, &k.PrivateKey, &k.ActivatesAt, &k.RetiresAt, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *KeyRepository) KeyExists(kid string) (bool, error) {
+	var count int
+	if err := r.DB.QueryRow("SELECT COUNT(*) FROM SigningKeys WHERE Kid = @p1", kid).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to check signing key: %w", err)
+	}
+	return count > 0, nil
+}
+
+// ReplacePrivateKey swaps the stored key for a resealed copy unless another
+// replica already did.
+func (r *KeyRepository) ReplacePrivateKey(kid, old, sealed string) error {
+	query := "UPDATE SigningKeys SET PrivateKey = @p1 WHERE Kid = @p2 AND PrivateKey = @p3"
+	if _, err := r.DB.Exec(query, sealed, kid, old); err != nil {
+		return fmt.Errorf("failed to update signing key: %w", err)
+	}
+	return nil
+}
+
+// RotateKey inserts a new key and schedules every other key to retire at
+// retireOthersAt. With onlyIfNoPending set, nothing happens when a key is
+// already waiting to activate, so replicas racing on the same schedule only
+// create one key between them. It reports whether the key was inserted.
+func (r *KeyRepository) RotateKey(key *models.SigningKeyRecord, retireOthersAt time.Time, onlyIfNoPending bool) (bool, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if onlyIfNoPending {
+		var pending int
+		query := "SELECT COUNT(*) FROM SigningKeys WITH (UPDLOCK, HOLDLOCK) WHERE ActivatesAt > GETUTCDATE()"
+		if err := tx.QueryRow(query).Scan(&pending); err != nil {
+			return false, fmt.Errorf("failed to check pending keys: %w", err)
+		}
+		if pending > 0 {
+			return false, nil
+		}
+	}
+
+	retire := `
+		UPDATE SigningKeys
+		SET RetiresAt = @p1
+		WHERE RetiresAt IS NULL OR RetiresAt > @p1
+	`
+	if _, err := tx.Exec(retire, retireOthersAt); err != nil {
+		return false, fmt.Errorf("failed to retire signing keys: %w", err)
+	}
+
+	insert := `
+		INSERT INTO SigningKeys (Kid, PrivateKey, ActivatesAt)
+		OUTPUT INSERTED.CreatedAt
+		VALUES (@p1, @p2, @p3)
+	`
+	if err := tx.QueryRow(insert, key.Kid, key.PrivateKey, key.ActivatesAt).Scan(&key.CreatedAt); err != nil {
+		return false, fmt.Errorf("failed to create signing key: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
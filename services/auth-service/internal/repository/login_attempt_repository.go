@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/lockout"
+)
+
+// LoginAttemptRepository is the SQL lockout.Store, shared by every replica.
+type LoginAttemptRepository struct {
+	DB *sql.DB
+}
+
+func NewLoginAttemptRepository(db *sql.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{DB: db}
+}
+
+func (r *LoginAttemptRepository) Get(key string) (*lockout.Record, error) {
+	rec := &lockout.Record{}
+	var lockedUntil sql.NullTime
+	query := "SELECT Failures, LastFailureAt, LockedUntil FROM LoginAttempts WHERE AttemptKey = @p1"
+	err := r.DB.QueryRow(query, key).Scan(&rec.Failures, &rec.LastFailureAt, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login attempts: %w", err)
+	}
+	if lockedUntil.Valid {
+		rec.LockedUntil = lockedUntil.Time
+	}
+	return rec, nil
+}
+
+// RecordFailure upserts the counter in one statement so concurrent failures
+// from different replicas are all counted.
+func (r *LoginAttemptRepository) RecordFailure(key string, now time.Time, window time.Duration) (int, error) {
+	query := `
+		MERGE LoginAttempts WITH (HOLDLOCK) AS t
+		USING (SELECT @p1 AS AttemptKey) AS s ON t.AttemptKey = s.AttemptKey
+		WHEN MATCHED THEN
+			UPDATE SET Failures = CASE WHEN t.LastFailureAt < @p3 THEN 1 ELSE t.Failures + 1 END, LastFailureAt = @p2
+		WHEN NOT MATCHED THEN
+			INSERT (AttemptKey, Failures, LastFailureAt) VALUES (@p1, 1, @p2)
+		OUTPUT INSERTED.Failures;
+	`
+	var failures int
+	if err := r.DB.QueryRow(query, key, now, now.Add(-window)).Scan(&failures); err != nil {
+		return 0, fmt.Errorf("failed to record login failure: %w", err)
+	}
+	return failures, nil
+}
+
+func (r *LoginAttemptRepository) Lock(key string, until time.Time) error {
+	query := "UPDATE LoginAttempts SET LockedUntil = @p2 WHERE AttemptKey = @p1"
+	if _, err := r.DB.Exec(query, key, until); err != nil {
+		return fmt.Errorf("failed to lock login: %w", err)
+	}
+	return nil
+}
+
+func (r *LoginAttemptRepository) Reset(key string) error {
+	if _, err := r.DB.Exec("DELETE FROM LoginAttempts WHERE AttemptKey = @p1", key); err != nil {
+		return fmt.Errorf("failed to reset login attempts: %w", err)
+	}
+	return nil
+}
+
+// Prune deletes records with no recent failure and no active lockout.
+func (r *LoginAttemptRepository) Prune(before time.Time) error {
+	query := `
+		DELETE FROM LoginAttempts
+		WHERE LastFailureAt < @p1 AND (LockedUntil IS NULL OR LockedUntil < GETUTCDATE())
+	`
+	if _, err := r.DB.Exec(query, before); err != nil {
+		return fmt.Errorf("failed to prune login attempts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+type OrgInviteRepository struct {
+	DB *sql.DB
+}
+
+func NewOrgInviteRepository(db *sql.DB) *OrgInviteRepository {
+	return &OrgInviteRepository{DB: db}
+}
+
+const inviteColumns = "ID, OrgID, Role, Username, Email, TokenHash, InvitedBy, ExpiresAt, AcceptedAt, RevokedAt, CreatedAt"
+
+func scanInvite(row rowScanner) (*models.OrgInvite, error) {
+	var inv models.OrgInvite
+	var username, email sql.NullString
+	err := row.Scan(&inv.ID, &inv.OrgID, &inv.Role, &username, &email, &inv.TokenHash, &inv.InvitedBy,
+		&inv.ExpiresAt, &inv.AcceptedAt, &inv.RevokedAt, &inv.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	inv.Username = username.String
+	inv.Email = email.String
+	return &inv, nil
+}
+
+func (r *OrgInviteRepository) CreateInvite(inv *models.OrgInvite) error {
+	query := `
+		INSERT INTO OrgInvites (OrgID, Role, Username, Email, TokenHash, InvitedBy, ExpiresAt)
+		OUTPUT INSERTED.ID, INSERTED.CreatedAt
+		VALUES (@p1, @p2, @p3, @p4, @p5, @p6, @p7)
+	`
+	err := r.DB.QueryRow(query, inv.OrgID, inv.Role, nullString(inv.Username), nullString(inv.Email),
+		inv.TokenHash, inv.InvitedBy, inv.ExpiresAt).Scan(&inv.ID, &inv.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create invite: %w", err)
+	}
+	return nil
+}
+
+func (r *OrgInviteRepository) GetInviteByHash(hash string) (*models.OrgInvite, error) {
+	inv, err := scanInvite(r.DB.QueryRow("SELECT "+inviteColumns+" FROM OrgInvites WHERE TokenHash = @p1", hash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite: %w", err)
+	}
+	return inv, nil
+}
+
+// ListPendingInvites returns invites that have not been accepted, revoked or expired.
+func (r *OrgInviteRepository) ListPendingInvites(orgID int) ([]models.OrgInvite, error) {
+	query := "SELECT " + inviteColumns + ` FROM OrgInvites
+		WHERE OrgID = @p1 AND AcceptedAt IS NULL AND RevokedAt IS NULL AND ExpiresAt > GETUTCDATE()
+		ORDER BY CreatedAt DESC`
+	rows, err := r.DB.Query(query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invites: %w", err)
+	}
+	defer rows.Close()
+
+	invites := []models.OrgInvite{}
+	for rows.Next() {
+		inv, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, *inv)
+	}
+	return invites, rows.Err()
+}
+
+// RevokeInvite reports whether a pending invite of the organization was found.
+func (r *OrgInviteRepository) RevokeInvite(id, orgID int) (bool, error) {
+	query := `
+		UPDATE OrgInvites SET RevokedAt = GETUTCDATE()
+		WHERE ID = @p1 AND OrgID = @p2 AND AcceptedAt IS NULL AND RevokedAt IS NULL
+	`
+	res, err := r.DB.Exec(query, id, orgID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke invite: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// AcceptInvite claims the invite and adds the user to the organization in one
+// transaction. It returns false if the invite is no longer pending.
+func (r *OrgInviteRepository) AcceptInvite(inv *models.OrgInvite, userID int) (bool, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	ok, err := acceptInvite(tx, inv, userID)
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// RegisterWithInvite creates the account and accepts the invite for it in one
+// transaction, so neither happens without the other. It returns false if the
+// invite is no longer pending.
+func (r *OrgInviteRepository) RegisterWithInvite(inv *models.OrgInvite, user *models.User) (bool, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if err := insertUser(tx, user); err != nil {
+		return false, err
+	}
+	ok, err := acceptInvite(tx, inv, user.ID)
+	if err != nil || !ok {
+		user.ID = 0
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func acceptInvite(tx *sql.Tx, inv *models.OrgInvite, userID int) (bool, error) {
+	claim := `
+		UPDATE OrgInvites SET AcceptedAt = GETUTCDATE(), AcceptedBy = @p2
+		WHERE ID = @p1 AND AcceptedAt IS NULL AND RevokedAt IS NULL AND ExpiresAt > GETUTCDATE()
+	`
+	res, err := tx.Exec(claim, inv.ID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to accept invite: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n != 1 {
+		return false, nil
+	}
+
+	member := "INSERT INTO OrgMembers (OrgID, UserID, Role) VALUES (@p1, @p2, @p3)"
+	if _, err := tx.Exec(member, inv.OrgID, userID, inv.Role); err != nil {
+		return false, fmt.Errorf("failed to add organization member: %w", err)
+	}
+	return true, nil
+}
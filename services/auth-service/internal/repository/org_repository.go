@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+type OrgRepository struct {
+	DB *sql.DB
+}
+
+func NewOrgRepository(db *sql.DB) *OrgRepository {
+	return &OrgRepository{DB: db}
+}
+
+// CreateOrg inserts the organization with ownerID as its first owner.
+func (r *OrgRepository) CreateOrg(org *models.Organization, ownerID int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insert := `
+		INSERT INTO Organizations (Name, CreatedBy)
+		OUTPUT INSERTED.ID, INSERTED.CreatedAt
+		VALUES (@p1, @p2)
+	`
+	if err := tx.QueryRow(insert, org.Name, ownerID).Scan(&org.ID, &org.CreatedAt); err != nil {
+		return fmt.Errorf("failed to create organization: %w", err)
+	}
+	member := "INSERT INTO OrgMembers (OrgID, UserID, Role) VALUES (@p1, @p2, @p3)"
+	if _, err := tx.Exec(member, org.ID, ownerID, models.OrgRoleOwner); err != nil {
+		return fmt.Errorf("failed to add organization owner: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *OrgRepository) GetOrg(id int) (*models.Organization, error) {
+	org := &models.Organization{}
+	query := "SELECT ID, Name, CreatedAt FROM Organizations WHERE ID = @p1"
+	err := r.DB.QueryRow(query, id).Scan(&org.ID, &org.Name, &org.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return org, nil
+}
+
+// ListOrgsForUser returns the organizations the user belongs to, with their role in each.
+func (r *OrgRepository) ListOrgsForUser(userID int) ([]models.Organization, error) {
+	query := `
+		SELECT o.ID, o.Name, o.CreatedAt, m.Role
+		FROM Organizations o
+		JOIN OrgMembers m ON m.OrgID = o.ID
+		WHERE m.UserID = @p1
+		ORDER BY o.Name
+	`
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	defer rows.Close()
+
+	orgs := []models.Organization{}
+	for rows.Next() {
+		var o models.Organization
+		if err := rows.Scan(&o.ID, &o.Name, &o.CreatedAt, &o.Role); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, o)
+	}
+	return orgs, rows.Err()
+}
+
+func (r *OrgRepository) RenameOrg(id int, name string) error {
+	if _, err := r.DB.Exec("UPDATE Organizations SET Name = @p1 WHERE ID = @p2", name, id); err != nil {
+		return fmt.Errorf("failed to rename organization: %w", err)
+	}
+	return nil
+}
+
+const memberColumns = "m.OrgID, m.UserID, u.Username, m.Role, m.CreatedAt"
+
+func (r *OrgRepository) GetMember(orgID, userID int) (*models.OrgMember, error) {
+	query := "SELECT " + memberColumns + " FROM OrgMembers m JOIN Users u ON u.ID = m.UserID WHERE m.OrgID = @p1 AND m.UserID = @p2"
+	var m models.OrgMember
+	err := r.DB.QueryRow(query, orgID, userID).Scan(&m.OrgID, &m.UserID, &m.Username, &m.Role, &m.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization member: %w", err)
+	}
+	return &m, nil
+}
+
+func (r *OrgRepository) ListMembers(orgID int) ([]models.OrgMember, error) {
+	query := "SELECT " + memberColumns + " FROM OrgMembers m JOIN Users u ON u.ID = m.UserID WHERE m.OrgID = @p1 ORDER BY u.Username"
+	rows, err := r.DB.Query(query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []models.OrgMember{}
+	for rows.Next() {
+		var m models.OrgMember
+		if err := rows.Scan(&m.OrgID, &m.UserID, &m.Username, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// Guards member updates so an organization never loses its last owner
+const keepsAnOwner = "(Role <> 'owner' OR (SELECT COUNT(*) FROM OrgMembers WHERE OrgID = @p1 AND Role = 'owner') > 1)"
+
+// SetMemberRole returns false if the change would leave the organization without an owner.
+func (r *OrgRepository) SetMemberRole(orgID, userID int, role string) (bool, error) {
+	query := "UPDATE OrgMembers SET Role = @p3 WHERE OrgID = @p1 AND UserID = @p2"
+	if role != models.OrgRoleOwner {
+		query += " AND " + keepsAnOwner
+	}
+	res, err := r.DB.Exec(query, orgID, userID, role)
+	if err != nil {
+		return false, fmt.Errorf("failed to update organization member: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// RemoveMember returns false if the member is the organization's last owner.
+func (r *OrgRepository) RemoveMember(orgID, userID int) (bool, error) {
+	query := "DELETE FROM OrgMembers WHERE OrgID = @p1 AND UserID = @p2 AND " + keepsAnOwner
+	res, err := r.DB.Exec(query, orgID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove organization member: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+type RecoveryCodeRepository struct {
+	DB *sql.DB
+}
+
+func NewRecoveryCodeRepository(db *sql.DB) *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{DB: db}
+}
+
+// ReplaceCodes drops any previous recovery codes and stores the new hashes.
+func (r *RecoveryCodeRepository) ReplaceCodes(userID int, hashes []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM RecoveryCodes WHERE UserID = @p1", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	for _, hash := range hashes {
+		if _, err := tx.Exec("INSERT INTO RecoveryCodes (UserID, CodeHash) VALUES (@p1, @p2)", userID, hash); err != nil {
+			return fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// UseCode burns an unused code. It returns false if no such code exists.
+func (r *RecoveryCodeRepository) UseCode(userID int, hash string) (bool, error) {
+	query := "UPDATE RecoveryCodes SET UsedAt = GETUTCDATE() WHERE UserID = @p1 AND CodeHash = @p2 AND UsedAt IS NULL"
+	res, err := r.DB.Exec(query, userID, hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *RecoveryCodeRepository) DeleteCodes(userID int) error {
+	if _, err := r.DB.Exec("DELETE FROM RecoveryCodes WHERE UserID = @p1", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	return nil
+}
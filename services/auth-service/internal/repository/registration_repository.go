@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+type RegistrationRepository struct {
+	DB *sql.DB
+}
+
+func NewRegistrationRepository(db *sql.DB) *RegistrationRepository {
+	return &RegistrationRepository{DB: db}
+}
+
+// GetSettings returns nil if no admin has changed the settings yet.
+func (r *RegistrationRepository) GetSettings() (*models.RegistrationSettings, error) {
+	var s models.RegistrationSettings
+	var domains string
+	var updatedBy sql.NullInt64
+	var updatedAt time.Time
+	err := r.DB.QueryRow("SELECT Mode, AllowedDomains, UpdatedBy, UpdatedAt FROM RegistrationSettings WHERE ID = 1").
+		Scan(&s.Mode, &domains, &updatedBy, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registration settings: %w", err)
+	}
+	s.AllowedDomains = []string{}
+	if domains != "" {
+		s.AllowedDomains = strings.Split(domains, ",")
+	}
+	if updatedBy.Valid {
+		id := int(updatedBy.Int64)
+		s.UpdatedBy = &id
+	}
+	s.UpdatedAt = &updatedAt
+	return &s, nil
+}
+
+func (r *RegistrationRepository) SaveSettings(s *models.RegistrationSettings, adminID int) error {
+	query := `
+		MERGE RegistrationSettings AS t
+		USING (SELECT 1 AS ID) AS src ON t.ID = src.ID
+		WHEN MATCHED THEN UPDATE SET Mode = @p1, AllowedDomains = @p2, UpdatedBy = @p3, UpdatedAt = GETUTCDATE()
+		WHEN NOT MATCHED THEN INSERT (ID, Mode, AllowedDomains, UpdatedBy) VALUES (1, @p1, @p2, @p3);
+	`
+	if _, err := r.DB.Exec(query, s.Mode, strings.Join(s.AllowedDomains, ","), adminID); err != nil {
+		return fmt.Errorf("failed to save registration settings: %w", err)
+	}
+	return nil
+}
+
+const inviteCodeColumns = "ID, Note, CodeHash, MaxUses, Uses, CreatedBy, ExpiresAt, RevokedAt, CreatedAt"
+
+func scanInviteCode(row rowScanner) (*models.InviteCode, error) {
+	var code models.InviteCode
+	var note sql.NullString
+	err := row.Scan(&code.ID, &note, &code.CodeHash, &code.MaxUses, &code.Uses, &code.CreatedBy,
+		&code.ExpiresAt, &code.RevokedAt, &code.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	code.Note = note.String
+	return &code, nil
+}
+
+func (r *RegistrationRepository) CreateCode(code *models.InviteCode) error {
+	query := `
+		INSERT INTO InviteCodes (CodeHash, Note, MaxUses, CreatedBy, ExpiresAt)
+		OUTPUT INSERTED.ID, INSERTED.CreatedAt
+		VALUES (@p1, @p2, @p3, @p4, @p5)
+	`
+	err := r.DB.QueryRow(query, code.CodeHash, nullString(code.Note), code.MaxUses, code.CreatedBy, code.ExpiresAt).
+		Scan(&code.ID, &code.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create invite code: %w", err)
+	}
+	return nil
+}
+
+func (r *RegistrationRepository) GetCodeByHash(hash string) (*models.InviteCode, error) {
+	code, err := scanInviteCode(r.DB.QueryRow("SELECT "+inviteCodeColumns+" FROM InviteCodes WHERE CodeHash = @p1", hash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite code: %w", err)
+	}
+	return code, nil
+}
+
+// ListCodes returns every code, newest first, used up and revoked ones included.
+func (r *RegistrationRepository) ListCodes() ([]models.InviteCode, error) {
+	rows, err := r.DB.Query("SELECT " + inviteCodeColumns + " FROM InviteCodes ORDER BY CreatedAt DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invite codes: %w", err)
+	}
+	defer rows.Close()
+
+	codes := []models.InviteCode{}
+	for rows.Next() {
+		code, err := scanInviteCode(rows)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, *code)
+	}
+	return codes, rows.Err()
+}
+
+// UseCode counts one use. It returns false if the code was used up, revoked
+// or expired in the meantime, so concurrent sign-ups cannot exceed MaxUses.
+func (r *RegistrationRepository) UseCode(id int) (bool, error) {
+	query := `
+		UPDATE InviteCodes SET Uses = Uses + 1
+		WHERE ID = @p1 AND Uses < MaxUses AND RevokedAt IS NULL AND ExpiresAt > GETUTCDATE()
+	`
+	res, err := r.DB.Exec(query, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to use invite code: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// ReleaseCode gives back a use whose sign-up failed.
+func (r *RegistrationRepository) ReleaseCode(id int) error {
+	if _, err := r.DB.Exec("UPDATE InviteCodes SET Uses = Uses - 1 WHERE ID = @p1 AND Uses > 0", id); err != nil {
+		return fmt.Errorf("failed to release invite code: %w", err)
+	}
+	return nil
+}
+
+// RevokeCode reports whether a code that was not already revoked was found.
+func (r *RegistrationRepository) RevokeCode(id int) (bool, error) {
+	res, err := r.DB.Exec("UPDATE InviteCodes SET RevokedAt = GETUTCDATE() WHERE ID = @p1 AND RevokedAt IS NULL", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke invite code: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
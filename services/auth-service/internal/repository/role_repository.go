@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+type RoleRepository struct {
+	DB *sql.DB
+}
+
+func NewRoleRepository(db *sql.DB) *RoleRepository {
+	return &RoleRepository{DB: db}
+}
+
+func (r *RoleRepository) ListRoles() ([]models.Role, error) {
+	rows, err := r.DB.Query("SELECT Name, Description FROM Roles ORDER BY Name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := []models.Role{}
+	for rows.Next() {
+		var role models.Role
+		if err := rows.Scan(&role.Name, &role.Description); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range roles {
+		if roles[i].Permissions, err = r.GetPermissions(roles[i].Name); err != nil {
+			return nil, err
+		}
+	}
+	return roles, nil
+}
+
+func (r *RoleRepository) GetRole(name string) (*models.Role, error) {
+	role := &models.Role{}
+	err := r.DB.QueryRow("SELECT Name, Description FROM Roles WHERE Name = @p1", name).Scan(&role.Name, &role.Description)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	if role.Permissions, err = r.GetPermissions(name); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// GetPermissions returns the permissions granted to a role, or none for an unknown role.
+func (r *RoleRepository) GetPermissions(role string) ([]string, error) {
+	query := "SELECT PermissionName FROM RolePermissions WHERE RoleName = @p1 ORDER BY PermissionName"
+	rows, err := r.DB.Query(query, role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role permissions: %w", err)
+	}
+	defer rows.Close()
+
+	perms := []string{}
+	for rows.Next() {
+		var perm string
+		if err := rows.Scan(&perm); err != nil {
+			return nil, err
+		}
+		perms = append(perms, perm)
+	}
+	return perms, rows.Err()
+}
+
+func (r *RoleRepository) ListPermissions() ([]models.Permission, error) {
+	rows, err := r.DB.Query("SELECT Name, Description FROM Permissions ORDER BY Name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+	defer rows.Close()
+
+	perms := []models.Permission{}
+	for rows.Next() {
+		var p models.Permission
+		if err := rows.Scan(&p.Name, &p.Description); err != nil {
+			return nil, err
+		}
+		perms = append(perms, p)
+	}
+	return perms, rows.Err()
+}
+
+// PutRole creates the role or replaces its description and permissions. It
+// fails with "no role manager left" if afterwards no active user could manage
+// roles any more, since nobody could then undo the change.
+func (r *RoleRepository) PutRole(role *models.Role) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	upsert := `
+		MERGE Roles WITH (HOLDLOCK) AS t
+		USING (SELECT @p1 AS Name) AS s ON t.Name = s.Name
+		WHEN MATCHED THEN UPDATE SET Description = @p2
+		WHEN NOT MATCHED THEN INSERT (Name, Description) VALUES (@p1, @p2);
+	`
+	if _, err := tx.Exec(upsert, role.Name, role.Description); err != nil {
+		return fmt.Errorf("failed to save role: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM RolePermissions WHERE RoleName = @p1", role.Name); err != nil {
+		return fmt.Errorf("failed to clear role permissions: %w", err)
+	}
+	for _, perm := range role.Permissions {
+		insert := "INSERT INTO RolePermissions (RoleName, PermissionName) VALUES (@p1, @p2)"
+		if _, err := tx.Exec(insert, role.Name, perm); err != nil {
+			return fmt.Errorf("failed to grant permission: %w", err)
+		}
+	}
+
+	// Locks the rows it reads, so two changes removing the permission from
+	// different roles cannot both pass
+	var managers int
+	check := `
+		SELECT COUNT(*) FROM Users u
+		JOIN RolePermissions rp WITH (UPDLOCK, HOLDLOCK) ON rp.RoleName = u.Role AND rp.PermissionName = @p1
+		WHERE u.DisabledAt IS NULL AND u.DeletedAt IS NULL
+	`
+	if err := tx.QueryRow(check, models.PermRolesManage).Scan(&managers); err != nil {
+		return fmt.Errorf("failed to check role managers: %w", err)
+	}
+	if managers == 0 {
+		return errors.New("no role manager left")
+	}
+
+	return tx.Commit()
+}
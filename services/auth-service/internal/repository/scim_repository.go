@@ -0,0 +1,398 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+// ScimRepository tracks which accounts and groups each SCIM tenant owns. The
+// accounts themselves live in Users and are changed through UserRepository.
+type ScimRepository struct {
+	DB *sql.DB
+}
+
+func NewScimRepository(db *sql.DB) *ScimRepository {
+	return &ScimRepository{DB: db}
+}
+
+// Filterable attributes, mapped to the columns they compare against
+var scimUserFilters = map[string]string{
+	"username":     "u.Username",
+	"externalid":   "s.ExternalID",
+	"emails":       "u.Email",
+	"emails.value": "u.Email",
+	"id":           "u.ID",
+}
+
+var scimGroupFilters = map[string]string{
+	"displayname": "g.DisplayName",
+	"externalid":  "g.ExternalID",
+	"id":          "g.ID",
+}
+
+// scimFilterClause turns a filter into SQL. Only whitelisted columns are ever
+// concatenated; the value is always a parameter.
+func scimFilterClause(filter *models.ScimFilter, columns map[string]string, args []interface{}) (string, []interface{}, error) {
+	if filter == nil {
+		return "", args, nil
+	}
+	if filter.Attribute == "active" && columns["username"] != "" {
+		if filter.Value == "true" {
+			return " AND u.DisabledAt IS NULL", args, nil
+		}
+		return " AND u.DisabledAt IS NOT NULL", args, nil
+	}
+	column, ok := columns[filter.Attribute]
+	if !ok {
+		return "", nil, errors.New("invalid filter")
+	}
+	if filter.Attribute == "id" {
+		id, err := strconv.Atoi(filter.Value)
+		if err != nil {
+			return " AND 1 = 0", args, nil
+		}
+		args = append(args, id)
+	} else {
+		args = append(args, filter.Value)
+	}
+	return fmt.Sprintf(" AND %s = @p%d", column, len(args)), args, nil
+}
+
+func (r *ScimRepository) LinkUser(tenant string, userID int, externalID string) error {
+	query := "INSERT INTO ScimUsers (Tenant, UserID, ExternalID) VALUES (@p1, @p2, @p3)"
+	if _, err := r.DB.Exec(query, tenant, userID, nullString(externalID)); err != nil {
+		return fmt.Errorf("failed to link scim user: %w", err)
+	}
+	return nil
+}
+
+// UnlinkUser hands the account back to local admins and drops it from the tenant's groups.
+func (r *ScimRepository) UnlinkUser(tenant string, userID int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	members := `
+		DELETE m FROM ScimGroupMembers m
+		JOIN ScimGroups g ON g.ID = m.GroupID
+		WHERE g.Tenant = @p1 AND m.UserID = @p2
+	`
+	if _, err := tx.Exec(members, tenant, userID); err != nil {
+		return fmt.Errorf("failed to remove scim group memberships: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM ScimUsers WHERE Tenant = @p1 AND UserID = @p2", tenant, userID); err != nil {
+		return fmt.Errorf("failed to unlink scim user: %w", err)
+	}
+	return tx.Commit()
+}
+
+// IsProvisioned reports whether any tenant manages the account.
+func (r *ScimRepository) IsProvisioned(userID int) (bool, error) {
+	var n int
+	if err := r.DB.QueryRow("SELECT COUNT(*) FROM ScimUsers WHERE UserID = @p1", userID).Scan(&n); err != nil {
+		return false, fmt.Errorf("failed to check scim user: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (r *ScimRepository) SetExternalID(tenant string, userID int, externalID string) error {
+	query := "UPDATE ScimUsers SET ExternalID = @p3 WHERE Tenant = @p1 AND UserID = @p2"
+	if _, err := r.DB.Exec(query, tenant, userID, nullString(externalID)); err != nil {
+		return fmt.Errorf("failed to update scim external id: %w", err)
+	}
+	return nil
+}
+
+var scimUserColumns = "u." + strings.ReplaceAll(userColumns, ", ", ", u.") + ", s.ExternalID"
+
+// withExtra lets scanUser read a row that has more columns after the user's.
+type withExtra struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+func (w withExtra) Scan(dest ...interface{}) error {
+	return w.row.Scan(append(dest, w.extra...)...)
+}
+
+func scanScimUser(row rowScanner) (*models.ScimUser, error) {
+	var externalID sql.NullString
+	user, err := scanUser(withExtra{row: row, extra: []interface{}{&externalID}})
+	if err != nil {
+		return nil, err
+	}
+	return &models.ScimUser{User: *user, ExternalID: externalID.String}, nil
+}
+
+func (r *ScimRepository) GetUser(tenant string, userID int) (*models.ScimUser, error) {
+	query := "SELECT " + scimUserColumns + " FROM ScimUsers s JOIN Users u ON u.ID = s.UserID WHERE s.Tenant = @p1 AND s.UserID = @p2"
+	user, err := scanScimUser(r.DB.QueryRow(query, tenant, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scim user: %w", err)
+	}
+	return user, nil
+}
+
+// ListUsers returns one page of the tenant's accounts ordered by ID, plus the total number of matches.
+func (r *ScimRepository) ListUsers(tenant string, filter *models.ScimFilter, offset, limit int) ([]models.ScimUser, int, error) {
+	where, args, err := scimFilterClause(filter, scimUserFilters, []interface{}{tenant})
+	if err != nil {
+		return nil, 0, err
+	}
+	from := " FROM ScimUsers s JOIN Users u ON u.ID = s.UserID WHERE s.Tenant = @p1" + where
+
+	var total int
+	if err := r.DB.QueryRow("SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count scim users: %w", err)
+	}
+	users := []models.ScimUser{}
+	if limit == 0 {
+		return users, total, nil
+	}
+
+	args = append(args, offset, limit)
+	query := fmt.Sprintf("SELECT %s%s ORDER BY u.ID OFFSET @p%d ROWS FETCH NEXT @p%d ROWS ONLY", scimUserColumns, from, len(args)-1, len(args))
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list scim users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user, err := scanScimUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, *user)
+	}
+	return users, total, rows.Err()
+}
+
+// GroupNamesForUser lists the tenant's groups the user is in.
+func (r *ScimRepository) GroupNamesForUser(tenant string, userID int) ([]string, error) {
+	query := `
+		SELECT g.DisplayName FROM ScimGroups g
+		JOIN ScimGroupMembers m ON m.GroupID = g.ID
+		WHERE g.Tenant = @p1 AND m.UserID = @p2
+	`
+	rows, err := r.DB.Query(query, tenant, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scim groups for user: %w", err)
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+const scimGroupColumns = "g.ID, g.DisplayName, g.ExternalID, g.CreatedAt, g.UpdatedAt"
+
+func scanScimGroup(row rowScanner) (*models.ScimGroup, error) {
+	var g models.ScimGroup
+	var externalID sql.NullString
+	if err := row.Scan(&g.ID, &g.DisplayName, &externalID, &g.CreatedAt, &g.UpdatedAt); err != nil {
+		return nil, err
+	}
+	g.ExternalID = externalID.String
+	g.Members = []models.ScimMember{}
+	return &g, nil
+}
+
+func (r *ScimRepository) CreateGroup(tenant string, group *models.ScimGroup) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insert := `
+		INSERT INTO ScimGroups (Tenant, DisplayName, ExternalID)
+		OUTPUT INSERTED.ID, INSERTED.CreatedAt, INSERTED.UpdatedAt
+		VALUES (@p1, @p2, @p3)
+	`
+	err = tx.QueryRow(insert, tenant, group.DisplayName, nullString(group.ExternalID)).Scan(&group.ID, &group.CreatedAt, &group.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create scim group: %w", err)
+	}
+	if err := addScimMembers(tx, group.ID, group.Members); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *ScimRepository) GetGroup(tenant string, id int) (*models.ScimGroup, error) {
+	query := "SELECT " + scimGroupColumns + " FROM ScimGroups g WHERE g.Tenant = @p1 AND g.ID = @p2"
+	group, err := scanScimGroup(r.DB.QueryRow(query, tenant, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scim group: %w", err)
+	}
+	if err := r.loadMembers([]*models.ScimGroup{group}); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// ListGroups returns one page of the tenant's groups with their members.
+func (r *ScimRepository) ListGroups(tenant string, filter *models.ScimFilter, offset, limit int) ([]models.ScimGroup, int, error) {
+	where, args, err := scimFilterClause(filter, scimGroupFilters, []interface{}{tenant})
+	if err != nil {
+		return nil, 0, err
+	}
+	from := " FROM ScimGroups g WHERE g.Tenant = @p1" + where
+
+	var total int
+	if err := r.DB.QueryRow("SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count scim groups: %w", err)
+	}
+	if limit == 0 {
+		return []models.ScimGroup{}, total, nil
+	}
+
+	args = append(args, offset, limit)
+	query := fmt.Sprintf("SELECT %s%s ORDER BY g.ID OFFSET @p%d ROWS FETCH NEXT @p%d ROWS ONLY", scimGroupColumns, from, len(args)-1, len(args))
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list scim groups: %w", err)
+	}
+	var page []*models.ScimGroup
+	for rows.Next() {
+		group, err := scanScimGroup(rows)
+		if err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		page = append(page, group)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if err := r.loadMembers(page); err != nil {
+		return nil, 0, err
+	}
+	groups := make([]models.ScimGroup, 0, len(page))
+	for _, g := range page {
+		groups = append(groups, *g)
+	}
+	return groups, total, nil
+}
+
+// loadMembers fills in the members of all groups with one query.
+func (r *ScimRepository) loadMembers(groups []*models.ScimGroup) error {
+	if len(groups) == 0 {
+		return nil
+	}
+	byID := make(map[int]*models.ScimGroup, len(groups))
+	placeholders := make([]string, 0, len(groups))
+	args := make([]interface{}, 0, len(groups))
+	for _, g := range groups {
+		byID[g.ID] = g
+		args = append(args, g.ID)
+		placeholders = append(placeholders, fmt.Sprintf("@p%d", len(args)))
+	}
+
+	query := `
+		SELECT m.GroupID, u.ID, u.Username FROM ScimGroupMembers m
+		JOIN Users u ON u.ID = m.UserID
+		WHERE m.GroupID IN (` + strings.Join(placeholders, ", ") + `)
+		ORDER BY u.ID
+	`
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to list scim group members: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var groupID int
+		var m models.ScimMember
+		if err := rows.Scan(&groupID, &m.UserID, &m.Username); err != nil {
+			return err
+		}
+		byID[groupID].Members = append(byID[groupID].Members, m)
+	}
+	return rows.Err()
+}
+
+func (r *ScimRepository) UpdateGroup(tenant string, group *models.ScimGroup) error {
+	query := "UPDATE ScimGroups SET DisplayName = @p3, ExternalID = @p4, UpdatedAt = GETUTCDATE() WHERE Tenant = @p1 AND ID = @p2"
+	if _, err := r.DB.Exec(query, tenant, group.ID, group.DisplayName, nullString(group.ExternalID)); err != nil {
+		return fmt.Errorf("failed to update scim group: %w", err)
+	}
+	return nil
+}
+
+func (r *ScimRepository) DeleteGroup(tenant string, id int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	members := `
+		DELETE m FROM ScimGroupMembers m
+		JOIN ScimGroups g ON g.ID = m.GroupID
+		WHERE g.Tenant = @p1 AND g.ID = @p2
+	`
+	if _, err := tx.Exec(members, tenant, id); err != nil {
+		return fmt.Errorf("failed to remove scim group members: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM ScimGroups WHERE Tenant = @p1 AND ID = @p2", tenant, id); err != nil {
+		return fmt.Errorf("failed to delete scim group: %w", err)
+	}
+	return tx.Commit()
+}
+
+// SetMembers replaces the member list of a group.
+func (r *ScimRepository) SetMembers(groupID int, members []models.ScimMember) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM ScimGroupMembers WHERE GroupID = @p1", groupID); err != nil {
+		return fmt.Errorf("failed to clear scim group members: %w", err)
+	}
+	if err := addScimMembers(tx, groupID, members); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE ScimGroups SET UpdatedAt = GETUTCDATE() WHERE ID = @p1", groupID); err != nil {
+		return fmt.Errorf("failed to update scim group: %w", err)
+	}
+	return tx.Commit()
+}
+
+func addScimMembers(tx *sql.Tx, groupID int, members []models.ScimMember) error {
+	query := `
+		IF NOT EXISTS (SELECT 1 FROM ScimGroupMembers WHERE GroupID = @p1 AND UserID = @p2)
+			INSERT INTO ScimGroupMembers (GroupID, UserID) VALUES (@p1, @p2)
+	`
+	for _, m := range members {
+		if _, err := tx.Exec(query, groupID, m.UserID); err != nil {
+			return fmt.Errorf("failed to add scim group member: %w", err)
+		}
+	}
+	return nil
+}
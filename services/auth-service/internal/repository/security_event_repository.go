@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+// SecurityEventRepository only ever inserts and reads; the table is append-only.
+type SecurityEventRepository struct {
+	DB *sql.DB
+}
+
+func NewSecurityEventRepository(db *sql.DB) *SecurityEventRepository {
+	return &SecurityEventRepository{DB: db}
+}
+
+func (r *SecurityEventRepository) CreateEvent(e *models.SecurityEvent) error {
+	query := `
+		INSERT INTO SecurityEvents (EventType, Outcome, UserID, Username, ActorID, IPAddress, UserAgent, Detail)
+		OUTPUT INSERTED.ID, INSERTED.CreatedAt
+		VALUES (@p1, @p2, @p3, @p4, @p5, @p6, @p7, @p8)
+	`
+	err := r.DB.QueryRow(query, e.Type, e.Outcome, e.UserID, nullString(e.Username), e.ActorID,
+		nullString(e.IP), nullString(e.UserAgent), nullString(e.Detail)).Scan(&e.ID, &e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record security event: %w", err)
+	}
+	return nil
+}
+
+// ListEvents returns one page of matching events, newest first, plus the total number of matches.
+func (r *SecurityEventRepository) ListEvents(q *models.SecurityEventQuery) ([]models.SecurityEvent, int, error) {
+	where := "1 = 1"
+	var args []interface{}
+	add := func(clause string, arg interface{}) {
+		args = append(args, arg)
+		where += fmt.Sprintf(clause, len(args))
+	}
+	if q.UserID != 0 {
+		add(" AND UserID = @p%d", q.UserID)
+	}
+	if q.Type != "" {
+		add(" AND EventType = @p%d", q.Type)
+	}
+	if q.Outcome != "" {
+		add(" AND Outcome = @p%d", q.Outcome)
+	}
+	if q.IP != "" {
+		add(" AND IPAddress = @p%d", q.IP)
+	}
+	if q.Since != nil {
+		add(" AND CreatedAt >= @p%d", q.Since.UTC())
+	}
+	if q.Until != nil {
+		add(" AND CreatedAt < @p%d", q.Until.UTC())
+	}
+
+	var total int
+	if err := r.DB.QueryRow("SELECT COUNT(*) FROM SecurityEvents WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count security events: %w", err)
+	}
+
+	args = append(args, (q.Page-1)*q.PageSize, q.PageSize)
+	query := fmt.Sprintf(`
+		SELECT ID, EventType, Outcome, UserID, Username, ActorID, IPAddress, UserAgent, Detail, CreatedAt
+		FROM SecurityEvents WHERE %s
+		ORDER BY ID DESC OFFSET @p%d ROWS FETCH NEXT @p%d ROWS ONLY
+	`, where, len(args)-1, len(args))
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list security events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.SecurityEvent{}
+	for rows.Next() {
+		var e models.SecurityEvent
+		var userID, actorID sql.NullInt64
+		var username, ip, ua, detail sql.NullString
+		if err := rows.Scan(&e.ID, &e.Type, &e.Outcome, &userID, &username, &actorID, &ip, &ua, &detail, &e.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		if userID.Valid {
+			id := int(userID.Int64)
+			e.UserID = &id
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			e.ActorID = &id
+		}
+		e.Username, e.IP, e.UserAgent, e.Detail = username.String, ip.String, ua.String, detail.String
+		events = append(events, e)
+	}
+	return events, total, rows.Err()
+}
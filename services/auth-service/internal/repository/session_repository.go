@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+type SessionRepository struct {
+	DB *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{DB: db}
+}
+
+func (r *SessionRepository) CreateSession(session *models.Session) error {
+	query := `
+		INSERT INTO Sessions (ID, UserID, ImpersonatorID, ExpiresAt)
+		OUTPUT INSERTED.CreatedAt
+		VALUES (@p1, @p2, @p3, @p4)
+	`
+	err := r.DB.QueryRow(query, session.ID, session.UserID, session.ImpersonatorID, session.ExpiresAt).Scan(&session.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) GetSession(id string) (*models.Session, error) {
+	session := &models.Session{}
+	query := `
+		SELECT ID, UserID, ImpersonatorID, CreatedAt, ExpiresAt, RevokedAt
+		FROM Sessions
+		WHERE ID = @p1
+	`
+	err := r.DB.QueryRow(query, id).Scan(&session.ID, &session.UserID, &session.ImpersonatorID, &session.CreatedAt, &session.ExpiresAt, &session.RevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return session, nil
+}
+
+func (r *SessionRepository) RevokeSession(id string) error {
+	query := "UPDATE Sessions SET RevokedAt = GETUTCDATE() WHERE ID = @p1 AND RevokedAt IS NULL"
+	if _, err := r.DB.Exec(query, id); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) RevokeUserSessions(userID int) error {
+	query := "UPDATE Sessions SET RevokedAt = GETUTCDATE() WHERE UserID = @p1 AND RevokedAt IS NULL"
+	if _, err := r.DB.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// ListActiveSessions returns the user's unexpired, unrevoked sessions, newest first.
+func (r *SessionRepository) ListActiveSessions(userID int) ([]models.SessionInfo, error) {
+	query := `
+		SELECT s.ID, s.CreatedAt, s.ExpiresAt,
+			(SELECT MAX(t.UsedAt) FROM RefreshTokens t WHERE t.SessionID = s.ID), s.ImpersonatorID
+		FROM Sessions s
+		WHERE s.UserID = @p1 AND s.RevokedAt IS NULL AND s.ExpiresAt > GETUTCDATE()
+		ORDER BY s.CreatedAt DESC
+	`
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []models.SessionInfo{}
+	for rows.Next() {
+		var s models.SessionInfo
+		if err := rows.Scan(&s.ID, &s.CreatedAt, &s.ExpiresAt, &s.LastUsedAt, &s.ImpersonatedBy); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *SessionRepository) CreateRefreshToken(token *models.RefreshToken) error {
+	query := `
+		INSERT INTO RefreshTokens (SessionID, TokenHash, ExpiresAt)
+		OUTPUT INSERTED.ID, INSERTED.CreatedAt
+		VALUES (@p1, @p2, @p3)
+	`
+	err := r.DB.QueryRow(query, token.SessionID, token.TokenHash, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) GetRefreshTokenByHash(hash string) (*models.RefreshToken, error) {
+	token := &models.RefreshToken{}
+	query := `
+		SELECT ID, SessionID, TokenHash, ExpiresAt, UsedAt, CreatedAt
+		FROM RefreshTokens
+		WHERE TokenHash = @p1
+	`
+	err := r.DB.QueryRow(query, hash).Scan(&token.ID, &token.SessionID, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	return token, nil
+}
+
+// MarkRefreshTokenUsed flags a token as rotated. It returns false if the token
+// had already been used, which means it is being replayed.
+func (r *SessionRepository) MarkRefreshTokenUsed(id int) (bool, error) {
+	query := "UPDATE RefreshTokens SET UsedAt = GETUTCDATE() WHERE ID = @p1 AND UsedAt IS NULL"
+	res, err := r.DB.Exec(query, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark refresh token used: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
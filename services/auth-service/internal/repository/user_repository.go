@@ -3,6 +3,8 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
 )
@@ -16,31 +18,56 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 }
 
 func (r *UserRepository) CreateUser(user *models.User) error {
+	return insertUser(r.DB, user)
+}
+
+// rowQuerier is a *sql.DB or a *sql.Tx.
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func insertUser(q rowQuerier, user *models.User) error {
 	query := `
-		INSERT INTO Users (Username, PasswordHash, Role)
+		INSERT INTO Users (Username, PasswordHash, Role, Email, EmailVerified, DirectoryID, Source, AdmittedByDomain)
 		OUTPUT INSERTED.ID, INSERTED.CreatedAt
-		VALUES (@p1, @p2, @p3)
+		VALUES (@p1, @p2, @p3, @p4, @p5, @p6, @p7, @p8)
 	`
-	// Use sql.Named args if driver supports it, or just ?/param placeholders depending on driver
-	// mssql driver uses @p1, @p2 or ?
-	// Let's use standard ? for simplicity if supported, or named args.
-	// The go-mssqldb driver supports named parameters.
-	
-	err := r.DB.QueryRow(query, user.Username, user.PasswordHash, user.Role).Scan(&user.ID, &user.CreatedAt)
+	err := q.QueryRow(query, user.Username, user.PasswordHash, user.Role, nullString(user.Email),
+		user.EmailVerified, nullString(user.DirectoryID), user.Source, user.AdmittedByDomain).Scan(&user.ID, &user.CreatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 	return nil
 }
 
-func (r *UserRepository) GetUserByUsername(username string) (*models.User, error) {
+// userColumns must stay in sync with scanUser
+const userColumns = "ID, Username, PasswordHash, Role, Email, EmailVerified, TOTPEnabled, TOTPSecret, TOTPLastStep, DisabledAt, CreatedAt, DisplayName, DeletionScheduledAt, DeletedAt, DirectoryID, Source, AdmittedByDomain"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
 	user := &models.User{}
-	query := `
-		SELECT ID, Username, PasswordHash, Role, CreatedAt
-		FROM Users
-		WHERE Username = @p1
-	`
-	err := r.DB.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.CreatedAt)
+	var email, totpSecret, displayName, directoryID sql.NullString
+	var totpLastStep sql.NullInt64
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &email, &user.EmailVerified,
+		&user.TOTPEnabled, &totpSecret, &totpLastStep, &user.DisabledAt, &user.CreatedAt, &displayName,
+		&user.DeletionScheduledAt, &user.DeletedAt, &directoryID, &user.Source, &user.AdmittedByDomain)
+	if err != nil {
+		return nil, err
+	}
+	user.Email = email.String
+	user.DisplayName = displayName.String
+	user.TOTPSecret = totpSecret.String
+	user.TOTPLastStep = totpLastStep.Int64
+	user.DirectoryID = directoryID.String
+	return user, nil
+}
+
+func (r *UserRepository) getUser(where string, arg interface{}) (*models.User, error) {
+	query := "SELECT " + userColumns + " FROM Users WHERE " + where
+	user, err := scanUser(r.DB.QueryRow(query, arg))
 	if err == sql.ErrNoRows {
 		return nil, nil // Not found
 	}
@@ -49,3 +76,351 @@ func (r *UserRepository) GetUserByUsername(username string) (*models.User, error
 	}
 	return user, nil
 }
+
+func (r *UserRepository) GetUserByUsername(username string) (*models.User, error) {
+	return r.getUser("Username = @p1", username)
+}
+
+func (r *UserRepository) GetUserByID(id int) (*models.User, error) {
+	return r.getUser("ID = @p1", id)
+}
+
+func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
+	return r.getUser("Email = @p1", email)
+}
+
+func (r *UserRepository) UpdatePassword(userID int, passwordHash string) error {
+	query := "UPDATE Users SET PasswordHash = @p1 WHERE ID = @p2"
+	if _, err := r.DB.Exec(query, passwordHash, userID); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	return nil
+}
+
+// ReplacePasswordHash swaps the hash only if it is still oldHash, so a
+// password changed in the meantime is not overwritten.
+func (r *UserRepository) ReplacePasswordHash(userID int, oldHash, newHash string) (bool, error) {
+	query := "UPDATE Users SET PasswordHash = @p1 WHERE ID = @p2 AND PasswordHash = @p3"
+	res, err := r.DB.Exec(query, newHash, userID, oldHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to update password: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// SetEmail stores a new address. Callers decide whether it is already verified.
+func (r *UserRepository) SetEmail(userID int, email string, verified bool) error {
+	query := "UPDATE Users SET Email = @p1, EmailVerified = @p2 WHERE ID = @p3"
+	if _, err := r.DB.Exec(query, nullString(email), verified, userID); err != nil {
+		return fmt.Errorf("failed to update email: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdentifiers frees the username and email of an account that is kept
+// after its provider removed it, so the provider can create the same user
+// again. The old username stays visible as the display name.
+func (r *UserRepository) ReleaseIdentifiers(userID int) error {
+	// '#' cannot appear in a valid username, so nobody can take the placeholder
+	query := `
+		UPDATE Users SET Username = CONCAT('removed#', ID), DisplayName = COALESCE(DisplayName, Username),
+			Email = NULL, EmailVerified = 0
+		WHERE ID = @p1
+	`
+	if _, err := r.DB.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to release username and email: %w", err)
+	}
+	return nil
+}
+
+// MarkEmailVerified only succeeds while the account still has that address,
+// so a link sent to an old address cannot verify a newer one.
+func (r *UserRepository) MarkEmailVerified(userID int, email string) (bool, error) {
+	query := "UPDATE Users SET EmailVerified = 1 WHERE ID = @p1 AND Email = @p2"
+	res, err := r.DB.Exec(query, userID, email)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify email: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// SetPendingTOTPSecret stores a secret for enrollment without enabling 2FA yet.
+func (r *UserRepository) SetPendingTOTPSecret(userID int, secret string) error {
+	query := "UPDATE Users SET TOTPSecret = @p1, TOTPEnabled = 0, TOTPLastStep = NULL WHERE ID = @p2"
+	if _, err := r.DB.Exec(query, secret, userID); err != nil {
+		return fmt.Errorf("failed to store totp secret: %w", err)
+	}
+	return nil
+}
+
+// ReplaceTOTPSecret swaps the stored secret for the same one sealed again,
+// unless it changed since it was read.
+func (r *UserRepository) ReplaceTOTPSecret(userID int, old, sealed string) error {
+	query := "UPDATE Users SET TOTPSecret = @p1 WHERE ID = @p2 AND TOTPSecret = @p3"
+	if _, err := r.DB.Exec(query, sealed, userID, old); err != nil {
+		return fmt.Errorf("failed to update totp secret: %w", err)
+	}
+	return nil
+}
+
+func (r *UserRepository) EnableTOTP(userID int) error {
+	query := "UPDATE Users SET TOTPEnabled = 1 WHERE ID = @p1 AND TOTPSecret IS NOT NULL"
+	if _, err := r.DB.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to enable totp: %w", err)
+	}
+	return nil
+}
+
+func (r *UserRepository) DisableTOTP(userID int) error {
+	query := "UPDATE Users SET TOTPEnabled = 0, TOTPSecret = NULL, TOTPLastStep = NULL WHERE ID = @p1"
+	if _, err := r.DB.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to disable totp: %w", err)
+	}
+	return nil
+}
+
+// ClaimTOTPStep records a used time step. It returns false if that step (or
+// a later one) was already used, i.e. the code is being replayed.
+func (r *UserRepository) ClaimTOTPStep(userID int, step int64) (bool, error) {
+	query := "UPDATE Users SET TOTPLastStep = @p1 WHERE ID = @p2 AND (TOTPLastStep IS NULL OR TOTPLastStep < @p1)"
+	res, err := r.DB.Exec(query, step, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record totp step: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// ListUsers returns one page of users ordered by ID, plus the total number of matches.
+func (r *UserRepository) ListUsers(req *models.ListUsersRequest) ([]models.User, int, error) {
+	where := "DeletedAt IS NULL"
+	var args []interface{}
+	if req.Query != "" {
+		args = append(args, "%"+escapeLike(req.Query)+"%")
+		where += fmt.Sprintf(" AND (Username LIKE @p%d ESCAPE '\\' OR Email LIKE @p%d ESCAPE '\\')", len(args), len(args))
+	}
+	if req.Role != "" {
+		args = append(args, req.Role)
+		where += fmt.Sprintf(" AND Role = @p%d", len(args))
+	}
+
+	var total int
+	if err := r.DB.QueryRow("SELECT COUNT(*) FROM Users WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	args = append(args, (req.Page-1)*req.PageSize, req.PageSize)
+	query := fmt.Sprintf("SELECT %s FROM Users WHERE %s ORDER BY ID OFFSET @p%d ROWS FETCH NEXT @p%d ROWS ONLY",
+		userColumns, where, len(args)-1, len(args))
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, *user)
+	}
+	return users, total, rows.Err()
+}
+
+func (r *UserRepository) SetRole(userID int, role string) error {
+	if _, err := r.DB.Exec("UPDATE Users SET Role = @p1 WHERE ID = @p2", role, userID); err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+	return nil
+}
+
+func (r *UserRepository) SetDisabled(userID int, disabled bool) error {
+	query := "UPDATE Users SET DisabledAt = NULL WHERE ID = @p1"
+	if disabled {
+		query = "UPDATE Users SET DisabledAt = GETUTCDATE() WHERE ID = @p1 AND DisabledAt IS NULL"
+	}
+	if _, err := r.DB.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to update user status: %w", err)
+	}
+	return nil
+}
+
+func (r *UserRepository) SetUsername(userID int, username string) error {
+	if _, err := r.DB.Exec("UPDATE Users SET Username = @p1 WHERE ID = @p2", username, userID); err != nil {
+		return fmt.Errorf("failed to update username: %w", err)
+	}
+	return nil
+}
+
+// SetDirectoryID ties an account the directory created before directory IDs
+// were stored to its entry. It returns false if the account already belongs
+// to one or was not created by the directory.
+func (r *UserRepository) SetDirectoryID(userID int, directoryID string) (bool, error) {
+	query := "UPDATE Users SET DirectoryID = @p1 WHERE ID = @p2 AND DirectoryID IS NULL AND Source = @p3"
+	res, err := r.DB.Exec(query, directoryID, userID, models.SourceDirectory)
+	if err != nil {
+		return false, fmt.Errorf("failed to update directory id: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *UserRepository) SetDisplayName(userID int, displayName string) error {
+	if _, err := r.DB.Exec("UPDATE Users SET DisplayName = @p1 WHERE ID = @p2", nullString(displayName), userID); err != nil {
+		return fmt.Errorf("failed to update display name: %w", err)
+	}
+	return nil
+}
+
+// ScheduleDeletion marks the account for deletion at the given time. It
+// returns false if the account is already deleted.
+func (r *UserRepository) ScheduleDeletion(userID int, at time.Time) (bool, error) {
+	query := "UPDATE Users SET DeletionScheduledAt = @p1 WHERE ID = @p2 AND DeletedAt IS NULL"
+	res, err := r.DB.Exec(query, at, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to schedule deletion: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// CancelDeletion returns false if no deletion was pending.
+func (r *UserRepository) CancelDeletion(userID int) (bool, error) {
+	query := "UPDATE Users SET DeletionScheduledAt = NULL WHERE ID = @p1 AND DeletionScheduledAt IS NOT NULL AND DeletedAt IS NULL"
+	res, err := r.DB.Exec(query, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel deletion: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// ListDueDeletions returns the IDs of accounts whose grace period has ended.
+func (r *UserRepository) ListDueDeletions(now time.Time) ([]int, error) {
+	query := "SELECT ID FROM Users WHERE DeletionScheduledAt <= @p1 AND DeletedAt IS NULL ORDER BY DeletionScheduledAt"
+	rows, err := r.DB.Query(query, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due deletions: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Tables holding personal data that goes with the account, child tables first
+var userOwnedRows = []string{
+	"DELETE rt FROM RefreshTokens rt JOIN Sessions s ON s.ID = rt.SessionID WHERE s.UserID = @p1",
+	"DELETE FROM Sessions WHERE UserID = @p1",
+	"DELETE FROM ApiKeys WHERE UserID = @p1",
+	"DELETE FROM UserTokens WHERE UserID = @p1",
+	"DELETE FROM EmailChanges WHERE UserID = @p1",
+	"DELETE FROM RecoveryCodes WHERE UserID = @p1",
+	"DELETE FROM ExternalIdentities WHERE UserID = @p1",
+	"DELETE FROM OIDCStates WHERE UserID = @p1",
+	"DELETE FROM OrgMembers WHERE UserID = @p1",
+	"DELETE FROM ScimGroupMembers WHERE UserID = @p1",
+	"DELETE FROM ScimUsers WHERE UserID = @p1",
+	"DELETE FROM DataExports WHERE UserID = @p1",
+}
+
+// PurgeUser deletes everything that belongs to the account and anonymizes the
+// Users row, which stays so organizations and invites created by the user keep
+// a valid reference. The event is inserted in the same transaction, so it is
+// published if and only if the deletion commits.
+func (r *UserRepository) PurgeUser(userID int, event *models.UserEvent) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Organizations the user owns alone pass to the most senior remaining member
+	succession := `
+		UPDATE m SET Role = 'owner'
+		FROM OrgMembers m
+		JOIN OrgMembers leaving ON leaving.OrgID = m.OrgID AND leaving.UserID = @p1 AND leaving.Role = 'owner'
+		WHERE NOT EXISTS (SELECT 1 FROM OrgMembers o WHERE o.OrgID = m.OrgID AND o.Role = 'owner' AND o.UserID <> @p1)
+			AND m.UserID = (
+				SELECT TOP 1 c.UserID FROM OrgMembers c
+				WHERE c.OrgID = m.OrgID AND c.UserID <> @p1
+				ORDER BY CASE c.Role WHEN 'admin' THEN 0 WHEN 'editor' THEN 1 ELSE 2 END, c.CreatedAt, c.UserID
+			)
+	`
+	if _, err := tx.Exec(succession, userID); err != nil {
+		return fmt.Errorf("failed to transfer organization ownership: %w", err)
+	}
+
+	for _, query := range userOwnedRows {
+		if _, err := tx.Exec(query, userID); err != nil {
+			return fmt.Errorf("failed to delete user data: %w", err)
+		}
+	}
+
+	// '#' cannot appear in a valid username, so nobody can take the placeholder
+	anonymize := `
+		UPDATE Users SET Username = CONCAT('deleted#', ID), PasswordHash = '', Email = NULL, EmailVerified = 0,
+			DisplayName = NULL, DirectoryID = NULL, TOTPEnabled = 0, TOTPSecret = NULL, TOTPLastStep = NULL,
+			DisabledAt = COALESCE(DisabledAt, GETUTCDATE()), DeletionScheduledAt = NULL, DeletedAt = GETUTCDATE()
+		WHERE ID = @p1 AND DeletedAt IS NULL
+	`
+	res, err := tx.Exec(anonymize, userID)
+	if err != nil {
+		return fmt.Errorf("failed to anonymize user: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return fmt.Errorf("user %d is already deleted", userID)
+	}
+
+	publish := `
+		INSERT INTO UserEvents (EventType, UserID)
+		OUTPUT INSERTED.ID, INSERTED.CreatedAt
+		VALUES (@p1, @p2)
+	`
+	if err := tx.QueryRow(publish, event.Type, event.UserID).Scan(&event.ID, &event.CreatedAt); err != nil {
+		return fmt.Errorf("failed to publish user event: %w", err)
+	}
+	return tx.Commit()
+}
+
+// escapeLike makes user input match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`, "[", `\[`).Replace(s)
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
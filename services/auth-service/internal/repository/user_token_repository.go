@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+type UserTokenRepository struct {
+	DB *sql.DB
+}
+
+func NewUserTokenRepository(db *sql.DB) *UserTokenRepository {
+	return &UserTokenRepository{DB: db}
+}
+
+func (r *UserTokenRepository) CreateToken(token *models.UserToken) error {
+	query := `
+		INSERT INTO UserTokens (UserID, Purpose, TokenHash, Data, ExpiresAt)
+		OUTPUT INSERTED.ID, INSERTED.CreatedAt
+		VALUES (@p1, @p2, @p3, @p4, @p5)
+	`
+	err := r.DB.QueryRow(query, token.UserID, token.Purpose, token.TokenHash, nullString(token.Data), token.ExpiresAt).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create token: %w", err)
+	}
+	return nil
+}
+
+func (r *UserTokenRepository) GetToken(purpose, hash string) (*models.UserToken, error) {
+	token := &models.UserToken{}
+	var data sql.NullString
+	query := `
+		SELECT ID, UserID, Purpose, TokenHash, Data, ExpiresAt, UsedAt, CreatedAt
+		FROM UserTokens
+		WHERE Purpose = @p1 AND TokenHash = @p2
+	`
+	err := r.DB.QueryRow(query, purpose, hash).Scan(&token.ID, &token.UserID, &token.Purpose, &token.TokenHash, &data, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+	token.Data = data.String
+	return token, nil
+}
+
+// ConsumeToken marks a token used. It returns false if it was already used.
+func (r *UserTokenRepository) ConsumeToken(id int) (bool, error) {
+	res, err := r.DB.Exec("UPDATE UserTokens SET UsedAt = GETUTCDATE() WHERE ID = @p1 AND UsedAt IS NULL", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume token: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// InvalidateTokens burns every outstanding token of a purpose for a user.
+func (r *UserTokenRepository) InvalidateTokens(userID int, purpose string) error {
+	query := "UPDATE UserTokens SET UsedAt = GETUTCDATE() WHERE UserID = @p1 AND Purpose = @p2 AND UsedAt IS NULL"
+	if _, err := r.DB.Exec(query, userID, purpose); err != nil {
+		return fmt.Errorf("failed to invalidate tokens: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Package scim holds the SCIM 2.0 pieces that are not tied to storage:
+// tenant configuration and filter parsing.
+package scim
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+// GroupRole gives members of a SCIM group a role.
+type GroupRole struct {
+	Group string // Group displayName
+	Role  string
+}
+
+// Tenant is an identity provider allowed to provision accounts. Each tenant
+// only sees the accounts and groups it created.
+type Tenant struct {
+	Name        string
+	Token       string      // Bearer token the provider sends
+	GroupRoles  []GroupRole // First matching group wins; empty leaves roles to admins
+	DefaultRole string      // Role for new accounts, and for members of no mapped group
+}
+
+// RoleFor picks the role for a user in the given groups, or "" when the
+// tenant does not manage roles.
+func (t *Tenant) RoleFor(groups []string) string {
+	if len(t.GroupRoles) == 0 {
+		return ""
+	}
+	for _, mapping := range t.GroupRoles {
+		for _, g := range groups {
+			if strings.EqualFold(g, mapping.Group) {
+				return mapping.Role
+			}
+		}
+	}
+	return t.DefaultRole
+}
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// ParseFilter understands `attribute eq "value"` (and unquoted true/false).
+// Anything richer is rejected rather than silently ignored, since a lookup
+// that matches everything would make the provider think the user exists.
+func ParseFilter(filter string) (*models.ScimFilter, error) {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return nil, nil
+	}
+	attr, rest, ok := strings.Cut(filter, " ")
+	if !ok {
+		return nil, ErrInvalidFilter
+	}
+	op, value, ok := strings.Cut(strings.TrimSpace(rest), " ")
+	if !ok || !strings.EqualFold(op, "eq") {
+		return nil, ErrInvalidFilter
+	}
+
+	value = strings.TrimSpace(value)
+	switch {
+	case strings.HasPrefix(value, `"`):
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, ErrInvalidFilter
+		}
+		value = unquoted
+	case strings.EqualFold(value, "true"), strings.EqualFold(value, "false"):
+		value = strings.ToLower(value)
+	default:
+		return nil, ErrInvalidFilter
+	}
+
+	// The core schema URN may prefix the attribute
+	attr = strings.ToLower(attr)
+	if i := strings.LastIndex(attr, ":"); i >= 0 {
+		attr = attr[i+1:]
+	}
+	return &models.ScimFilter{Attribute: attr, Value: value}, nil
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
+)
+
+// AccountDeletionService schedules account deletions and carries them out
+// once the grace period has passed. Each deletion publishes a user.deleted
+// event that link-management uses to remove the user's links.
+type AccountDeletionService struct {
+	Auth  *AuthService
+	Scim  *repository.ScimRepository
+	Grace time.Duration
+}
+
+func NewAccountDeletionService(auth *AuthService, scimRepo *repository.ScimRepository, grace time.Duration) *AccountDeletionService {
+	return &AccountDeletionService{Auth: auth, Scim: scimRepo, Grace: grace}
+}
+
+// How recently a user without a password must have signed in to delete
+// their account
+const deletionReauthWindow = 10 * time.Minute
+
+// RequestDeletion schedules the caller's own account for deletion and signs
+// them out everywhere. Logging in again during the grace period is allowed,
+// so they can still cancel. Users with a password confirm with it; users who
+// only sign in through a provider confirm by having signed in recently, in
+// the session sessionID.
+func (s *AccountDeletionService) RequestDeletion(userID int, sessionID, password string) (*models.User, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	// The provisioning system owns the account and would only recreate it
+	managed, err := s.Scim.IsProvisioned(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if managed {
+		return nil, errors.New("account managed by directory")
+	}
+	if err := s.confirm(user, sessionID, password); err != nil {
+		return nil, err
+	}
+
+	if err := s.schedule(user.ID, time.Now().UTC().Add(s.Grace)); err != nil {
+		return nil, err
+	}
+	log.Printf("User %d requested deletion of their account", user.ID)
+	if err := s.Auth.Sessions.RevokeUserSessions(user.ID); err != nil {
+		return nil, err
+	}
+	return s.getUser(user.ID)
+}
+
+func (s *AccountDeletionService) confirm(user *models.User, sessionID, password string) error {
+	if user.PasswordHash != "" || user.FromDirectory() {
+		return s.Auth.VerifyPassword(user, password)
+	}
+	session, err := s.Auth.Sessions.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.UserID != user.ID || session.RevokedAt != nil ||
+		time.Since(session.CreatedAt) > deletionReauthWindow {
+		return errors.New("reauthentication required")
+	}
+	return nil
+}
+
+// CancelDeletion keeps the account. Admins use it for accounts they
+// scheduled; those stay disabled until an admin enables them.
+func (s *AccountDeletionService) CancelDeletion(userID int) (*models.User, error) {
+	if _, err := s.getUser(userID); err != nil {
+		return nil, err
+	}
+	ok, err := s.Auth.Repo.CancelDeletion(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("no deletion pending")
+	}
+	log.Printf("Deletion of user %d cancelled", userID)
+	return s.getUser(userID)
+}
+
+// DeleteUser is the admin side. The account is disabled right away, so the
+// user cannot sign in and cancel, and deleted after the grace period, or at
+// once when immediate is set.
+func (s *AccountDeletionService) DeleteUser(adminID, userID int, immediate bool) (*models.User, error) {
+	if adminID == userID {
+		return nil, errors.New("cannot modify own account")
+	}
+	if _, err := s.getUser(userID); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Admin %d scheduled deletion of user %d (immediate=%t)", adminID, userID, immediate)
+	if err := s.Auth.Repo.SetDisabled(userID, true); err != nil {
+		return nil, err
+	}
+	if err := s.Auth.Sessions.RevokeUserSessions(userID); err != nil {
+		return nil, err
+	}
+	if !immediate {
+		if err := s.schedule(userID, time.Now().UTC().Add(s.Grace)); err != nil {
+			return nil, err
+		}
+		return s.getUser(userID)
+	}
+
+	if err := s.purge(userID); err != nil {
+		return nil, err
+	}
+	return s.Auth.Repo.GetUserByID(userID)
+}
+
+// Run deletes accounts whose grace period has ended, checking every interval.
+func (s *AccountDeletionService) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.PurgeDue(); err != nil {
+			log.Printf("Failed to delete scheduled accounts: %v", err)
+		}
+	}
+}
+
+// PurgeDue deletes every account that is due. A failure is logged and the
+// account is retried on the next run.
+func (s *AccountDeletionService) PurgeDue() error {
+	due, err := s.Auth.Repo.ListDueDeletions(time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	for _, userID := range due {
+		if err := s.purge(userID); err != nil {
+			log.Printf("Failed to delete user %d: %v", userID, err)
+			continue
+		}
+		s.Auth.Events.Record(&models.SecurityEvent{Type: models.EventAccountDelete, Outcome: models.OutcomeSuccess, UserID: &userID, Detail: "source=schedule"})
+	}
+	return nil
+}
+
+func (s *AccountDeletionService) schedule(userID int, at time.Time) error {
+	ok, err := s.Auth.Repo.ScheduleDeletion(userID, at)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+func (s *AccountDeletionService) purge(userID int) error {
+	event := &models.UserEvent{Type: models.UserEventDeleted, UserID: userID}
+	if err := s.Auth.Repo.PurgeUser(userID, event); err != nil {
+		return err
+	}
+	log.Printf("Deleted user %d, published event %d", userID, event.ID)
+	return nil
+}
+
+// getUser treats deleted accounts as missing.
+func (s *AccountDeletionService) getUser(userID int) (*models.User, error) {
+	user, err := s.Auth.Repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Deleted() {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
+)
+
+// AccountService backs the /api/auth/me endpoints people use to manage their own account.
+type AccountService struct {
+	Auth *AuthService
+	Scim *repository.ScimRepository
+}
+
+func NewAccountService(auth *AuthService, scimRepo *repository.ScimRepository) *AccountService {
+	return &AccountService{Auth: auth, Scim: scimRepo}
+}
+
+func (s *AccountService) GetProfile(userID int) (*models.User, error) {
+	user, err := s.Auth.Repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+// UpdateProfile applies the fields present in req. A new username replaces
+// the caller's session, so it returns fresh tokens and the old ones stop
+// working; other changes return no tokens.
+func (s *AccountService) UpdateProfile(userID int, sessionID string, req *models.UpdateProfileRequest) (*models.User, *models.TokenPair, error) {
+	user, err := s.GetProfile(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if req.DisplayName != nil {
+		displayName := strings.TrimSpace(*req.DisplayName)
+		if displayName != user.DisplayName {
+			if err := s.Auth.Repo.SetDisplayName(user.ID, displayName); err != nil {
+				return nil, nil, err
+			}
+			user.DisplayName = displayName
+		}
+	}
+
+	if req.Username == nil || strings.TrimSpace(*req.Username) == user.Username {
+		return user, nil, nil
+	}
+	username := strings.TrimSpace(*req.Username)
+	// The provisioning system would rename it back, or lose track of the
+	// account; a directory account renamed away would free its name
+	if user.FromDirectory() {
+		return nil, nil, errors.New("username managed by directory")
+	}
+	managed, err := s.Scim.IsProvisioned(user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if managed {
+		return nil, nil, errors.New("username managed by directory")
+	}
+	if err := s.Auth.validateUsername(username); err != nil {
+		return nil, nil, err
+	}
+	existing, err := s.Auth.Repo.GetUserByUsername(username)
+	if err != nil {
+		return nil, nil, err
+	}
+	if existing != nil && existing.ID != user.ID {
+		return nil, nil, errors.New("username already exists")
+	}
+
+	log.Printf("User %d changed username from %s to %s", user.ID, user.Username, username)
+	if err := s.Auth.Repo.SetUsername(user.ID, username); err != nil {
+		return nil, nil, err
+	}
+	user.Username = username
+
+	if sessionID != "" {
+		if err := s.Auth.Sessions.RevokeSession(sessionID); err != nil {
+			return nil, nil, err
+		}
+	}
+	tokens, err := s.Auth.startSession(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// ListSessions marks the session the request was made with as current.
+func (s *AccountService) ListSessions(userID int, currentSessionID string) ([]models.SessionInfo, error) {
+	sessions, err := s.Auth.Sessions.ListActiveSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs one of the user's devices out.
+func (s *AccountService) RevokeSession(userID int, sessionID string) error {
+	session, err := s.Auth.Sessions.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.UserID != userID || session.RevokedAt != nil {
+		return errors.New("session not found")
+	}
+	return s.Auth.Sessions.RevokeSession(sessionID)
+}
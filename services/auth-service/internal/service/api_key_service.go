@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
+)
+
+// API keys look like usk_<prefix>_<secret> so they are easy to spot in
+// logs and secret scanners, and link-management can tell them from JWTs.
+const apiKeyPrefix = "usk_"
+
+type ApiKeyService struct {
+	Repo *repository.ApiKeyRepository
+}
+
+func NewApiKeyService(repo *repository.ApiKeyRepository) *ApiKeyService {
+	return &ApiKeyService{Repo: repo}
+}
+
+func (s *ApiKeyService) CreateApiKey(userID int, req *models.CreateApiKeyRequest) (*models.CreateApiKeyResponse, error) {
+	if len(req.Scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		if !validScope(scope) {
+			return nil, fmt.Errorf("invalid scope: %s", scope)
+		}
+	}
+	if req.ExpiresInDays < 0 {
+		return nil, errors.New("expires_in_days must not be negative")
+	}
+
+	id, err := randomToken(6)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	prefix := apiKeyPrefix + id
+	plaintext := prefix + "_" + secret
+
+	key := &models.ApiKey{
+		UserID:  userID,
+		Name:    req.Name,
+		Prefix:  prefix,
+		KeyHash: hashToken(plaintext),
+		Scopes:  req.Scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		t := time.Now().UTC().AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &t
+	}
+
+	if err := s.Repo.CreateApiKey(key); err != nil {
+		return nil, err
+	}
+
+	return &models.CreateApiKeyResponse{Key: plaintext, ApiKey: *key}, nil
+}
+
+func (s *ApiKeyService) ListApiKeys(userID int) ([]models.ApiKey, error) {
+	return s.Repo.GetApiKeysByUserID(userID)
+}
+
+func (s *ApiKeyService) RevokeApiKey(id, userID int) error {
+	found, err := s.Repo.RevokeApiKey(id, userID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("api key not found")
+	}
+	return nil
+}
+
+func validScope(scope string) bool {
+	for _, s := range models.ApiKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
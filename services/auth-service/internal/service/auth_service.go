@@ -2,25 +2,87 @@ package service
 
 import (
 	"errors"
+	"log"
+	"regexp"
+	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/authn"
 	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/config"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/keys"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/lockout"
 	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/password"
 	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthService struct {
-	Repo   *repository.UserRepository
-	Config *config.Config
+	Repo          *repository.UserRepository
+	Sessions      *repository.SessionRepository
+	Tokens        *repository.UserTokenRepository
+	Roles         *repository.RoleRepository
+	Keys          *keys.Keyring
+	Guard         *lockout.Guard
+	Authenticator authn.Authenticator
+	Passwords     *password.Hasher
+	Events        *SecurityEventService
+	Config        *config.Config
 }
 
-func NewAuthService(repo *repository.UserRepository, cfg *config.Config) *AuthService {
-	return &AuthService{Repo: repo, Config: cfg}
+// How long a user has to enter their 2FA code after the password step
+const mfaChallengeTTL = 5 * time.Minute
+
+func NewAuthService(repo *repository.UserRepository, sessions *repository.SessionRepository, tokens *repository.UserTokenRepository, roles *repository.RoleRepository, keyring *keys.Keyring, guard *lockout.Guard, authenticator authn.Authenticator, passwords *password.Hasher, events *SecurityEventService, cfg *config.Config) *AuthService {
+	return &AuthService{Repo: repo, Sessions: sessions, Tokens: tokens, Roles: roles, Keys: keyring, Guard: guard, Authenticator: authenticator, Passwords: passwords, Events: events, Config: cfg}
+}
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,50}$`)
+
+// validateUsername applies the rules for names people pick themselves.
+// Accounts created by SSO, LDAP or SCIM keep the name their directory uses,
+// and names the directory has are kept for it.
+func (s *AuthService) validateUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return errors.New("invalid username")
+	}
+	for _, reserved := range s.Config.ReservedUsernames {
+		if strings.EqualFold(username, reserved) {
+			return errors.New("username reserved")
+		}
+	}
+	if directory, ok := s.Authenticator.(authn.Directory); ok {
+		taken, err := directory.HasUser(username)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return errors.New("username reserved")
+		}
+	}
+	return nil
 }
 
 func (s *AuthService) Register(req *models.RegisterRequest) (*models.User, error) {
+	user, err := s.newUser(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.CreateUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// newUser checks a sign-up and returns the account to create.
+func (s *AuthService) newUser(req *models.RegisterRequest) (*models.User, error) {
+	if err := s.validateUsername(req.Username); err != nil {
+		return nil, err
+	}
+
+	if err := s.CheckPassword(req.Password, req.Username); err != nil {
+		return nil, err
+	}
+
 	// Check if user exists
 	existing, err := s.Repo.GetUserByUsername(req.Username)
 	if err != nil {
@@ -30,49 +92,231 @@ func (s *AuthService) Register(req *models.RegisterRequest) (*models.User, error
 		return nil, errors.New("username already exists")
 	}
 
+	email := normalizeEmail(req.Email)
+	if email != "" {
+		taken, err := s.Repo.GetUserByEmail(email)
+		if err != nil {
+			return nil, err
+		}
+		if taken != nil {
+			return nil, errors.New("email already in use")
+		}
+	}
+
 	// Hash password
-	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashed, err := s.Passwords.Hash(req.Password)
 	if err != nil {
 		return nil, err
 	}
 
-	user := &models.User{
+	return &models.User{
 		Username:     req.Username,
-		PasswordHash: string(hashed),
+		PasswordHash: hashed,
 		Role:         "User", // Default role
+		Email:        email,
+		Source:       models.SourceLocal,
+	}, nil
+}
+
+// Login checks the password with the configured authenticator. Users with
+// 2FA enabled get an MFA challenge to complete through
+// MFAService.CompleteLogin instead of tokens. Once the account or the client
+// IP has failed too often it returns a *lockout.LockedError without looking
+// at the password. Directory users get a local account on first login.
+func (s *AuthService) Login(req *models.LoginRequest, ip string) (*models.LoginResult, error) {
+	if err := s.Guard.Check(req.Username, ip); err != nil {
+		return nil, err
 	}
 
-	if err := s.Repo.CreateUser(user); err != nil {
+	user, err := s.Repo.GetUserByUsername(req.Username)
+	if err != nil {
 		return nil, err
 	}
+	// The lookup may match a differently spelled name; failures are counted
+	// under the stored one, so check that too
+	if user != nil && user.Username != req.Username {
+		if err := s.Guard.Check(user.Username, ""); err != nil {
+			return nil, err
+		}
+	}
 
-	return user, nil
+	// Unknown usernames count too, so probing for accounts gets throttled the same way
+	identity, err := s.Authenticator.Authenticate(user, req.Username, req.Password)
+	if errors.Is(err, authn.ErrInvalidCredentials) {
+		name := req.Username
+		if user != nil {
+			name = user.Username
+		}
+		return nil, s.loginFailed(name, ip, errors.New("invalid credentials"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err = s.syncIdentity(user, identity)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Guard.Succeed(user.Username); err != nil {
+		return nil, err
+	}
+	if identity.Rehash {
+		s.rehashPassword(user, req.Password)
+	}
+	// Checked after the password so the response does not reveal the account state to guessers
+	return s.signIn(user)
 }
 
-func (s *AuthService) Login(req *models.LoginRequest) (string, *models.User, error) {
-	user, err := s.Repo.GetUserByUsername(req.Username)
+// signIn finishes a first-factor login: users with 2FA get an MFA challenge,
+// everyone else a session.
+func (s *AuthService) signIn(user *models.User) (*models.LoginResult, error) {
+	if user.Disabled() {
+		return nil, errors.New("account disabled")
+	}
+	if user.AwaitingVerification() {
+		return nil, errors.New("email not verified")
+	}
+
+	if user.TOTPEnabled {
+		token, err := createUserToken(s.Tokens, user.ID, models.TokenPurposeMFAChallenge, "", mfaChallengeTTL)
+		if err != nil {
+			return nil, err
+		}
+		return &models.LoginResult{
+			User: user,
+			Challenge: &models.MFAChallenge{
+				MFARequired: true,
+				MFAToken:    token,
+				ExpiresIn:   int64(mfaChallengeTTL.Seconds()),
+			},
+		}, nil
+	}
+
+	tokens, err := s.startSession(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.LoginResult{Tokens: tokens, User: user}, nil
+}
+
+// CheckPassword applies the password policy to a new password. Rule
+// violations come back as a *password.PolicyError.
+func (s *AuthService) CheckPassword(pw, username string) error {
+	return s.Config.PasswordPolicy.Check(pw, username)
+}
+
+// rehashPassword replaces an outdated hash now that the password is known.
+// It only logs failures since the old hash keeps working, and it leaves the
+// hash alone if the password was changed in the meantime.
+func (s *AuthService) rehashPassword(user *models.User, password string) {
+	hashed, err := s.Passwords.Hash(password)
 	if err != nil {
-		return "", nil, err
+		log.Printf("Failed to rehash password of user %d: %v", user.ID, err)
+		return
+	}
+	ok, err := s.Repo.ReplacePasswordHash(user.ID, user.PasswordHash, hashed)
+	if err != nil {
+		log.Printf("Failed to rehash password of user %d: %v", user.ID, err)
+		return
+	}
+	if ok {
+		user.PasswordHash = hashed
 	}
+}
+
+// VerifyPassword re-checks the password of a signed-in user before sensitive
+// changes, using the same backend as Login.
+func (s *AuthService) VerifyPassword(user *models.User, password string) error {
+	if _, err := s.Authenticator.Authenticate(user, user.Username, password); err != nil {
+		if errors.Is(err, authn.ErrInvalidCredentials) {
+			return errors.New("invalid credentials")
+		}
+		return err
+	}
+	return nil
+}
+
+// syncIdentity creates the local account for a directory user on first
+// login and keeps the role in step with their group membership afterwards.
+func (s *AuthService) syncIdentity(user *models.User, identity *authn.Identity) (*models.User, error) {
+	if identity.Role != "" && (user == nil || user.Role != identity.Role) {
+		role, err := s.Roles.GetRole(identity.Role)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			log.Printf("Directory maps %s to unknown role %q", identity.Username, identity.Role)
+			return nil, errors.New("invalid credentials")
+		}
+	}
+
 	if user == nil {
-		return "", nil, errors.New("invalid credentials")
+		return s.provisionUser(identity)
+	}
+	if identity.DirectoryID != "" && !user.FromDirectory() {
+		adopted, err := s.Repo.SetDirectoryID(user.ID, identity.DirectoryID)
+		if err != nil {
+			return nil, err
+		}
+		if !adopted {
+			return nil, errors.New("invalid credentials")
+		}
+		user.DirectoryID = identity.DirectoryID
+		log.Printf("Tied user %d to directory entry %s", user.ID, identity.DirectoryID)
 	}
+	if identity.Role != "" && user.Role != identity.Role {
+		if err := s.Repo.SetRole(user.ID, identity.Role); err != nil {
+			return nil, err
+		}
+		user.Role = identity.Role
+		s.Events.Record(&models.SecurityEvent{Type: models.EventRoleChange, Outcome: models.OutcomeSuccess, UserID: &user.ID, Detail: "role=" + user.Role + " source=directory"})
+	}
+	return user, nil
+}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		return "", nil, errors.New("invalid credentials")
+// provisionUser creates an account without a local password. The email is
+// kept, and trusted as verified, only if no other account already uses it.
+func (s *AuthService) provisionUser(identity *authn.Identity) (*models.User, error) {
+	role := identity.Role
+	if role == "" {
+		role = "User"
+	}
+	email := normalizeEmail(identity.Email)
+	if email != "" {
+		taken, err := s.Repo.GetUserByEmail(email)
+		if err != nil {
+			return nil, err
+		}
+		if taken != nil {
+			email = ""
+		}
 	}
 
-	// Generate JWT
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":  user.ID,
-		"role": user.Role,
-		"exp":  time.Now().Add(time.Hour * 24).Unix(), // 24 hours
-	})
+	user := &models.User{Username: identity.Username, Role: role, Email: email, DirectoryID: identity.DirectoryID, Source: models.SourceDirectory}
+	if err := s.Repo.CreateUser(user); err != nil {
+		return nil, err
+	}
+	if email != "" {
+		if _, err := s.Repo.MarkEmailVerified(user.ID, email); err != nil {
+			return nil, err
+		}
+		user.EmailVerified = true
+	}
+	log.Printf("Provisioned directory user %s (id %d) with role %s", user.Username, user.ID, user.Role)
+	s.Events.Record(&models.SecurityEvent{Type: models.EventAccountProvision, Outcome: models.OutcomeSuccess, UserID: &user.ID, Detail: "source=directory"})
+	return user, nil
+}
 
-	tokenString, err := token.SignedString([]byte(s.Config.JWTSecret))
+// loginFailed records a failed attempt. It returns a *lockout.LockedError if
+// this failure locked the account or IP, otherwise cause.
+func (s *AuthService) loginFailed(username, ip string, cause error) error {
+	wait, err := s.Guard.Fail(username, ip)
 	if err != nil {
-		return "", nil, err
+		return err
 	}
-
-	return tokenString, user, nil
+	if wait > 0 {
+		return &lockout.LockedError{RetryAfter: wait}
+	}
+	return cause
 }
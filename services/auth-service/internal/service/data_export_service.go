@@ -0,0 +1,262 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
+)
+
+// DataExportService builds personal data exports: a ZIP of JSON and CSV files
+// with the account, its sessions, keys, identities, organizations and security
+// log, plus the links and click stats link-management contributes.
+type DataExportService struct {
+	Auth       *AuthService
+	Repo       *repository.DataExportRepository
+	ApiKeys    *repository.ApiKeyRepository
+	Identities *repository.ExternalIdentityRepository
+	Orgs       *repository.OrgRepository
+	Events     *repository.SecurityEventRepository
+	TTL        time.Duration // How long a finished archive can be downloaded
+	Timeout    time.Duration // How long an export may stay pending
+}
+
+func NewDataExportService(auth *AuthService, repo *repository.DataExportRepository, apiKeys *repository.ApiKeyRepository,
+	identities *repository.ExternalIdentityRepository, orgs *repository.OrgRepository, events *repository.SecurityEventRepository,
+	ttl, timeout time.Duration) *DataExportService {
+	return &DataExportService{Auth: auth, Repo: repo, ApiKeys: apiKeys, Identities: identities, Orgs: orgs, Events: events, TTL: ttl, Timeout: timeout}
+}
+
+// RequestExport starts an export. Only one can be in progress at a time.
+func (s *DataExportService) RequestExport(userID int) (*models.DataExport, error) {
+	exports, err := s.Repo.ListExports(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range exports {
+		if e.Status == models.ExportPending {
+			return nil, errors.New("export already in progress")
+		}
+	}
+
+	id, err := randomToken(24)
+	if err != nil {
+		return nil, err
+	}
+	export := &models.DataExport{ID: id, UserID: userID, Status: models.ExportPending}
+	if err := s.Repo.CreateExport(export); err != nil {
+		return nil, err
+	}
+	log.Printf("User %d requested data export %s", userID, id)
+	return export, nil
+}
+
+func (s *DataExportService) ListExports(userID int) ([]models.DataExport, error) {
+	return s.Repo.ListExports(userID)
+}
+
+// GetExport hides other users' exports as not found.
+func (s *DataExportService) GetExport(userID int, id string) (*models.DataExport, error) {
+	export, err := s.Repo.GetExport(id)
+	if err != nil {
+		return nil, err
+	}
+	if export == nil || export.UserID != userID {
+		return nil, errors.New("export not found")
+	}
+	return export, nil
+}
+
+// Download returns the finished archive.
+func (s *DataExportService) Download(userID int, id string) ([]byte, error) {
+	export, err := s.GetExport(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if export.Status != models.ExportReady {
+		return nil, errors.New("export not ready")
+	}
+	archive, err := s.Repo.GetArchive(id)
+	if err != nil {
+		return nil, err
+	}
+	if archive == nil {
+		return nil, errors.New("export not found")
+	}
+	return archive, nil
+}
+
+// Run assembles pending exports and deletes expired ones every interval.
+func (s *DataExportService) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.ProcessPending(); err != nil {
+			log.Printf("Failed to process data exports: %v", err)
+		}
+		if err := s.Repo.DeleteExpired(time.Now().UTC()); err != nil {
+			log.Printf("Failed to delete expired data exports: %v", err)
+		}
+	}
+}
+
+// ProcessPending finishes every export whose link part has arrived, and
+// fails those that are still pending after the timeout, whether they wait for
+// link-management or keep failing to build.
+func (s *DataExportService) ProcessPending() error {
+	exports, err := s.Repo.ListPendingExports()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, e := range exports {
+		timedOut := now.Sub(e.CreatedAt) > s.Timeout
+		linkPart, err := s.Repo.GetLinkPart(e.ID)
+		if err != nil {
+			return err
+		}
+		if linkPart == "" {
+			if timedOut {
+				log.Printf("Data export %s timed out waiting for link-management", e.ID)
+				if err := s.Repo.FailExport(e.ID, "link data unavailable", now.Add(s.TTL)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		archive, err := s.buildArchive(e.UserID, linkPart)
+		if err != nil {
+			log.Printf("Failed to build data export %s: %v", e.ID, err)
+			// Retried on the next run until the timeout, in case the error was passing
+			if timedOut {
+				if err := s.Repo.FailExport(e.ID, "export failed", now.Add(s.TTL)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := s.Repo.CompleteExport(e.ID, archive, now.Add(s.TTL)); err != nil {
+			return err
+		}
+		log.Printf("Data export %s is ready (%d bytes)", e.ID, len(archive))
+	}
+	return nil
+}
+
+func (s *DataExportService) buildArchive(userID int, linkPart string) ([]byte, error) {
+	files := map[string][]byte{}
+	var linkFiles map[string]string
+	if err := json.Unmarshal([]byte(linkPart), &linkFiles); err != nil {
+		return nil, fmt.Errorf("invalid link export: %w", err)
+	}
+	for name, content := range linkFiles {
+		files[name] = []byte(content)
+	}
+
+	user, err := s.Auth.Repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	sessions, err := s.Auth.Sessions.ListActiveSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	apiKeys, err := s.ApiKeys.GetApiKeysByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := s.Identities.ListIdentities(userID)
+	if err != nil {
+		return nil, err
+	}
+	orgs, err := s.Orgs.ListOrgsForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.securityEvents(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, v := range map[string]interface{}{
+		"account.json":         user,
+		"sessions.json":        sessions,
+		"api_keys.json":        apiKeys,
+		"identities.json":      identities,
+		"organizations.json":   orgs,
+		"security_events.json": events,
+	} {
+		if files[name], err = json.MarshalIndent(v, "", "  "); err != nil {
+			return nil, err
+		}
+	}
+
+	rows := [][]string{{"id", "type", "outcome", "actor_id", "ip", "user_agent", "detail", "created_at"}}
+	for _, e := range events {
+		actor := ""
+		if e.ActorID != nil {
+			actor = strconv.Itoa(*e.ActorID)
+		}
+		rows = append(rows, []string{strconv.FormatInt(e.ID, 10), e.Type, e.Outcome, actor, e.IP, e.UserAgent, e.Detail, e.CreatedAt.UTC().Format(time.RFC3339)})
+	}
+	var buf bytes.Buffer
+	if err := csv.NewWriter(&buf).WriteAll(rows); err != nil {
+		return nil, err
+	}
+	files["security_events.csv"] = buf.Bytes()
+
+	return zipFiles(files)
+}
+
+// securityEvents reads the user's whole security log, newest first.
+func (s *DataExportService) securityEvents(userID int) ([]models.SecurityEvent, error) {
+	all := []models.SecurityEvent{}
+	for page := 1; ; page++ {
+		events, total, err := s.Events.ListEvents(&models.SecurityEventQuery{UserID: userID, Page: page, PageSize: 200})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, events...)
+		if len(events) == 0 || len(all) >= total {
+			return all, nil
+		}
+	}
+}
+
+// zipFiles writes the files in name order so archives are easy to compare.
+func zipFiles(files map[string][]byte) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/notify"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
+)
+
+type EmailService struct {
+	Auth     *AuthService
+	Tokens   *repository.UserTokenRepository
+	Changes  *repository.EmailChangeRepository
+	Notifier notify.Notifier
+}
+
+func NewEmailService(auth *AuthService, tokens *repository.UserTokenRepository, changes *repository.EmailChangeRepository, notifier notify.Notifier) *EmailService {
+	return &EmailService{Auth: auth, Tokens: tokens, Changes: changes, Notifier: notifier}
+}
+
+// SendVerification mails a verification link to the user's current address.
+func (s *EmailService) SendVerification(user *models.User) error {
+	if user.Email == "" {
+		return errors.New("no email address on account")
+	}
+	if user.EmailVerified {
+		return errors.New("email already verified")
+	}
+
+	if err := s.Tokens.InvalidateTokens(user.ID, models.TokenPurposeEmailVerify); err != nil {
+		return err
+	}
+	token, err := createUserToken(s.Tokens, user.ID, models.TokenPurposeEmailVerify, user.Email, s.Auth.Config.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.Notifier.Send(notify.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Confirm that %s belongs to %s by opening this link:\n%s",
+			user.Email, user.Username, s.link("verify-email", token)),
+	})
+}
+
+func (s *EmailService) ResendVerification(userID int) error {
+	user, err := s.Auth.Repo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.New("user not found")
+	}
+	return s.SendVerification(user)
+}
+
+func (s *EmailService) VerifyEmail(req *models.VerifyEmailRequest) error {
+	token, err := s.Tokens.GetToken(models.TokenPurposeEmailVerify, hashToken(req.Token))
+	if err != nil {
+		return err
+	}
+	if token == nil || token.UsedAt != nil || time.Now().UTC().After(token.ExpiresAt) {
+		return errors.New("invalid or expired token")
+	}
+
+	consumed, err := s.Tokens.ConsumeToken(token.ID)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return errors.New("invalid or expired token")
+	}
+
+	verified, err := s.Auth.Repo.MarkEmailVerified(token.UserID, token.Data)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		// The account has moved on to a different address since the link was sent
+		return errors.New("invalid or expired token")
+	}
+	return nil
+}
+
+// RequestEmailChange starts a change of address. Accounts without a verified
+// address simply switch and verify the new one. Otherwise both the current
+// and the new address have to confirm, so a hijacked session cannot quietly
+// redirect password resets elsewhere. It returns "verification_sent" or
+// "confirmation_sent".
+func (s *EmailService) RequestEmailChange(userID int, req *models.ChangeEmailRequest) (string, error) {
+	user, err := s.Auth.Repo.GetUserByID(userID)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", errors.New("user not found")
+	}
+	if err := s.Auth.VerifyPassword(user, req.Password); err != nil {
+		return "", err
+	}
+
+	newEmail := normalizeEmail(req.NewEmail)
+	if newEmail == user.Email {
+		return "", errors.New("email unchanged")
+	}
+	if err := s.ensureEmailAvailable(newEmail, user.ID); err != nil {
+		return "", err
+	}
+
+	if err := s.Changes.CancelPendingChanges(user.ID); err != nil {
+		return "", err
+	}
+
+	if user.Email == "" || !user.EmailVerified {
+		if err := s.Auth.Repo.SetEmail(user.ID, newEmail, false); err != nil {
+			return "", err
+		}
+		user.Email, user.EmailVerified = newEmail, false
+		if err := s.SendVerification(user); err != nil {
+			return "", err
+		}
+		return "verification_sent", nil
+	}
+
+	oldToken, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	newToken, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := s.Changes.CreateChange(&models.EmailChange{
+		UserID:       user.ID,
+		NewEmail:     newEmail,
+		OldTokenHash: hashToken(oldToken),
+		NewTokenHash: hashToken(newToken),
+		ExpiresAt:    time.Now().UTC().Add(s.Auth.Config.EmailVerificationTTL),
+	}); err != nil {
+		return "", err
+	}
+
+	if err := s.Notifier.Send(notify.Message{
+		To:      user.Email,
+		Subject: "Confirm your email change",
+		Body: fmt.Sprintf("Someone asked to change the email address of %s to %s.\n\nIf this was you, confirm here:\n%s\n\nIf it wasn't, ignore this message and change your password.",
+			user.Username, newEmail, s.link("confirm-email-change", oldToken)),
+	}); err != nil {
+		return "", err
+	}
+	if err := s.Notifier.Send(notify.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Confirm that %s should become the email address of %s:\n%s",
+			newEmail, user.Username, s.link("confirm-email-change", newToken)),
+	}); err != nil {
+		return "", err
+	}
+	return "confirmation_sent", nil
+}
+
+// ConfirmEmailChange records one side's confirmation and applies the change
+// once both sides confirmed. It returns "pending" or "completed", and the
+// user the change belongs to.
+func (s *EmailService) ConfirmEmailChange(req *models.ConfirmEmailChangeRequest) (string, int, error) {
+	hash := hashToken(req.Token)
+	change, err := s.Changes.GetPendingChangeByToken(hash)
+	if err != nil {
+		return "", 0, err
+	}
+	if change == nil || time.Now().UTC().After(change.ExpiresAt) {
+		return "", 0, errors.New("invalid or expired token")
+	}
+
+	stillOpen, err := s.Changes.Confirm(change, hash == change.OldTokenHash)
+	if err != nil {
+		return "", 0, err
+	}
+	if !stillOpen {
+		return "", 0, errors.New("invalid or expired token")
+	}
+	if change.OldConfirmedAt == nil || change.NewConfirmedAt == nil {
+		return "pending", change.UserID, nil
+	}
+
+	// Both confirmations may arrive together; only one completes the change
+	completed, err := s.Changes.CompleteChange(change)
+	if err != nil {
+		return "", 0, err
+	}
+	if !completed {
+		return "", 0, errors.New("invalid or expired token")
+	}
+	log.Printf("Email address changed for user %d", change.UserID)
+	return "completed", change.UserID, nil
+}
+
+func (s *EmailService) ensureEmailAvailable(email string, userID int) error {
+	existing, err := s.Auth.Repo.GetUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != userID {
+		return errors.New("email already in use")
+	}
+	return nil
+}
+
+func (s *EmailService) link(path, token string) string {
+	return fmt.Sprintf("%s/%s?token=%s", s.Auth.Config.AppBaseURL, path, url.QueryEscape(token))
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// createUserToken stores a single-use token and returns the plaintext to send.
+func createUserToken(repo *repository.UserTokenRepository, userID int, purpose, data string, ttl time.Duration) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := repo.CreateToken(&models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		Data:      data,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
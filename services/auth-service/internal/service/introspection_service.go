@@ -0,0 +1,113 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
+)
+
+// IntrospectionService tells services that cannot verify tokens themselves
+// whether an access token or API key is currently valid.
+type IntrospectionService struct {
+	Auth    *AuthService
+	ApiKeys *repository.ApiKeyRepository
+}
+
+func NewIntrospectionService(auth *AuthService, apiKeys *repository.ApiKeyRepository) *IntrospectionService {
+	return &IntrospectionService{Auth: auth, ApiKeys: apiKeys}
+}
+
+// Introspect only returns an error when the token could not be checked.
+func (s *IntrospectionService) Introspect(token string) (*models.Introspection, error) {
+	if strings.HasPrefix(token, apiKeyPrefix) {
+		return s.introspectApiKey(token)
+	}
+
+	claims, err := s.Auth.ValidateAccessToken(token)
+	if err != nil {
+		if err.Error() == "invalid token" || err.Error() == "session revoked" {
+			return &models.Introspection{}, nil
+		}
+		return nil, err
+	}
+
+	sub, _ := claims["sub"].(float64)
+	user, err := s.activeUser(int(sub))
+	if err != nil || user == nil {
+		return &models.Introspection{}, err
+	}
+	result := &models.Introspection{
+		Active:    true,
+		TokenType: "access_token",
+		Sub:       strconv.Itoa(user.ID),
+		Username:  user.Username,
+		Role:      user.Role,
+	}
+	// Role and permissions as of when the token was issued, like every other consumer sees them
+	if role, ok := claims["role"].(string); ok {
+		result.Role = role
+	}
+	if list, ok := claims["perms"].([]interface{}); ok {
+		for _, p := range list {
+			if perm, ok := p.(string); ok {
+				result.Permissions = append(result.Permissions, perm)
+			}
+		}
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		result.Exp = int64(exp)
+	}
+	if act, ok := claims["act"].(map[string]interface{}); ok {
+		if actor, ok := act["sub"].(float64); ok {
+			result.Act = &models.IntrospectionActor{Sub: strconv.Itoa(int(actor))}
+		}
+	}
+	return result, nil
+}
+
+func (s *IntrospectionService) introspectApiKey(token string) (*models.Introspection, error) {
+	key, err := s.ApiKeys.GetApiKeyByHash(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || key.RevokedAt != nil || (key.ExpiresAt != nil && time.Now().UTC().After(*key.ExpiresAt)) {
+		return &models.Introspection{}, nil
+	}
+	user, err := s.activeUser(key.UserID)
+	if err != nil || user == nil {
+		return &models.Introspection{}, err
+	}
+	perms, err := s.Auth.Roles.GetPermissions(user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.Introspection{
+		Active:      true,
+		TokenType:   "api_key",
+		Sub:         strconv.Itoa(user.ID),
+		Username:    user.Username,
+		Role:        user.Role,
+		Permissions: perms,
+		Scope:       strings.Join(key.Scopes, " "),
+	}
+	if key.ExpiresAt != nil {
+		result.Exp = key.ExpiresAt.Unix()
+	}
+	return result, nil
+}
+
+// activeUser returns nil for accounts that are disabled or deleted.
+func (s *IntrospectionService) activeUser(userID int) (*models.User, error) {
+	user, err := s.Auth.Repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Disabled() || user.Deleted() {
+		return nil, nil
+	}
+	return user, nil
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/notify"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
+)
+
+type InviteService struct {
+	Auth         *AuthService
+	Orgs         *OrgService
+	Registration *RegistrationService
+	Invites      *repository.OrgInviteRepository
+	Notifier     notify.Notifier
+}
+
+func NewInviteService(auth *AuthService, orgs *OrgService, registration *RegistrationService, invites *repository.OrgInviteRepository, notifier notify.Notifier) *InviteService {
+	return &InviteService{Auth: auth, Orgs: orgs, Registration: registration, Invites: invites, Notifier: notifier}
+}
+
+// CreateInvite invites one person by username or email. Admins may invite
+// editors and viewers; owners may invite any role.
+func (s *InviteService) CreateInvite(actorID, orgID int, req *models.CreateInviteRequest) (*models.CreateInviteResponse, error) {
+	username := strings.TrimSpace(req.Username)
+	email := normalizeEmail(req.Email)
+	if (username == "") == (email == "") {
+		return nil, errors.New("exactly one of username or email is required")
+	}
+	if !models.ValidOrgRole(req.Role) {
+		return nil, errors.New("invalid org role")
+	}
+	actor, err := s.Orgs.requireRole(actorID, orgID, models.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if !canManage(actor.Role, req.Role) {
+		return nil, errors.New("forbidden")
+	}
+
+	// Existing accounts are checked up front; unknown ones can register with the invite
+	var invitee *models.User
+	if username != "" {
+		invitee, err = s.Auth.Repo.GetUserByUsername(username)
+	} else {
+		invitee, err = s.Auth.Repo.GetUserByEmail(email)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if invitee != nil {
+		member, err := s.Orgs.Orgs.GetMember(orgID, invitee.ID)
+		if err != nil {
+			return nil, err
+		}
+		if member != nil {
+			return nil, errors.New("already a member")
+		}
+	}
+
+	ttl := s.Auth.Config.OrgInviteTTL
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	invite := &models.OrgInvite{
+		OrgID:     orgID,
+		Role:      req.Role,
+		Username:  username,
+		Email:     email,
+		TokenHash: hashToken(token),
+		InvitedBy: actorID,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}
+	if err := s.Invites.CreateInvite(invite); err != nil {
+		return nil, err
+	}
+	log.Printf("User %d invited %s%s to organization %d as %s", actorID, username, email, orgID, req.Role)
+
+	// Username invites reach the person by mail only if they have a verified address
+	to := email
+	if invitee != nil && username != "" && invitee.EmailVerified {
+		to = invitee.Email
+	}
+	if to != "" {
+		if err := s.sendInvite(to, actor.Username, orgID, invite.Role, token); err != nil {
+			log.Printf("Failed to send invite %d: %v", invite.ID, err)
+		}
+	}
+
+	// The token of an email invite proves the address to RegisterWithInvite,
+	// so it goes to the mailbox only, never to the inviter
+	resp := &models.CreateInviteResponse{Invite: *invite}
+	if email == "" {
+		resp.Token = token
+	}
+	return resp, nil
+}
+
+func (s *InviteService) ListInvites(actorID, orgID int) ([]models.OrgInvite, error) {
+	if _, err := s.Orgs.requireRole(actorID, orgID, models.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
+	return s.Invites.ListPendingInvites(orgID)
+}
+
+func (s *InviteService) RevokeInvite(actorID, orgID, inviteID int) error {
+	if _, err := s.Orgs.requireRole(actorID, orgID, models.OrgRoleAdmin); err != nil {
+		return err
+	}
+	found, err := s.Invites.RevokeInvite(inviteID, orgID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("invite not found")
+	}
+	return nil
+}
+
+// AcceptInvite adds a signed-in user to the organization if the invite was
+// issued to their username or their verified email address.
+func (s *InviteService) AcceptInvite(userID int, req *models.AcceptInviteRequest) (*models.Organization, error) {
+	invite, err := s.pendingInvite(req.Token)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.Auth.Repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	if !inviteMatches(invite, user) {
+		return nil, errors.New("invite is for someone else")
+	}
+	member, err := s.Orgs.Orgs.GetMember(invite.OrgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member != nil {
+		return nil, errors.New("already a member")
+	}
+
+	if err := s.accept(invite, userID); err != nil {
+		return nil, err
+	}
+	return s.Orgs.GetOrg(userID, invite.OrgID)
+}
+
+// RegisterWithInvite creates an account for someone who was invited before
+// they signed up, accepts the invite and logs them in. The token of an email
+// invite is only ever sent to the invited address, so holding it proves
+// ownership and the address is stored as verified.
+func (s *InviteService) RegisterWithInvite(req *models.RegisterInviteRequest) (*models.TokenPair, *models.User, error) {
+	invite, err := s.pendingInvite(req.Token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if invite.Username != "" && !strings.EqualFold(invite.Username, req.Username) {
+		return nil, nil, errors.New("invite is for someone else")
+	}
+	user, err := s.Auth.newUser(&models.RegisterRequest{Username: req.Username, Password: req.Password, Email: invite.Email})
+	if err != nil {
+		return nil, nil, err
+	}
+	user.EmailVerified = user.Email != ""
+
+	code, err := s.Registration.CheckOrgInvite(invite, strings.TrimSpace(req.InviteCode))
+	if err != nil {
+		return nil, nil, err
+	}
+	accepted, err := s.Invites.RegisterWithInvite(invite, user)
+	if err == nil && !accepted {
+		err = errors.New("invalid or expired invite")
+	}
+	if err != nil {
+		s.Registration.ReleaseCode(code)
+		return nil, nil, err
+	}
+	log.Printf("User %d signed up and joined organization %d as %s", user.ID, invite.OrgID, invite.Role)
+
+	tokens, err := s.Auth.startSession(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, user, nil
+}
+
+func (s *InviteService) pendingInvite(token string) (*models.OrgInvite, error) {
+	invite, err := s.Invites.GetInviteByHash(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if invite == nil || invite.AcceptedAt != nil || invite.RevokedAt != nil || time.Now().UTC().After(invite.ExpiresAt) {
+		return nil, errors.New("invalid or expired invite")
+	}
+	return invite, nil
+}
+
+func (s *InviteService) accept(invite *models.OrgInvite, userID int) error {
+	accepted, err := s.Invites.AcceptInvite(invite, userID)
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		return errors.New("invalid or expired invite")
+	}
+	log.Printf("User %d joined organization %d as %s", userID, invite.OrgID, invite.Role)
+	return nil
+}
+
+func (s *InviteService) sendInvite(to, inviter string, orgID int, role, token string) error {
+	org, err := s.Orgs.Orgs.GetOrg(orgID)
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s/accept-invite?token=%s", s.Auth.Config.AppBaseURL, url.QueryEscape(token))
+	return s.Notifier.Send(notify.Message{
+		To:      to,
+		Subject: fmt.Sprintf("You have been invited to join %s", org.Name),
+		Body: fmt.Sprintf("%s invited you to join %s as %s. Open this link to accept, or to create an account if you do not have one yet:\n%s",
+			inviter, org.Name, role, link),
+	})
+}
+
+// inviteMatches reports whether the invite was issued to this user.
+func inviteMatches(invite *models.OrgInvite, user *models.User) bool {
+	if invite.Username != "" {
+		return strings.EqualFold(invite.Username, user.Username)
+	}
+	return user.EmailVerified && user.Email != "" && user.Email == invite.Email
+}
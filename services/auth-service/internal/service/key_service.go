@@ -0,0 +1,255 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/config"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/kek"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/keys"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
+)
+
+// Allowance for clock skew between replicas when retiring keys.
+const keyRetireGrace = time.Minute
+
+// KeyService keeps the in-memory keyring in sync with the SigningKeys table
+// and promotes new keys on the configured schedule. Private keys are stored
+// sealed with the key encryption key, which only auth-service has.
+type KeyService struct {
+	Repo   *repository.KeyRepository
+	Keys   *keys.Keyring
+	KEK    *kek.KEK
+	Config *config.Config
+}
+
+func NewKeyService(repo *repository.KeyRepository, keyring *keys.Keyring, secrets *kek.KEK, cfg *config.Config) *KeyService {
+	return &KeyService{Repo: repo, Keys: keyring, KEK: secrets, Config: cfg}
+}
+
+// Init imports the key from JWT_PRIVATE_KEY(_FILE) if it is new, makes sure
+// at least one key exists and loads the keyring.
+func (s *KeyService) Init() error {
+	if s.Config.KeyRotationInterval <= s.Config.KeyPrepublishPeriod {
+		return errors.New("key rotation interval must be longer than the pre-publish period")
+	}
+
+	configured, err := s.configuredKey()
+	if err != nil {
+		return err
+	}
+	if configured != nil {
+		exists, err := s.Repo.KeyExists(configured.ID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			log.Printf("Importing configured JWT signing key %s", configured.ID)
+			if _, err := s.store(configured, time.Now().UTC(), false); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.Reload(); err != nil {
+		return err
+	}
+	if _, err := s.Keys.SigningKey(); err == nil {
+		return nil
+	}
+
+	// Nothing can be signing yet, so there is no one to pre-publish to
+	log.Println("No active JWT signing key found, generating one")
+	key, err := keys.GenerateSigningKey()
+	if err != nil {
+		return err
+	}
+	if _, err := s.store(key, time.Now().UTC(), false); err != nil {
+		return err
+	}
+	return s.Reload()
+}
+
+func (s *KeyService) configuredKey() (*keys.SigningKey, error) {
+	pemData := s.Config.JWTPrivateKey
+	if pemData == "" && s.Config.JWTPrivateKeyFile != "" {
+		data, err := os.ReadFile(s.Config.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		pemData = string(data)
+	}
+	if pemData == "" {
+		return nil, nil
+	}
+	return keys.ParseSigningKey([]byte(pemData))
+}
+
+// Reload replaces the keyring with the keys currently stored in the database.
+func (s *KeyService) Reload() error {
+	records, err := s.Repo.ListKeys()
+	if err != nil {
+		return err
+	}
+
+	var loaded []*keys.SigningKey
+	for _, rec := range records {
+		key, err := s.openKey(&rec)
+		if err != nil {
+			log.Printf("Skipping unreadable signing key %s: %v", rec.Kid, err)
+			continue
+		}
+		key.ActivatesAt = rec.ActivatesAt
+		key.RetiresAt = rec.RetiresAt
+		loaded = append(loaded, key)
+	}
+	s.Keys.Replace(loaded)
+	return nil
+}
+
+// RotateIfDue pre-publishes the next key once the current one has been
+// signing for the rotation interval. The new key only starts signing after
+// the pre-publish period, giving verifiers time to fetch it from the JWKS.
+func (s *KeyService) RotateIfDue() error {
+	current, err := s.Keys.SigningKey()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	due := current.ActivatesAt.Add(s.Config.KeyRotationInterval - s.Config.KeyPrepublishPeriod)
+	if now.Before(due) {
+		return nil
+	}
+
+	key, err := keys.GenerateSigningKey()
+	if err != nil {
+		return err
+	}
+	created, err := s.store(key, now.Add(s.Config.KeyPrepublishPeriod), true)
+	if err != nil {
+		return err
+	}
+	if created {
+		log.Printf("Scheduled JWT signing key %s to activate at %s", key.ID, key.ActivatesAt.Format(time.RFC3339))
+	}
+	return s.Reload()
+}
+
+// Rotate pre-publishes a new key the same way RotateIfDue does, without
+// waiting for the rotation interval. With immediate set the new key signs
+// right away and older keys stop verifying, for when a key may have been
+// compromised.
+func (s *KeyService) Rotate(immediate bool) (*models.SigningKeyRecord, error) {
+	key, err := keys.GenerateSigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	activatesAt := now.Add(s.Config.KeyPrepublishPeriod)
+	retireAt := activatesAt.Add(s.Config.AccessTokenTTL + keyRetireGrace)
+	if immediate {
+		activatesAt = now
+		retireAt = now
+	}
+
+	rec, err := s.record(key, activatesAt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Repo.RotateKey(rec, retireAt, false); err != nil {
+		return nil, err
+	}
+	log.Printf("Rotated JWT signing key to %s, activating at %s (immediate=%t)", key.ID, activatesAt.Format(time.RFC3339), immediate)
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (s *KeyService) ListKeys() ([]models.SigningKeyRecord, error) {
+	return s.Repo.ListKeys()
+}
+
+// Run reloads the keyring and checks the rotation schedule until the process exits.
+func (s *KeyService) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.Reload(); err != nil {
+			log.Printf("Failed to reload signing keys: %v", err)
+			continue
+		}
+		if err := s.RotateIfDue(); err != nil {
+			log.Printf("Failed to rotate signing key: %v", err)
+		}
+	}
+}
+
+func (s *KeyService) store(key *keys.SigningKey, activatesAt time.Time, onlyIfNoPending bool) (bool, error) {
+	rec, err := s.record(key, activatesAt)
+	if err != nil {
+		return false, err
+	}
+	return s.Repo.RotateKey(rec, activatesAt.Add(s.Config.AccessTokenTTL+keyRetireGrace), onlyIfNoPending)
+}
+
+func (s *KeyService) record(key *keys.SigningKey, activatesAt time.Time) (*models.SigningKeyRecord, error) {
+	encoded, err := key.EncodePEM()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.KEK.Seal([]byte(encoded), signingKeyContext(key.ID))
+	if err != nil {
+		return nil, err
+	}
+	key.ActivatesAt = activatesAt
+	return &models.SigningKeyRecord{
+		Kid:         key.ID,
+		PrivateKey:  sealed,
+		ActivatesAt: activatesAt,
+	}, nil
+}
+
+// openKey unseals a stored key. Keys stored in plaintext before they were
+// encrypted, or sealed with a previous key encryption key, are resealed
+// with the current one.
+func (s *KeyService) openKey(rec *models.SigningKeyRecord) (*keys.SigningKey, error) {
+	pemData := []byte(rec.PrivateKey)
+	if kek.IsSealed(rec.PrivateKey) {
+		opened, err := s.KEK.Open(rec.PrivateKey, signingKeyContext(rec.Kid))
+		if err != nil {
+			return nil, err
+		}
+		pemData = opened
+	}
+	key, err := keys.ParseSigningKey(pemData)
+	if err != nil {
+		return nil, err
+	}
+	if key.ID != rec.Kid {
+		return nil, errors.New("key does not match its kid")
+	}
+
+	if !s.KEK.Current(rec.PrivateKey) {
+		sealed, err := s.KEK.Seal(pemData, signingKeyContext(rec.Kid))
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Repo.ReplacePrivateKey(rec.Kid, rec.PrivateKey, sealed); err != nil {
+			log.Printf("Failed to reseal signing key %s: %v", rec.Kid, err)
+		} else {
+			log.Printf("Resealed signing key %s with the current key encryption key", rec.Kid)
+		}
+	}
+	return key, nil
+}
+
+func signingKeyContext(kid string) string {
+	return "SigningKeys:" + kid
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/kek"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/totp"
+)
+
+const recoveryCodeCount = 10
+
+// Accept codes from one step either side of now to tolerate clock drift
+const totpSkew = 1
+
+// MFAService manages TOTP second factors. Secrets are stored sealed with the
+// key encryption key, like signing keys.
+type MFAService struct {
+	Auth          *AuthService
+	RecoveryCodes *repository.RecoveryCodeRepository
+	KEK           *kek.KEK
+}
+
+func NewMFAService(auth *AuthService, recoveryCodes *repository.RecoveryCodeRepository, secrets *kek.KEK) *MFAService {
+	return &MFAService{Auth: auth, RecoveryCodes: recoveryCodes, KEK: secrets}
+}
+
+// SetupTOTP starts enrollment with a fresh secret. 2FA stays off until the
+// user proves their authenticator works via ConfirmTOTP.
+func (s *MFAService) SetupTOTP(userID int) (*models.TOTPSetupResponse, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("2fa already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.KEK.Seal([]byte(secret), totpSecretContext(user.ID))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Auth.Repo.SetPendingTOTPSecret(user.ID, sealed); err != nil {
+		return nil, err
+	}
+
+	return &models.TOTPSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(s.Auth.Config.TOTPIssuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables 2FA and hands out the recovery codes, which are only
+// ever shown this once.
+func (s *MFAService) ConfirmTOTP(userID int, req *models.TOTPCodeRequest) (*models.RecoveryCodesResponse, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("2fa already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("2fa setup not started")
+	}
+
+	if err := s.checkTOTP(user, req.Code); err != nil {
+		return nil, err
+	}
+	if err := s.Auth.Repo.EnableTOTP(user.ID); err != nil {
+		return nil, err
+	}
+	return s.issueRecoveryCodes(user.ID)
+}
+
+func (s *MFAService) DisableTOTP(userID int, req *models.DisableTOTPRequest) error {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return errors.New("2fa not enabled")
+	}
+	if err := s.Auth.VerifyPassword(user, req.Password); err != nil {
+		return err
+	}
+	if err := s.checkSecondFactor(user, req.Code, req.RecoveryCode); err != nil {
+		return err
+	}
+	return s.disable(user.ID)
+}
+
+func (s *MFAService) RegenerateRecoveryCodes(userID int, req *models.TOTPCodeRequest) (*models.RecoveryCodesResponse, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, errors.New("2fa not enabled")
+	}
+	if err := s.checkTOTP(user, req.Code); err != nil {
+		return nil, err
+	}
+	return s.issueRecoveryCodes(user.ID)
+}
+
+// ChallengeUser returns the user an MFA challenge was issued to, even once
+// used or expired, or 0 if the token is unknown. It is for logging only.
+func (s *MFAService) ChallengeUser(mfaToken string) int {
+	challenge, err := s.Auth.Tokens.GetToken(models.TokenPurposeMFAChallenge, hashToken(mfaToken))
+	if err != nil || challenge == nil {
+		return 0
+	}
+	return challenge.UserID
+}
+
+// CompleteLogin exchanges an MFA challenge plus a TOTP or recovery code for
+// tokens. The challenge is single-use: a wrong code means logging in again,
+// which keeps code guessing behind the password check. Wrong codes also count
+// towards the account lockout.
+func (s *MFAService) CompleteLogin(req *models.MFALoginRequest, ip string) (*models.TokenPair, *models.User, error) {
+	challenge, err := s.Auth.Tokens.GetToken(models.TokenPurposeMFAChallenge, hashToken(req.MFAToken))
+	if err != nil {
+		return nil, nil, err
+	}
+	if challenge == nil || challenge.UsedAt != nil || time.Now().UTC().After(challenge.ExpiresAt) {
+		return nil, nil, errors.New("invalid or expired challenge")
+	}
+	consumed, err := s.Auth.Tokens.ConsumeToken(challenge.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !consumed {
+		return nil, nil, errors.New("invalid or expired challenge")
+	}
+
+	user, err := s.getUser(challenge.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, nil, errors.New("invalid or expired challenge")
+	}
+	if err := s.Auth.Guard.Check(user.Username, ip); err != nil {
+		return nil, nil, err
+	}
+	if err := s.checkSecondFactor(user, req.Code, req.RecoveryCode); err != nil {
+		if err.Error() == "invalid code" {
+			return nil, nil, s.Auth.loginFailed(user.Username, ip, err)
+		}
+		return nil, nil, err
+	}
+	if err := s.Auth.Guard.Succeed(user.Username); err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.Auth.startSession(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, user, nil
+}
+
+// ResetForUser lets an admin turn off 2FA for someone who lost their device
+// and their recovery codes.
+func (s *MFAService) ResetForUser(userID int) error {
+	if _, err := s.getUser(userID); err != nil {
+		return err
+	}
+	log.Printf("Resetting 2FA for user %d", userID)
+	return s.disable(userID)
+}
+
+func (s *MFAService) disable(userID int) error {
+	if err := s.Auth.Repo.DisableTOTP(userID); err != nil {
+		return err
+	}
+	return s.RecoveryCodes.DeleteCodes(userID)
+}
+
+func (s *MFAService) checkSecondFactor(user *models.User, code, recoveryCode string) error {
+	if recoveryCode != "" {
+		used, err := s.RecoveryCodes.UseCode(user.ID, hashToken(normalizeRecoveryCode(recoveryCode)))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return errors.New("invalid code")
+		}
+		return nil
+	}
+	return s.checkTOTP(user, code)
+}
+
+func (s *MFAService) checkTOTP(user *models.User, code string) error {
+	secret, err := s.totpSecret(user)
+	if err != nil {
+		return err
+	}
+	step, ok := totp.Validate(secret, code, time.Now(), totpSkew)
+	if !ok {
+		return errors.New("invalid code")
+	}
+	claimed, err := s.Auth.Repo.ClaimTOTPStep(user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return errors.New("invalid code")
+	}
+	return nil
+}
+
+// totpSecret opens the user's secret. Secrets stored in plaintext before
+// they were sealed, or sealed with a previous key, are resealed with the
+// current one.
+func (s *MFAService) totpSecret(user *models.User) (string, error) {
+	secret := user.TOTPSecret
+	if kek.IsSealed(user.TOTPSecret) {
+		opened, err := s.KEK.Open(user.TOTPSecret, totpSecretContext(user.ID))
+		if err != nil {
+			return "", err
+		}
+		secret = string(opened)
+	}
+
+	if !s.KEK.Current(user.TOTPSecret) {
+		sealed, err := s.KEK.Seal([]byte(secret), totpSecretContext(user.ID))
+		if err != nil {
+			return "", err
+		}
+		if err := s.Auth.Repo.ReplaceTOTPSecret(user.ID, user.TOTPSecret, sealed); err != nil {
+			log.Printf("Failed to reseal TOTP secret of user %d: %v", user.ID, err)
+		}
+	}
+	return secret, nil
+}
+
+func totpSecretContext(userID int) string {
+	return "Users.TOTPSecret:" + strconv.Itoa(userID)
+}
+
+func (s *MFAService) issueRecoveryCodes(userID int) (*models.RecoveryCodesResponse, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := randomRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashToken(normalizeRecoveryCode(code))
+	}
+	if err := s.RecoveryCodes.ReplaceCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return &models.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *MFAService) getUser(userID int) (*models.User, error) {
+	user, err := s.Auth.Repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+// randomRecoveryCode returns 80 random bits as XXXX-XXXX-XXXX-XXXX.
+func randomRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s := base32.StdEncoding.EncodeToString(b)
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/oidc"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
+)
+
+// How long the user has to finish signing in at the provider
+const oidcStateTTL = 10 * time.Minute
+
+var usernameUnsafeChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// OIDCService signs users in through external OpenID Connect providers and
+// issues the same tokens as a password login.
+type OIDCService struct {
+	Auth         *AuthService
+	Identities   *repository.ExternalIdentityRepository
+	Registration *RegistrationService
+	Providers    map[string]*oidc.Provider
+}
+
+func NewOIDCService(auth *AuthService, identities *repository.ExternalIdentityRepository, registration *RegistrationService, providers []oidc.Config) *OIDCService {
+	s := &OIDCService{Auth: auth, Identities: identities, Registration: registration, Providers: map[string]*oidc.Provider{}}
+	for _, p := range providers {
+		s.Providers[p.Name] = oidc.NewProvider(p)
+	}
+	return s
+}
+
+func (s *OIDCService) ProviderNames() []string {
+	names := []string{}
+	for name := range s.Providers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Authorize starts a sign-in and returns the provider URL plus the state,
+// which the caller keeps in the browser so the callback can check it came
+// back to the browser that started the sign-in. With a non-zero userID the
+// callback links the identity to that user instead of logging in.
+func (s *OIDCService) Authorize(providerName string, userID int) (string, string, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return "", "", errors.New("unknown provider")
+	}
+
+	state, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomToken(48)
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	record := &models.OIDCState{
+		StateHash:    hashToken(state),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().UTC().Add(oidcStateTTL),
+	}
+	if userID != 0 {
+		record.UserID = &userID
+	}
+	if err := s.Identities.CreateState(record); err != nil {
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+// Callback finishes a sign-in started by Authorize. Unknown identities get a
+// new local account if the registration mode or the provider's
+// TrustedSignup lets them in; they are never matched to an existing one by email,
+// since that would let anyone who controls an address at the provider take
+// over the local account. Users link providers themselves while signed in.
+// browserState is the state Authorize handed to the browser; a callback
+// carrying someone else's state, e.g. from a forged link that would sign the
+// victim into the attacker's account, is rejected.
+func (s *OIDCService) Callback(providerName string, req *models.OIDCCallbackRequest, browserState string) (*models.OIDCResult, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return nil, errors.New("unknown provider")
+	}
+	if browserState == "" || subtle.ConstantTimeCompare([]byte(browserState), []byte(req.State)) != 1 {
+		return nil, errors.New("invalid or expired state")
+	}
+	state, err := s.Identities.ConsumeState(hashToken(req.State))
+	if err != nil {
+		return nil, err
+	}
+	if state == nil || state.Provider != providerName || time.Now().UTC().After(state.ExpiresAt) {
+		return nil, errors.New("invalid or expired state")
+	}
+
+	rawIDToken, err := provider.Exchange(req.Code, state.CodeVerifier)
+	if err != nil {
+		log.Printf("OIDC code exchange with %s failed: %v", providerName, err)
+		return nil, errors.New("sign-in with provider failed")
+	}
+	claims, err := provider.VerifyIDToken(rawIDToken, state.Nonce)
+	if err != nil {
+		log.Printf("OIDC id token from %s rejected: %v", providerName, err)
+		return nil, errors.New("sign-in with provider failed")
+	}
+
+	identity, err := s.Identities.GetIdentity(providerName, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.UserID != nil {
+		return s.link(providerName, *state.UserID, identity, claims)
+	}
+
+	var user *models.User
+	if identity != nil {
+		if err := s.Identities.TouchIdentity(identity.ID, claims.Email); err != nil {
+			return nil, err
+		}
+		user, err = s.Auth.Repo.GetUserByID(identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, errors.New("sign-in with provider failed")
+		}
+	} else {
+		if !provider.TrustedSignup {
+			if err := s.Registration.CheckExternalSignup(claims.Email, claims.EmailVerified); err != nil {
+				return nil, err
+			}
+		}
+		user, err = s.provision(providerName, claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The provider only stands in for the password; 2FA still applies
+	login, err := s.Auth.signIn(user)
+	if err != nil {
+		return nil, err
+	}
+	return &models.OIDCResult{Login: login}, nil
+}
+
+func (s *OIDCService) ListIdentities(userID int) ([]models.ExternalIdentity, error) {
+	return s.Identities.ListIdentities(userID)
+}
+
+// Unlink removes an identity unless it is the only way left to sign in.
+func (s *OIDCService) Unlink(userID, identityID int) error {
+	user, err := s.Auth.Repo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.New("user not found")
+	}
+	if user.PasswordHash == "" {
+		identities, err := s.Identities.ListIdentities(userID)
+		if err != nil {
+			return err
+		}
+		if len(identities) <= 1 {
+			return errors.New("cannot remove last sign-in method")
+		}
+	}
+
+	found, err := s.Identities.DeleteIdentity(identityID, userID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("identity not found")
+	}
+	return nil
+}
+
+func (s *OIDCService) link(providerName string, userID int, existing *models.ExternalIdentity, claims *oidc.Claims) (*models.OIDCResult, error) {
+	if existing != nil {
+		if existing.UserID != userID {
+			return nil, errors.New("identity linked to another account")
+		}
+		return &models.OIDCResult{Linked: existing}, nil
+	}
+
+	identity := &models.ExternalIdentity{UserID: userID, Provider: providerName, Subject: claims.Subject, Email: claims.Email}
+	if err := s.Identities.CreateIdentity(identity); err != nil {
+		return nil, err
+	}
+	log.Printf("User %d linked %s identity %s", userID, providerName, claims.Subject)
+	return &models.OIDCResult{Linked: identity}, nil
+}
+
+// provision creates a local account for a first-time external sign-in. The
+// account has no password; the provider is its only way in until one is set.
+func (s *OIDCService) provision(providerName string, claims *oidc.Claims) (*models.User, error) {
+	username, err := s.availableUsername(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only keep the email if no other account uses it
+	email := normalizeEmail(claims.Email)
+	if email != "" {
+		taken, err := s.Auth.Repo.GetUserByEmail(email)
+		if err != nil {
+			return nil, err
+		}
+		if taken != nil {
+			email = ""
+		}
+	}
+
+	user := &models.User{Username: username, Role: "User", Email: email, Source: models.SourceOIDC}
+	if err := s.Auth.Repo.CreateUser(user); err != nil {
+		return nil, err
+	}
+	if email != "" && claims.EmailVerified {
+		if _, err := s.Auth.Repo.MarkEmailVerified(user.ID, email); err != nil {
+			return nil, err
+		}
+		user.EmailVerified = true
+	}
+
+	identity := &models.ExternalIdentity{UserID: user.ID, Provider: providerName, Subject: claims.Subject, Email: claims.Email}
+	if err := s.Identities.CreateIdentity(identity); err != nil {
+		return nil, err
+	}
+	log.Printf("Provisioned user %d for %s identity %s", user.ID, providerName, claims.Subject)
+	return user, nil
+}
+
+// availableUsername derives a username from the ID token, adding a number
+// if it is already taken.
+func (s *OIDCService) availableUsername(claims *oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" && claims.Email != "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	base = usernameUnsafeChars.ReplaceAllString(base, "")
+	if base == "" {
+		base = "user"
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	for i := 0; i < 100; i++ {
+		candidate := base
+		if i > 0 {
+			candidate = fmt.Sprintf("%s%d", base, i+1)
+		}
+		existing, err := s.Auth.Repo.GetUserByUsername(candidate)
+		if err != nil {
+			return "", err
+		}
+		if existing == nil {
+			return candidate, nil
+		}
+	}
+
+	suffix, err := randomToken(6)
+	if err != nil {
+		return "", err
+	}
+	return base + "-" + usernameUnsafeChars.ReplaceAllString(suffix, ""), nil
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
+)
+
+// OrgService manages organizations and their members. Links owned by an
+// organization live in link-management, which reads OrgMembers to authorize.
+type OrgService struct {
+	Orgs *repository.OrgRepository
+}
+
+func NewOrgService(orgs *repository.OrgRepository) *OrgService {
+	return &OrgService{Orgs: orgs}
+}
+
+func (s *OrgService) CreateOrg(userID int, req *models.OrgRequest) (*models.Organization, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	org := &models.Organization{Name: name, Role: models.OrgRoleOwner}
+	if err := s.Orgs.CreateOrg(org, userID); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func (s *OrgService) ListOrgs(userID int) ([]models.Organization, error) {
+	return s.Orgs.ListOrgsForUser(userID)
+}
+
+func (s *OrgService) GetOrg(userID, orgID int) (*models.Organization, error) {
+	member, err := s.requireRole(userID, orgID, models.OrgRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	org, err := s.Orgs.GetOrg(orgID)
+	if err != nil {
+		return nil, err
+	}
+	org.Role = member.Role
+	return org, nil
+}
+
+func (s *OrgService) RenameOrg(userID, orgID int, req *models.OrgRequest) (*models.Organization, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	if _, err := s.requireRole(userID, orgID, models.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
+	if err := s.Orgs.RenameOrg(orgID, name); err != nil {
+		return nil, err
+	}
+	return s.GetOrg(userID, orgID)
+}
+
+func (s *OrgService) ListMembers(userID, orgID int) ([]models.OrgMember, error) {
+	if _, err := s.requireRole(userID, orgID, models.OrgRoleViewer); err != nil {
+		return nil, err
+	}
+	return s.Orgs.ListMembers(orgID)
+}
+
+// UpdateMemberRole lets admins manage editors and viewers. Only owners may
+// grant or take away the owner and admin roles.
+func (s *OrgService) UpdateMemberRole(actorID, orgID, memberID int, role string) (*models.OrgMember, error) {
+	if !models.ValidOrgRole(role) {
+		return nil, errors.New("invalid org role")
+	}
+	actor, err := s.requireRole(actorID, orgID, models.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.getMember(orgID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManage(actor.Role, target.Role) || !canManage(actor.Role, role) {
+		return nil, errors.New("forbidden")
+	}
+
+	ok, err := s.Orgs.SetMemberRole(orgID, memberID, role)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("organization needs an owner")
+	}
+	log.Printf("User %d changed role of user %d in organization %d from %s to %s", actorID, memberID, orgID, target.Role, role)
+	return s.getMember(orgID, memberID)
+}
+
+// RemoveMember removes someone from the organization. Members may always remove
+// themselves (leave), except the last owner.
+func (s *OrgService) RemoveMember(actorID, orgID, memberID int) error {
+	actor, err := s.requireRole(actorID, orgID, models.OrgRoleViewer)
+	if err != nil {
+		return err
+	}
+	if actorID != memberID {
+		target, err := s.getMember(orgID, memberID)
+		if err != nil {
+			return err
+		}
+		if !models.OrgRoleAtLeast(actor.Role, models.OrgRoleAdmin) || !canManage(actor.Role, target.Role) {
+			return errors.New("forbidden")
+		}
+	}
+
+	ok, err := s.Orgs.RemoveMember(orgID, memberID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("organization needs an owner")
+	}
+	log.Printf("User %d removed user %d from organization %d", actorID, memberID, orgID)
+	return nil
+}
+
+// requireRole returns the caller's membership if it is at least min.
+// Non-members get "organization not found" so they cannot probe for org IDs.
+func (s *OrgService) requireRole(userID, orgID int, min string) (*models.OrgMember, error) {
+	member, err := s.Orgs.GetMember(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return nil, errors.New("organization not found")
+	}
+	if !models.OrgRoleAtLeast(member.Role, min) {
+		return nil, errors.New("forbidden")
+	}
+	return member, nil
+}
+
+func (s *OrgService) getMember(orgID, userID int) (*models.OrgMember, error) {
+	member, err := s.Orgs.GetMember(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return nil, errors.New("member not found")
+	}
+	return member, nil
+}
+
+// canManage reports whether an actor with role actor may assign or change role target.
+func canManage(actor, target string) bool {
+	if actor == models.OrgRoleOwner {
+		return true
+	}
+	return actor == models.OrgRoleAdmin && !models.OrgRoleAtLeast(target, models.OrgRoleAdmin)
+}
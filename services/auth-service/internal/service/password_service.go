@@ -0,0 +1,149 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/notify"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
+)
+
+type PasswordService struct {
+	Auth     *AuthService
+	Tokens   *repository.UserTokenRepository
+	Notifier notify.Notifier
+}
+
+func NewPasswordService(auth *AuthService, tokens *repository.UserTokenRepository, notifier notify.Notifier) *PasswordService {
+	return &PasswordService{Auth: auth, Tokens: tokens, Notifier: notifier}
+}
+
+// ChangePassword verifies the current password, stores the new one and
+// revokes every existing session. The caller gets a fresh session back.
+func (s *PasswordService) ChangePassword(userID int, req *models.ChangePasswordRequest) (*models.TokenPair, *models.User, error) {
+	user, err := s.Auth.Repo.GetUserByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, errors.New("user not found")
+	}
+
+	if user.FromDirectory() {
+		return nil, nil, errors.New("invalid credentials")
+	}
+	if ok, err := s.Auth.Passwords.Verify(user.PasswordHash, req.CurrentPassword); err != nil || !ok {
+		return nil, nil, errors.New("invalid credentials")
+	}
+	if err := s.Auth.CheckPassword(req.NewPassword, user.Username); err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.setPassword(user, req.NewPassword); err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.Auth.startSession(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, user, nil
+}
+
+// ForgotPassword sends a reset link if the account exists. It never reports
+// whether it does, so the endpoint cannot be used to enumerate usernames.
+func (s *PasswordService) ForgotPassword(req *models.ForgotPasswordRequest) error {
+	user, err := s.Auth.Repo.GetUserByUsername(req.Username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+	// Directory accounts change their password in the directory
+	if user.FromDirectory() {
+		log.Printf("Password reset requested for directory user %d", user.ID)
+		return nil
+	}
+
+	// Reset links only go to an address the user has proven they own
+	if user.Email == "" || !user.EmailVerified {
+		log.Printf("Password reset requested for user %d without a verified email", user.ID)
+		return nil
+	}
+
+	token, err := createUserToken(s.Tokens, user.ID, models.TokenPurposePasswordReset, "", s.Auth.Config.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", s.Auth.Config.AppBaseURL, url.QueryEscape(token))
+	msg := notify.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password for %s.\n\nOpen this link within %s to choose a new one:\n%s\n\nIf this wasn't you, you can ignore this message.",
+			user.Username, s.Auth.Config.PasswordResetTTL, link),
+	}
+	// Failing here would tell the caller the account exists
+	if err := s.Notifier.Send(msg); err != nil {
+		log.Printf("Failed to send password reset for user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// ResetPassword returns the user whose password was reset.
+func (s *PasswordService) ResetPassword(req *models.ResetPasswordRequest) (int, error) {
+	token, err := s.Tokens.GetToken(models.TokenPurposePasswordReset, hashToken(req.Token))
+	if err != nil {
+		return 0, err
+	}
+	if token == nil || token.UsedAt != nil || time.Now().UTC().After(token.ExpiresAt) {
+		return 0, errors.New("invalid or expired token")
+	}
+
+	user, err := s.Auth.Repo.GetUserByID(token.UserID)
+	if err != nil {
+		return 0, err
+	}
+	if user == nil || user.FromDirectory() {
+		return 0, errors.New("invalid or expired token")
+	}
+	// Checked before the token is used up so a rejected password can be retried
+	if err := s.Auth.CheckPassword(req.NewPassword, user.Username); err != nil {
+		return 0, err
+	}
+
+	consumed, err := s.Tokens.ConsumeToken(token.ID)
+	if err != nil {
+		return 0, err
+	}
+	if !consumed {
+		return 0, errors.New("invalid or expired token")
+	}
+
+	if err := s.setPassword(user, req.NewPassword); err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+func (s *PasswordService) setPassword(user *models.User, password string) error {
+	hashed, err := s.Auth.Passwords.Hash(password)
+	if err != nil {
+		return err
+	}
+	if err := s.Auth.Repo.UpdatePassword(user.ID, hashed); err != nil {
+		return err
+	}
+	user.PasswordHash = hashed
+
+	// Anyone holding an old session or reset link loses access
+	if err := s.Auth.Sessions.RevokeUserSessions(user.ID); err != nil {
+		return err
+	}
+	return s.Tokens.InvalidateTokens(user.ID, models.TokenPurposePasswordReset)
+}
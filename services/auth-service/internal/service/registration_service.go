@@ -0,0 +1,253 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
+)
+
+var domainPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+
+// RegistrationService decides who may sign up and manages invite codes.
+// Admins and SCIM create accounts without going through it.
+type RegistrationService struct {
+	Auth *AuthService
+	Repo *repository.RegistrationRepository
+}
+
+func NewRegistrationService(auth *AuthService, repo *repository.RegistrationRepository) *RegistrationService {
+	return &RegistrationService{Auth: auth, Repo: repo}
+}
+
+// Settings returns the stored settings, or the configured ones if no admin
+// has changed them.
+func (s *RegistrationService) Settings() (*models.RegistrationSettings, error) {
+	settings, err := s.Repo.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		settings = &models.RegistrationSettings{Mode: s.Auth.Config.RegistrationMode, AllowedDomains: s.Auth.Config.RegistrationDomains}
+	}
+	return settings, nil
+}
+
+func (s *RegistrationService) Info() (*models.RegistrationInfo, error) {
+	settings, err := s.Settings()
+	if err != nil {
+		return nil, err
+	}
+	return &models.RegistrationInfo{Mode: settings.Mode, InviteCodeRequired: settings.Mode == models.RegistrationInvite}, nil
+}
+
+func (s *RegistrationService) UpdateSettings(adminID int, req *models.UpdateRegistrationRequest) (*models.RegistrationSettings, error) {
+	if !models.ValidRegistrationMode(req.Mode) {
+		return nil, errors.New("invalid registration mode")
+	}
+	domains := []string{}
+	for _, d := range req.AllowedDomains {
+		d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "@")
+		if !domainPattern.MatchString(d) {
+			return nil, errors.New("invalid domain")
+		}
+		domains = append(domains, d)
+	}
+	if req.Mode == models.RegistrationDomain && len(domains) == 0 {
+		return nil, errors.New("allowed domains required")
+	}
+
+	if err := s.Repo.SaveSettings(&models.RegistrationSettings{Mode: req.Mode, AllowedDomains: domains}, adminID); err != nil {
+		return nil, err
+	}
+	log.Printf("Admin %d set registration mode to %s (domains: %s)", adminID, req.Mode, strings.Join(domains, ","))
+	return s.Settings()
+}
+
+// Register signs someone up through the public form if the registration
+// mode lets them in.
+func (s *RegistrationService) Register(req *models.RegisterRequest) (*models.User, error) {
+	settings, err := s.Settings()
+	if err != nil {
+		return nil, err
+	}
+
+	var code *models.InviteCode
+	byDomain := false
+	switch settings.Mode {
+	case models.RegistrationOpen:
+	case models.RegistrationInvite:
+		if req.InviteCode == "" {
+			return nil, errors.New("invite code required")
+		}
+		if code, err = s.claimCode(req.InviteCode); err != nil {
+			return nil, err
+		}
+	case models.RegistrationDomain:
+		if req.InviteCode != "" {
+			if code, err = s.claimCode(req.InviteCode); err != nil {
+				return nil, err
+			}
+		} else if !domainAllowed(normalizeEmail(req.Email), settings.AllowedDomains) {
+			return nil, errors.New("email domain not allowed")
+		} else {
+			// Anyone can type an address in an allowed domain; only its owner can verify it
+			byDomain = true
+		}
+	default:
+		return nil, errors.New("registration closed")
+	}
+
+	user, err := s.Auth.newUser(req)
+	if err == nil {
+		user.AdmittedByDomain = byDomain
+		err = s.Auth.Repo.CreateUser(user)
+	}
+	if err != nil {
+		s.ReleaseCode(code)
+		return nil, err
+	}
+	if code != nil {
+		log.Printf("User %d registered with invite code %d", user.ID, code.ID)
+	}
+	return user, nil
+}
+
+// CheckOrgInvite is for people an organization invited. Anyone can create an
+// organization and invite people, so the org invite only admits them where
+// anyone could sign up anyway: in open mode, and in domain mode if it was
+// emailed to an allowed domain. Otherwise they need an admin's invite code
+// too. The claimed code, if any, must be given back with ReleaseCode if the
+// sign-up fails.
+func (s *RegistrationService) CheckOrgInvite(invite *models.OrgInvite, inviteCode string) (*models.InviteCode, error) {
+	settings, err := s.Settings()
+	if err != nil {
+		return nil, err
+	}
+	switch settings.Mode {
+	case models.RegistrationOpen:
+		return nil, nil
+	case models.RegistrationInvite, models.RegistrationDomain:
+		if settings.Mode == models.RegistrationDomain && invite.Email != "" && domainAllowed(invite.Email, settings.AllowedDomains) {
+			return nil, nil
+		}
+		if inviteCode == "" {
+			return nil, errors.New("invite code required")
+		}
+		return s.claimCode(inviteCode)
+	default:
+		return nil, errors.New("registration closed")
+	}
+}
+
+// CheckExternalSignup is for people signing in through an identity provider
+// for the first time. There is no invite code to give, so they only get in
+// where anyone could sign up: in open mode, and in domain mode if the
+// provider vouches for an address in an allowed domain.
+func (s *RegistrationService) CheckExternalSignup(email string, emailVerified bool) error {
+	settings, err := s.Settings()
+	if err != nil {
+		return err
+	}
+	switch settings.Mode {
+	case models.RegistrationOpen:
+		return nil
+	case models.RegistrationDomain:
+		if !emailVerified || !domainAllowed(normalizeEmail(email), settings.AllowedDomains) {
+			return errors.New("email domain not allowed")
+		}
+		return nil
+	default:
+		return errors.New("registration closed")
+	}
+}
+
+// ReleaseCode gives back the use of a code whose sign-up failed.
+func (s *RegistrationService) ReleaseCode(code *models.InviteCode) {
+	if code == nil {
+		return
+	}
+	if err := s.Repo.ReleaseCode(code.ID); err != nil {
+		log.Printf("Failed to release invite code %d: %v", code.ID, err)
+	}
+}
+
+// claimCode uses up one sign-up of a valid invite code.
+func (s *RegistrationService) claimCode(plain string) (*models.InviteCode, error) {
+	code, err := s.Repo.GetCodeByHash(hashToken(strings.TrimSpace(plain)))
+	if err != nil {
+		return nil, err
+	}
+	if code == nil || !code.Usable(time.Now().UTC()) {
+		return nil, errors.New("invalid invite code")
+	}
+	ok, err := s.Repo.UseCode(code.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("invalid invite code")
+	}
+	return code, nil
+}
+
+func (s *RegistrationService) CreateCode(adminID int, req *models.CreateInviteCodeRequest) (*models.CreateInviteCodeResponse, error) {
+	ttl := s.Auth.Config.InviteCodeTTL
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+	maxUses := req.MaxUses
+	if maxUses == 0 {
+		maxUses = 1
+	}
+	plain, err := randomToken(12)
+	if err != nil {
+		return nil, err
+	}
+	code := &models.InviteCode{
+		Note:      strings.TrimSpace(req.Note),
+		CodeHash:  hashToken(plain),
+		MaxUses:   maxUses,
+		CreatedBy: adminID,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}
+	if err := s.Repo.CreateCode(code); err != nil {
+		return nil, err
+	}
+	log.Printf("Admin %d created invite code %d for %d sign-ups", adminID, code.ID, maxUses)
+	return &models.CreateInviteCodeResponse{Code: plain, InviteCode: *code}, nil
+}
+
+func (s *RegistrationService) ListCodes() ([]models.InviteCode, error) {
+	return s.Repo.ListCodes()
+}
+
+func (s *RegistrationService) RevokeCode(adminID, id int) error {
+	ok, err := s.Repo.RevokeCode(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invite code not found")
+	}
+	log.Printf("Admin %d revoked invite code %d", adminID, id)
+	return nil
+}
+
+func domainAllowed(email string, domains []string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, d := range domains {
+		if domain == d {
+			return true
+		}
+	}
+	return false
+}
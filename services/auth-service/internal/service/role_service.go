@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"regexp"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
+)
+
+// Role names end up in tokens and URLs, so keep them simple
+var roleNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,19}$`)
+
+type RoleService struct {
+	Roles *repository.RoleRepository
+}
+
+func NewRoleService(roles *repository.RoleRepository) *RoleService {
+	return &RoleService{Roles: roles}
+}
+
+func (s *RoleService) ListRoles() ([]models.Role, error) {
+	return s.Roles.ListRoles()
+}
+
+func (s *RoleService) ListPermissions() ([]models.Permission, error) {
+	return s.Roles.ListPermissions()
+}
+
+// PutRole creates or replaces a role. Changes reach existing access tokens
+// only when they are refreshed. Admins cannot take roles:manage away from
+// their own role, nor from the last role that active users hold it through,
+// and can only edit roles whose old and new permissions they hold.
+func (s *RoleService) PutRole(callerRole, name string, req *models.PutRoleRequest) (*models.Role, error) {
+	if !roleNamePattern.MatchString(name) {
+		return nil, errors.New("invalid role name")
+	}
+
+	known, err := s.Roles.ListPermissions()
+	if err != nil {
+		return nil, err
+	}
+	valid := make(map[string]bool, len(known))
+	for _, p := range known {
+		valid[p.Name] = true
+	}
+	seen := make(map[string]bool)
+	perms := []string{}
+	for _, p := range req.Permissions {
+		if !valid[p] {
+			return nil, errors.New("unknown permission: " + p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			perms = append(perms, p)
+		}
+	}
+
+	if name == callerRole && !seen[models.PermRolesManage] {
+		return nil, errors.New("cannot remove own role management")
+	}
+
+	callerPerms, err := s.Roles.GetPermissions(callerRole)
+	if err != nil {
+		return nil, err
+	}
+	var current []string
+	existing, err := s.Roles.GetRole(name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		current = existing.Permissions
+	}
+	if err := checkGrant(callerPerms, current, perms); err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{Name: name, Description: req.Description, Permissions: perms}
+	log.Printf("Saving role %s with permissions %v", name, perms)
+	if err := s.Roles.PutRole(role); err != nil {
+		return nil, err
+	}
+	return s.Roles.GetRole(name)
+}
+
+// checkGrant returns an error unless the caller holds every permission a
+// role has now and every one it is about to get.
+func checkGrant(callerPerms, current, requested []string) error {
+	if !holdsAll(callerPerms, current) || !holdsAll(callerPerms, requested) {
+		return errors.New("role has more permissions")
+	}
+	return nil
+}
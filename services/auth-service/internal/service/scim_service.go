@@ -0,0 +1,665 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/scim"
+)
+
+// ScimService lets an identity provider create, update and deactivate
+// accounts. A tenant can only touch the accounts and groups it created.
+type ScimService struct {
+	Auth *AuthService
+	Scim *repository.ScimRepository
+}
+
+func NewScimService(auth *AuthService, scimRepo *repository.ScimRepository) *ScimService {
+	return &ScimService{Auth: auth, Scim: scimRepo}
+}
+
+const scimMaxPageSize = 200
+
+// scimPage converts SCIM paging to an offset and limit. Out of range values
+// are clamped rather than rejected, as RFC 7644 asks.
+func scimPage(req *models.ScimListRequest) (int, int) {
+	if req.StartIndex < 1 {
+		req.StartIndex = 1
+	}
+	if req.Count < 0 {
+		req.Count = 0
+	}
+	if req.Count > scimMaxPageSize {
+		req.Count = scimMaxPageSize
+	}
+	return req.StartIndex - 1, req.Count
+}
+
+func (s *ScimService) ListUsers(tenant *scim.Tenant, req *models.ScimListRequest) ([]models.ScimUser, int, error) {
+	filter, err := scim.ParseFilter(req.Filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset, limit := scimPage(req)
+	return s.Scim.ListUsers(tenant.Name, filter, offset, limit)
+}
+
+func (s *ScimService) GetUser(tenant *scim.Tenant, userID int) (*models.ScimUser, error) {
+	user, err := s.Scim.GetUser(tenant.Name, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+// CreateUser provisions an account owned by the tenant. The address is
+// trusted as verified since the provider manages it.
+func (s *ScimService) CreateUser(tenant *scim.Tenant, res *models.ScimUserResource) (*models.ScimUser, error) {
+	username := strings.TrimSpace(res.UserName)
+	if err := s.checkUsername(username, 0); err != nil {
+		return nil, err
+	}
+	email := primaryEmail(res.Emails)
+	if err := s.checkEmail(email, 0); err != nil {
+		return nil, err
+	}
+	known, err := s.Auth.Roles.GetRole(tenant.DefaultRole)
+	if err != nil {
+		return nil, err
+	}
+	if known == nil {
+		log.Printf("SCIM tenant %s has unknown default role %q", tenant.Name, tenant.DefaultRole)
+		return nil, errors.New("invalid role")
+	}
+
+	var hash string
+	if res.Password != "" {
+		hash, err = s.Auth.Passwords.Hash(res.Password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	user := &models.User{Username: username, PasswordHash: hash, Role: tenant.DefaultRole, Email: email, Source: models.SourceSCIM}
+	if err := s.Auth.Repo.CreateUser(user); err != nil {
+		return nil, err
+	}
+	if err := s.Scim.LinkUser(tenant.Name, user.ID, res.ExternalID); err != nil {
+		return nil, err
+	}
+	if email != "" {
+		if _, err := s.Auth.Repo.MarkEmailVerified(user.ID, email); err != nil {
+			return nil, err
+		}
+	}
+	if res.Active != nil && !*res.Active {
+		if err := s.Auth.Repo.SetDisabled(user.ID, true); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("SCIM tenant %s provisioned user %s (id %d)", tenant.Name, user.Username, user.ID)
+	s.Auth.Events.Record(&models.SecurityEvent{Type: models.EventAccountProvision, Outcome: models.OutcomeSuccess, UserID: &user.ID, Detail: "source=scim:" + tenant.Name})
+	return s.GetUser(tenant, user.ID)
+}
+
+// userChange collects the attributes a PATCH request touches.
+type userChange struct {
+	UserName   *string
+	Email      *string
+	ExternalID *string
+	Active     *bool
+}
+
+func (s *ScimService) PatchUser(tenant *scim.Tenant, userID int, ops []models.ScimPatchOperation) (*models.ScimUser, error) {
+	user, err := s.GetUser(tenant, userID)
+	if err != nil {
+		return nil, err
+	}
+	var change userChange
+	for _, op := range ops {
+		if err := applyUserOperation(&change, op); err != nil {
+			return nil, err
+		}
+	}
+
+	if change.UserName != nil && *change.UserName != user.Username {
+		if err := s.checkUsername(*change.UserName, user.ID); err != nil {
+			return nil, err
+		}
+		if err := s.Auth.Repo.SetUsername(user.ID, *change.UserName); err != nil {
+			return nil, err
+		}
+	}
+	if change.Email != nil && *change.Email != user.Email {
+		if err := s.checkEmail(*change.Email, user.ID); err != nil {
+			return nil, err
+		}
+		if err := s.Auth.Repo.SetEmail(user.ID, *change.Email, *change.Email != ""); err != nil {
+			return nil, err
+		}
+	}
+	if change.ExternalID != nil && *change.ExternalID != user.ExternalID {
+		if err := s.Scim.SetExternalID(tenant.Name, user.ID, *change.ExternalID); err != nil {
+			return nil, err
+		}
+	}
+	if change.Active != nil && *change.Active == user.Disabled() {
+		if err := s.setActive(tenant, user.ID, *change.Active); err != nil {
+			return nil, err
+		}
+	}
+	return s.GetUser(tenant, user.ID)
+}
+
+// DeleteUser deactivates the account and releases it from the tenant. The
+// account and its links are kept so an admin can still review them, but its
+// username and email are freed for the provider to provision the user again.
+func (s *ScimService) DeleteUser(tenant *scim.Tenant, userID int) error {
+	user, err := s.GetUser(tenant, userID)
+	if err != nil {
+		return err
+	}
+	if !user.Disabled() {
+		if err := s.setActive(tenant, user.ID, false); err != nil {
+			return err
+		}
+	}
+	// Before unlinking, so a failed request can be retried
+	if err := s.Auth.Repo.ReleaseIdentifiers(user.ID); err != nil {
+		return err
+	}
+	return s.Scim.UnlinkUser(tenant.Name, user.ID)
+}
+
+// setActive disables or re-enables an account. Disabling logs the user out
+// everywhere, which makes link-management reject their tokens.
+func (s *ScimService) setActive(tenant *scim.Tenant, userID int, active bool) error {
+	log.Printf("SCIM tenant %s set active=%t for user %d", tenant.Name, active, userID)
+	if err := s.Auth.Repo.SetDisabled(userID, !active); err != nil {
+		return err
+	}
+	eventType := models.EventAccountDisable
+	if active {
+		eventType = models.EventAccountEnable
+	}
+	s.Auth.Events.Record(&models.SecurityEvent{Type: eventType, Outcome: models.OutcomeSuccess, UserID: &userID, Detail: "source=scim:" + tenant.Name})
+	if active {
+		return nil
+	}
+	return s.Auth.Sessions.RevokeUserSessions(userID)
+}
+
+func (s *ScimService) checkUsername(username string, userID int) error {
+	if username == "" || len(username) > 50 {
+		return errors.New("invalid value")
+	}
+	existing, err := s.Auth.Repo.GetUserByUsername(username)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != userID {
+		return errors.New("username already exists")
+	}
+	return nil
+}
+
+func (s *ScimService) checkEmail(email string, userID int) error {
+	if email == "" {
+		return nil
+	}
+	existing, err := s.Auth.Repo.GetUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != userID {
+		return errors.New("email already in use")
+	}
+	return nil
+}
+
+func (s *ScimService) ListGroups(tenant *scim.Tenant, req *models.ScimListRequest) ([]models.ScimGroup, int, error) {
+	filter, err := scim.ParseFilter(req.Filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset, limit := scimPage(req)
+	return s.Scim.ListGroups(tenant.Name, filter, offset, limit)
+}
+
+func (s *ScimService) GetGroup(tenant *scim.Tenant, groupID int) (*models.ScimGroup, error) {
+	group, err := s.Scim.GetGroup(tenant.Name, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, errors.New("group not found")
+	}
+	return group, nil
+}
+
+func (s *ScimService) CreateGroup(tenant *scim.Tenant, res *models.ScimGroupResource) (*models.ScimGroup, error) {
+	group := &models.ScimGroup{DisplayName: strings.TrimSpace(res.DisplayName), ExternalID: res.ExternalID}
+	if err := s.checkGroupName(tenant, group.DisplayName, 0); err != nil {
+		return nil, err
+	}
+	members, err := s.resolveMembers(tenant, memberIDs(res.Members))
+	if err != nil {
+		return nil, err
+	}
+	group.Members = members
+
+	if err := s.Scim.CreateGroup(tenant.Name, group); err != nil {
+		return nil, err
+	}
+	if err := s.syncRoles(tenant, members); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(tenant, group.ID)
+}
+
+// groupChange collects the attributes a PATCH request touches. Members holds
+// the complete new member list once any operation changed it.
+type groupChange struct {
+	DisplayName *string
+	ExternalID  *string
+	Members     map[string]bool
+}
+
+func (s *ScimService) PatchGroup(tenant *scim.Tenant, groupID int, ops []models.ScimPatchOperation) (*models.ScimGroup, error) {
+	group, err := s.GetGroup(tenant, groupID)
+	if err != nil {
+		return nil, err
+	}
+	change := groupChange{Members: map[string]bool{}}
+	for _, m := range group.Members {
+		change.Members[strconv.Itoa(m.UserID)] = true
+	}
+	for _, op := range ops {
+		if err := applyGroupOperation(&change, op); err != nil {
+			return nil, err
+		}
+	}
+
+	renamed := false
+	if change.DisplayName != nil || change.ExternalID != nil {
+		if change.DisplayName != nil && *change.DisplayName != group.DisplayName {
+			if err := s.checkGroupName(tenant, *change.DisplayName, group.ID); err != nil {
+				return nil, err
+			}
+			group.DisplayName = *change.DisplayName
+			renamed = true
+		}
+		if change.ExternalID != nil {
+			group.ExternalID = *change.ExternalID
+		}
+		if err := s.Scim.UpdateGroup(tenant.Name, group); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]string, 0, len(change.Members))
+	for id := range change.Members {
+		ids = append(ids, id)
+	}
+	members, err := s.resolveMembers(tenant, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Scim.SetMembers(group.ID, members); err != nil {
+		return nil, err
+	}
+
+	// A rename can change the role of everyone in the group, otherwise only
+	// members who joined or left are affected
+	affected := map[int]models.ScimMember{}
+	for _, m := range group.Members {
+		affected[m.UserID] = m
+	}
+	for _, m := range members {
+		if _, ok := affected[m.UserID]; ok && !renamed {
+			delete(affected, m.UserID)
+			continue
+		}
+		affected[m.UserID] = m
+	}
+	changed := make([]models.ScimMember, 0, len(affected))
+	for _, m := range affected {
+		changed = append(changed, m)
+	}
+	if err := s.syncRoles(tenant, changed); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(tenant, group.ID)
+}
+
+func (s *ScimService) DeleteGroup(tenant *scim.Tenant, groupID int) error {
+	group, err := s.GetGroup(tenant, groupID)
+	if err != nil {
+		return err
+	}
+	if err := s.Scim.DeleteGroup(tenant.Name, group.ID); err != nil {
+		return err
+	}
+	return s.syncRoles(tenant, group.Members)
+}
+
+func (s *ScimService) checkGroupName(tenant *scim.Tenant, name string, groupID int) error {
+	if name == "" || len(name) > 255 {
+		return errors.New("invalid value")
+	}
+	existing, _, err := s.Scim.ListGroups(tenant.Name, &models.ScimFilter{Attribute: "displayname", Value: name}, 0, 1)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 && existing[0].ID != groupID {
+		return errors.New("group already exists")
+	}
+	return nil
+}
+
+// resolveMembers checks that every member is an account of this tenant.
+func (s *ScimService) resolveMembers(tenant *scim.Tenant, ids []string) ([]models.ScimMember, error) {
+	members := make([]models.ScimMember, 0, len(ids))
+	for _, id := range ids {
+		userID, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, errors.New("unknown member")
+		}
+		user, err := s.Scim.GetUser(tenant.Name, userID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, errors.New("unknown member")
+		}
+		members = append(members, models.ScimMember{UserID: user.ID, Username: user.Username})
+	}
+	return members, nil
+}
+
+// syncRoles gives each user the role their groups map to. Sessions are
+// revoked on a change because access tokens carry the role.
+func (s *ScimService) syncRoles(tenant *scim.Tenant, members []models.ScimMember) error {
+	if len(tenant.GroupRoles) == 0 {
+		return nil
+	}
+	for _, m := range members {
+		groups, err := s.Scim.GroupNamesForUser(tenant.Name, m.UserID)
+		if err != nil {
+			return err
+		}
+		role := tenant.RoleFor(groups)
+		user, err := s.Auth.Repo.GetUserByID(m.UserID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.Role == role {
+			continue
+		}
+		known, err := s.Auth.Roles.GetRole(role)
+		if err != nil {
+			return err
+		}
+		if known == nil {
+			log.Printf("SCIM tenant %s maps user %d to unknown role %q", tenant.Name, user.ID, role)
+			continue
+		}
+
+		log.Printf("SCIM tenant %s changed role of user %d from %s to %s", tenant.Name, user.ID, user.Role, role)
+		if err := s.Auth.Repo.SetRole(user.ID, role); err != nil {
+			return err
+		}
+		if err := s.Auth.Sessions.RevokeUserSessions(user.ID); err != nil {
+			return err
+		}
+		s.Auth.Events.Record(&models.SecurityEvent{Type: models.EventRoleChange, Outcome: models.OutcomeSuccess, UserID: &user.ID, Detail: "role=" + role + " source=scim:" + tenant.Name})
+	}
+	return nil
+}
+
+// patchTarget splits an operation into its lower case op and attribute path,
+// dropping the schema URN providers sometimes put in front.
+func patchTarget(op models.ScimPatchOperation) (string, string, error) {
+	name := strings.ToLower(op.Op)
+	if name != "add" && name != "replace" && name != "remove" {
+		return "", "", errors.New("invalid patch")
+	}
+	path := strings.TrimSpace(op.Path)
+	if strings.HasPrefix(strings.ToLower(path), "urn:") {
+		bracket := strings.Index(path, "[")
+		if bracket < 0 {
+			bracket = len(path)
+		}
+		if i := strings.LastIndex(path[:bracket], ":"); i >= 0 {
+			path = path[i+1:]
+		}
+	}
+	return name, path, nil
+}
+
+func applyUserOperation(change *userChange, op models.ScimPatchOperation) error {
+	name, path, err := patchTarget(op)
+	if err != nil {
+		return err
+	}
+
+	// Without a path the value is an object of attributes to set
+	if path == "" {
+		if name == "remove" {
+			return errors.New("invalid path")
+		}
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &attrs); err != nil {
+			return errors.New("invalid value")
+		}
+		for attr, value := range attrs {
+			if err := setUserAttribute(change, strings.ToLower(attr), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	attr := strings.ToLower(path)
+	if name == "remove" {
+		empty := ""
+		switch {
+		case attr == "externalid":
+			change.ExternalID = &empty
+		case strings.HasPrefix(attr, "emails"):
+			change.Email = &empty
+		case attr == "username", attr == "active":
+			return errors.New("invalid path")
+		}
+		return nil
+	}
+	return setUserAttribute(change, attr, op.Value)
+}
+
+// setUserAttribute applies one attribute. Attributes the service does not
+// store, such as name or title, are accepted and ignored.
+func setUserAttribute(change *userChange, attr string, value json.RawMessage) error {
+	switch {
+	case attr == "username":
+		var username string
+		if err := json.Unmarshal(value, &username); err != nil {
+			return errors.New("invalid value")
+		}
+		username = strings.TrimSpace(username)
+		change.UserName = &username
+	case attr == "externalid":
+		var externalID string
+		if err := json.Unmarshal(value, &externalID); err != nil {
+			return errors.New("invalid value")
+		}
+		change.ExternalID = &externalID
+	case attr == "active":
+		active, err := scimBool(value)
+		if err != nil {
+			return err
+		}
+		change.Active = &active
+	case attr == "emails" || attr == "emails.value" || strings.HasPrefix(attr, "emails["):
+		email, err := scimEmail(value)
+		if err != nil {
+			return err
+		}
+		change.Email = &email
+	}
+	return nil
+}
+
+// scimBool accepts true/false and the "True"/"False" strings some providers send.
+func scimBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		if parsed, err := strconv.ParseBool(s); err == nil {
+			return parsed, nil
+		}
+	}
+	return false, errors.New("invalid value")
+}
+
+// scimEmail reads an address given as a string, an email object or a list of them.
+func scimEmail(value json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return normalizeEmail(s), nil
+	}
+	var list []models.ScimEmail
+	if err := json.Unmarshal(value, &list); err == nil {
+		return primaryEmail(list), nil
+	}
+	var one models.ScimEmail
+	if err := json.Unmarshal(value, &one); err == nil {
+		return normalizeEmail(one.Value), nil
+	}
+	return "", errors.New("invalid value")
+}
+
+func primaryEmail(emails []models.ScimEmail) string {
+	for _, e := range emails {
+		if e.Primary {
+			return normalizeEmail(e.Value)
+		}
+	}
+	if len(emails) > 0 {
+		return normalizeEmail(emails[0].Value)
+	}
+	return ""
+}
+
+func applyGroupOperation(change *groupChange, op models.ScimPatchOperation) error {
+	name, path, err := patchTarget(op)
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		if name == "remove" {
+			return errors.New("invalid path")
+		}
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &attrs); err != nil {
+			return errors.New("invalid value")
+		}
+		for attr, value := range attrs {
+			if err := setGroupAttribute(change, name, strings.ToLower(attr), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	attr := strings.ToLower(path)
+	if name != "remove" {
+		return setGroupAttribute(change, name, attr, op.Value)
+	}
+	switch {
+	case attr == "externalid":
+		empty := ""
+		change.ExternalID = &empty
+	case attr == "members":
+		// With a value only the listed members go, otherwise everyone does
+		if len(op.Value) == 0 || string(op.Value) == "null" {
+			change.Members = map[string]bool{}
+			return nil
+		}
+		ids, err := memberValues(op.Value)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			delete(change.Members, id)
+		}
+	case strings.HasPrefix(attr, "members["):
+		// members[value eq "42"]
+		filter, err := scim.ParseFilter(strings.TrimSuffix(path[len("members["):], "]"))
+		if err != nil || filter == nil || filter.Attribute != "value" {
+			return errors.New("invalid path")
+		}
+		delete(change.Members, filter.Value)
+	default:
+		return errors.New("invalid path")
+	}
+	return nil
+}
+
+func setGroupAttribute(change *groupChange, op, attr string, value json.RawMessage) error {
+	switch attr {
+	case "displayname":
+		var displayName string
+		if err := json.Unmarshal(value, &displayName); err != nil {
+			return errors.New("invalid value")
+		}
+		displayName = strings.TrimSpace(displayName)
+		change.DisplayName = &displayName
+	case "externalid":
+		var externalID string
+		if err := json.Unmarshal(value, &externalID); err != nil {
+			return errors.New("invalid value")
+		}
+		change.ExternalID = &externalID
+	case "members":
+		ids, err := memberValues(value)
+		if err != nil {
+			return err
+		}
+		if op == "replace" {
+			change.Members = map[string]bool{}
+		}
+		for _, id := range ids {
+			change.Members[id] = true
+		}
+	}
+	return nil
+}
+
+func memberValues(value json.RawMessage) ([]string, error) {
+	var refs []models.ScimMemberRef
+	if err := json.Unmarshal(value, &refs); err != nil {
+		return nil, errors.New("invalid value")
+	}
+	return memberIDs(refs), nil
+}
+
+func memberIDs(refs []models.ScimMemberRef) []string {
+	ids := make([]string, 0, len(refs))
+	for _, r := range refs {
+		ids = append(ids, strings.TrimSpace(r.Value))
+	}
+	return ids
+}
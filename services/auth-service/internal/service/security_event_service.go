@@ -0,0 +1,72 @@
+package service
+
+import (
+	"log"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/events"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/repository"
+)
+
+// SecurityEventService keeps the security log and forwards each event to
+// the configured sink.
+type SecurityEventService struct {
+	Repo  *repository.SecurityEventRepository
+	Users *repository.UserRepository
+	Sink  events.Sink // May be nil
+}
+
+func NewSecurityEventService(repo *repository.SecurityEventRepository, users *repository.UserRepository, sink events.Sink) *SecurityEventService {
+	return &SecurityEventService{Repo: repo, Users: users, Sink: sink}
+}
+
+// Record stores an event. Errors are logged rather than returned so a
+// logging problem never blocks a login. Events that only name a username,
+// such as failed logins, are attributed to that account if it exists.
+func (s *SecurityEventService) Record(e *models.SecurityEvent) {
+	if e.UserID == nil && e.Username != "" {
+		user, err := s.Users.GetUserByUsername(e.Username)
+		if err != nil {
+			log.Printf("Failed to resolve user for security event: %v", err)
+		} else if user != nil {
+			e.UserID = &user.ID
+		}
+	}
+	e.UserAgent = truncate(e.UserAgent, 512)
+	e.Detail = truncate(e.Detail, 255)
+	e.Username = truncate(e.Username, 50)
+
+	if err := s.Repo.CreateEvent(e); err != nil {
+		log.Printf("Failed to store security event %s/%s: %v", e.Type, e.Outcome, err)
+	}
+	if s.Sink != nil {
+		if err := s.Sink.Emit(e); err != nil {
+			log.Printf("Failed to emit security event %d: %v", e.ID, err)
+		}
+	}
+}
+
+// ListForUser returns the user's own history; any user_id in the query is ignored.
+func (s *SecurityEventService) ListForUser(userID int, q *models.SecurityEventQuery) (*models.SecurityEventPage, error) {
+	q.UserID = userID
+	return s.Query(q)
+}
+
+func (s *SecurityEventService) Query(q *models.SecurityEventQuery) (*models.SecurityEventPage, error) {
+	list, total, err := s.Repo.ListEvents(q)
+	if err != nil {
+		return nil, err
+	}
+	return &models.SecurityEventPage{Events: list, Total: total, Page: q.Page, PageSize: q.PageSize}, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	// Do not cut a multi-byte character in half
+	for n > 0 && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
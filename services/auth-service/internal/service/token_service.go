@@ -0,0 +1,235 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+// startSession opens a new token family for the user and returns its first token pair.
+func (s *AuthService) startSession(user *models.User) (*models.TokenPair, error) {
+	if user.Disabled() {
+		return nil, errors.New("account disabled")
+	}
+	if user.AwaitingVerification() {
+		return nil, errors.New("email not verified")
+	}
+
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		ID:        sessionID,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(s.Config.RefreshTokenTTL),
+	}
+	if err := s.Sessions.CreateSession(session); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, session)
+}
+
+func (s *AuthService) issueTokens(user *models.User, session *models.Session) (*models.TokenPair, error) {
+	accessToken, err := s.signAccessToken(user, session)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refresh tokens never outlive the session they belong to
+	expiresAt := time.Now().UTC().Add(s.Config.RefreshTokenTTL)
+	if expiresAt.After(session.ExpiresAt) {
+		expiresAt = session.ExpiresAt
+	}
+
+	if err := s.Sessions.CreateRefreshToken(&models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.Config.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// signAccessToken adds an act claim (RFC 8693) naming the admin for
+// impersonation sessions; those tokens end with their session.
+func (s *AuthService) signAccessToken(user *models.User, session *models.Session) (string, error) {
+	key, err := s.Keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+	// Resolved on every issue so role edits apply from the next refresh
+	perms, err := s.Roles.GetPermissions(user.Role)
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := time.Now().Add(s.Config.AccessTokenTTL)
+	claims := jwt.MapClaims{
+		"sub":   user.ID,
+		"role":  user.Role,
+		"perms": perms,
+		"sid":   session.ID,
+		// Unverified accounts get fewer features in link-management
+		"email_verified": user.EmailVerified,
+	}
+	if session.ImpersonatorID != nil {
+		claims["act"] = map[string]interface{}{"sub": *session.ImpersonatorID}
+		if session.ExpiresAt.Before(expiresAt) {
+			expiresAt = session.ExpiresAt
+		}
+	}
+	claims["exp"] = expiresAt.Unix()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.PrivateKey)
+}
+
+// Refresh rotates a refresh token. Presenting a token that was already rotated
+// is treated as theft and revokes the whole session.
+func (s *AuthService) Refresh(req *models.RefreshRequest) (*models.TokenPair, *models.User, error) {
+	stored, err := s.Sessions.GetRefreshTokenByHash(hashToken(req.RefreshToken))
+	if err != nil {
+		return nil, nil, err
+	}
+	if stored == nil || time.Now().UTC().After(stored.ExpiresAt) {
+		return nil, nil, errors.New("invalid refresh token")
+	}
+
+	session, err := s.Sessions.GetSession(stored.SessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session == nil || session.RevokedAt != nil || time.Now().UTC().After(session.ExpiresAt) {
+		return nil, nil, errors.New("invalid refresh token")
+	}
+
+	fresh, err := s.Sessions.MarkRefreshTokenUsed(stored.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stored.UsedAt != nil || !fresh {
+		log.Printf("Refresh token reuse detected, revoking session %s", session.ID)
+		if err := s.Sessions.RevokeSession(session.ID); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, errors.New("invalid refresh token")
+	}
+
+	user, err := s.Repo.GetUserByID(session.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil || user.Disabled() {
+		return nil, nil, errors.New("invalid refresh token")
+	}
+
+	tokens, err := s.issueTokens(user, session)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tokens, user, nil
+}
+
+// Logout revokes the session of the refresh token and returns its user, or 0
+// if the token was unknown.
+func (s *AuthService) Logout(req *models.LogoutRequest) (int, error) {
+	stored, err := s.Sessions.GetRefreshTokenByHash(hashToken(req.RefreshToken))
+	if err != nil {
+		return 0, err
+	}
+	if stored == nil {
+		return 0, nil
+	}
+	session, err := s.Sessions.GetSession(stored.SessionID)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.Sessions.RevokeSession(stored.SessionID); err != nil {
+		return 0, err
+	}
+	if session == nil {
+		return 0, nil
+	}
+	return session.UserID, nil
+}
+
+// ValidateAccessToken verifies an access token issued by this service and
+// checks that its session has not been revoked and its user is not disabled
+// or deleted. Disabling revokes sessions too, but the token must not depend
+// on that.
+func (s *AuthService) ValidateAccessToken(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key := s.Keys.Key(kid)
+		if key == nil {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return key.PrivateKey.Public(), nil
+	}, jwt.WithValidMethods([]string{"EdDSA"}))
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid token")
+	}
+
+	sid, _ := claims["sid"].(string)
+	if sid == "" {
+		return nil, errors.New("invalid token")
+	}
+	session, err := s.Sessions.GetSession(sid)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.RevokedAt != nil {
+		return nil, errors.New("session revoked")
+	}
+	user, err := s.Repo.GetUserByID(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Disabled() || user.Deleted() {
+		return nil, errors.New("session revoked")
+	}
+
+	return claims, nil
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
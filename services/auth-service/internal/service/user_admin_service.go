@@ -0,0 +1,221 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/auth-service/internal/models"
+)
+
+// UserAdminService backs the admin endpoints for managing other accounts.
+type UserAdminService struct {
+	Auth *AuthService
+}
+
+func NewUserAdminService(auth *AuthService) *UserAdminService {
+	return &UserAdminService{Auth: auth}
+}
+
+func (s *UserAdminService) ListUsers(req *models.ListUsersRequest) (*models.UserPage, error) {
+	users, total, err := s.Auth.Repo.ListUsers(req)
+	if err != nil {
+		return nil, err
+	}
+	return &models.UserPage{Users: users, Total: total, Page: req.Page, PageSize: req.PageSize}, nil
+}
+
+func (s *UserAdminService) GetUser(userID int) (*models.User, error) {
+	return s.getUser(userID)
+}
+
+// ChangeRole revokes the user's sessions because access tokens carry the
+// role; without this a demoted admin would keep admin tokens until they expire.
+// Admins can only change the role of users whose permissions they hold, and
+// only to a role whose permissions they hold.
+func (s *UserAdminService) ChangeRole(adminID, userID int, role string) (*models.User, error) {
+	known, err := s.Auth.Roles.GetRole(role)
+	if err != nil {
+		return nil, err
+	}
+	if known == nil {
+		return nil, errors.New("invalid role")
+	}
+	if adminID == userID {
+		return nil, errors.New("cannot modify own account")
+	}
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkHeld(adminID, user.Role); err != nil {
+		return nil, err
+	}
+	if user.Role == role {
+		return user, nil
+	}
+	held, err := s.holdsRole(adminID, role)
+	if err != nil {
+		return nil, err
+	}
+	if !held {
+		return nil, errors.New("role has more permissions")
+	}
+
+	log.Printf("Admin %d changed role of user %d from %s to %s", adminID, userID, user.Role, role)
+	if err := s.Auth.Repo.SetRole(userID, role); err != nil {
+		return nil, err
+	}
+	if err := s.Auth.Sessions.RevokeUserSessions(userID); err != nil {
+		return nil, err
+	}
+	return s.getUser(userID)
+}
+
+// SetDisabled disables or re-enables an account. Disabling also logs the user
+// out everywhere, which makes link-management reject their tokens.
+func (s *UserAdminService) SetDisabled(adminID, userID int, disabled bool) (*models.User, error) {
+	if adminID == userID {
+		return nil, errors.New("cannot modify own account")
+	}
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkHeld(adminID, user.Role); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Admin %d set disabled=%t for user %d", adminID, disabled, userID)
+	if err := s.Auth.Repo.SetDisabled(userID, disabled); err != nil {
+		return nil, err
+	}
+	if disabled {
+		if err := s.Auth.Sessions.RevokeUserSessions(userID); err != nil {
+			return nil, err
+		}
+	}
+	return s.getUser(userID)
+}
+
+// ForceLogout revokes every session of the user.
+func (s *UserAdminService) ForceLogout(adminID, userID int) error {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkHeld(adminID, user.Role); err != nil {
+		return err
+	}
+	log.Printf("Admin %d forced logout of user %d", adminID, userID)
+	return s.Auth.Sessions.RevokeUserSessions(userID)
+}
+
+// Impersonate opens a short session as the user so support can see what they
+// see. The token names the admin in its act claim. Admins can only act as
+// users whose permissions they hold themselves.
+func (s *UserAdminService) Impersonate(adminID, userID int, reason string) (*models.ImpersonationResponse, error) {
+	if adminID == userID {
+		return nil, errors.New("cannot modify own account")
+	}
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled() {
+		return nil, errors.New("account disabled")
+	}
+	if err := s.checkHeld(adminID, user.Role); err != nil {
+		return nil, err
+	}
+
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	session := &models.Session{
+		ID:             sessionID,
+		UserID:         user.ID,
+		ImpersonatorID: &adminID,
+		ExpiresAt:      time.Now().UTC().Add(s.Auth.Config.ImpersonationTTL),
+	}
+	if err := s.Auth.Sessions.CreateSession(session); err != nil {
+		return nil, err
+	}
+	token, err := s.Auth.signAccessToken(user, session)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Admin %d is impersonating user %d: %s", adminID, userID, reason)
+	return &models.ImpersonationResponse{
+		Token:     token,
+		ExpiresIn: int64(time.Until(session.ExpiresAt).Seconds()),
+		User:      *user,
+	}, nil
+}
+
+func (s *UserAdminService) getUser(userID int) (*models.User, error) {
+	user, err := s.Auth.Repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Deleted() {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+// CheckManage returns an error unless the user exists and has no permission
+// the admin lacks. Handlers call it before admin actions done by other services.
+func (s *UserAdminService) CheckManage(adminID, userID int) error {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return err
+	}
+	return s.checkHeld(adminID, user.Role)
+}
+
+// checkHeld returns an error unless the admin holds every permission of the
+// user's role, so admins cannot act on accounts with more access than theirs.
+func (s *UserAdminService) checkHeld(adminID int, role string) error {
+	held, err := s.holdsRole(adminID, role)
+	if err != nil {
+		return err
+	}
+	if !held {
+		return errors.New("user has more permissions")
+	}
+	return nil
+}
+
+// holdsRole reports whether the admin holds every permission of role.
+func (s *UserAdminService) holdsRole(adminID int, role string) (bool, error) {
+	admin, err := s.getUser(adminID)
+	if err != nil {
+		return false, err
+	}
+	adminPerms, err := s.Auth.Roles.GetPermissions(admin.Role)
+	if err != nil {
+		return false, err
+	}
+	rolePerms, err := s.Auth.Roles.GetPermissions(role)
+	if err != nil {
+		return false, err
+	}
+	return holdsAll(adminPerms, rolePerms), nil
+}
+
+// holdsAll reports whether held includes every permission in perms.
+func holdsAll(held, perms []string) bool {
+	set := make(map[string]bool, len(held))
+	for _, p := range held {
+		set[p] = true
+	}
+	for _, p := range perms {
+		if !set[p] {
+			return false
+		}
+	}
+	return true
+}
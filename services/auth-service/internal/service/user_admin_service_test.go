@@ -0,0 +1,54 @@
+package service
+
+import "testing"
+
+func TestHoldsAll(t *testing.T) {
+	admin := []string{"keys:manage", "links:manage", "roles:manage", "users:manage"}
+	moderator := []string{"links:manage", "users:manage"}
+	user := []string{}
+
+	tests := []struct {
+		name  string
+		held  []string
+		perms []string
+		want  bool
+	}{
+		{"moderator manages user", moderator, user, true},
+		{"moderator manages moderator", moderator, moderator, true},
+		{"moderator cannot manage or grant admin", moderator, admin, false},
+		{"moderator cannot grant partial overlap", moderator, []string{"links:manage", "keys:manage"}, false},
+		{"admin manages moderator", admin, moderator, true},
+		{"no permissions hold nothing", nil, moderator, false},
+	}
+	for _, tt := range tests {
+		if got := holdsAll(tt.held, tt.perms); got != tt.want {
+			t.Errorf("%s: holdsAll(%v, %v) = %t, want %t", tt.name, tt.held, tt.perms, got, tt.want)
+		}
+	}
+}
+
+func TestCheckGrant(t *testing.T) {
+	admin := []string{"keys:manage", "links:manage", "roles:manage", "users:manage"}
+	roleManager := []string{"links:manage", "roles:manage"}
+
+	tests := []struct {
+		name      string
+		caller    []string
+		current   []string
+		requested []string
+		wantErr   bool
+	}{
+		{"role manager edits weaker role", roleManager, []string{"links:manage"}, []string{}, false},
+		{"role manager creates role within own permissions", roleManager, nil, []string{"links:manage"}, false},
+		{"role manager grants own role more", roleManager, roleManager, append([]string{"keys:manage"}, roleManager...), true},
+		{"role manager grants users:manage", roleManager, nil, []string{"users:manage"}, true},
+		{"role manager strips stronger role", roleManager, admin, []string{"links:manage"}, true},
+		{"admin edits any role", admin, roleManager, admin, false},
+	}
+	for _, tt := range tests {
+		err := checkGrant(tt.caller, tt.current, tt.requested)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkGrant = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
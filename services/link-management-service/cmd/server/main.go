@@ -4,14 +4,17 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/config"
 	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/database"
 	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/handler"
 	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/middleware"
+	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/models"
 	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/repository"
 	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/service"
+	"github.com/shinshark/azure-url-shortener/services/pkg/signing"
 )
 
 func main() {
@@ -27,9 +30,41 @@ func main() {
 
 	// Initialize Layers
 	repo := repository.NewLinkRepository(db)
-	svc := service.NewLinkService(repo, cfg.CacheEvictionUrl)
+	sessions := repository.NewSessionRepository(db)
+	apiKeys := repository.NewApiKeyRepository(db)
+	jwks := middleware.NewJWKSCache(cfg.JWKSUrl)
+	roles := middleware.NewRoleCache(repository.NewRoleRepository(db))
+	var cacheEviction *signing.Client
+	if cfg.CacheEvictionSecret == "" {
+		log.Println("CACHE_EVICTION_SECRET is not set, cached redirects will not be evicted on update or delete")
+	} else if cfg.CacheEvictionUrl != "" {
+		signer, err := signing.NewSigner(cfg.ServiceID, cfg.CacheEvictionSecret)
+		if err != nil {
+			// Every eviction would be rejected, leaving stale redirects cached
+			log.Fatalf("Invalid CACHE_EVICTION_SECRET: %v", err)
+		}
+		cacheEviction = signing.NewClient(signer, 5*time.Second)
+	}
+	var analyticsPurge *signing.Client
+	if cfg.AnalyticsUrl == "" {
+		log.Println("ANALYTICS_URL is not set, click data of deleted accounts will not be purged")
+	} else {
+		signer, err := signing.NewSigner(cfg.ServiceID, cfg.AnalyticsPurgeToken)
+		if err != nil {
+			// Every purge would be rejected, and account deletions would stall on it
+			log.Fatalf("Invalid ANALYTICS_PURGE_TOKEN: %v", err)
+		}
+		analyticsPurge = signing.NewClient(signer, 30*time.Second)
+	}
+	svc := service.NewLinkService(repo, repository.NewOrgRepository(db), cfg.CacheEvictionUrl, cacheEviction, cfg.AnalyticsUrl, analyticsPurge)
 	h := handler.NewLinkHandler(svc)
 
+	// Clean up after accounts deleted in auth-service
+	go service.NewUserEventConsumer(repository.NewUserEventRepository(db), svc).Run(time.Minute)
+
+	// Contribute links and click stats to personal data exports
+	go service.NewExportService(repository.NewExportRepository(db), svc).Run(time.Minute)
+
 	// Initialize Gin router
 	r := gin.Default()
 
@@ -43,12 +78,13 @@ func main() {
 
 	// Routes
 	api := r.Group("/api/links")
-	api.Use(middleware.AuthMiddleware(cfg)) // Apply Auth Middleware
+	api.Use(middleware.AuthMiddleware(jwks, sessions, apiKeys, roles)) // Apply Auth Middleware
+	api.Use(middleware.AuditImpersonation(repository.NewImpersonationAuditRepository(db)))
 	{
-		api.POST("", h.CreateLink)
-		api.GET("", h.GetMyLinks)
-		api.DELETE("/:code", h.DeleteLink)
-		api.PUT("/:code", h.UpdateLink)
+		api.POST("", middleware.RequireScope("links:write"), middleware.RequirePermission(models.PermLinksCreate), h.CreateLink)
+		api.GET("", middleware.RequireScope("links:read"), h.GetMyLinks)
+		api.DELETE("/:code", middleware.RequireScope("links:delete"), h.DeleteLink)
+		api.PUT("/:code", middleware.RequireScope("links:write"), h.UpdateLink)
 	}
 
 	// Start server
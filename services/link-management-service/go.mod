@@ -6,6 +6,7 @@ require (
 	github.com/gin-gonic/gin v1.9.1
 	github.com/golang-jwt/jwt/v5 v5.2.0
 	github.com/microsoft/go-mssqldb v1.6.0
+	github.com/shinshark/azure-url-shortener/services/pkg v0.0.0
 	github.com/teris-io/shortid v0.0.0-20220617161101-71ec9f2aa569
 )
 
@@ -37,3 +38,6 @@ require (
 	google.golang.org/protobuf v1.30.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+// Code shared between services; Docker builds use services/ as the context
+replace github.com/shinshark/azure-url-shortener/services/pkg => ../pkg
@@ -16,3 +16,75 @@ BEGIN
     CREATE INDEX IX_Links_UserID ON Links(UserID);
 END
 GO
+
+-- Links can belong to an organization (OrgMembers is owned by auth-service)
+IF NOT EXISTS (SELECT * FROM sys.columns WHERE object_id = OBJECT_ID('Links') AND name = 'OrgID')
+BEGIN
+    ALTER TABLE Links ADD OrgID INT NULL;
+END
+GO
+
+IF NOT EXISTS (SELECT * FROM sys.indexes WHERE name = 'IX_Links_OrgID')
+BEGIN
+    CREATE INDEX IX_Links_OrgID ON Links(OrgID);
+END
+GO
+
+-- Create UserEventCursors table (how far each consumer has read the UserEvents
+-- outbox that auth-service writes, e.g. user.deleted)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='UserEventCursors' and xtype='U')
+BEGIN
+    CREATE TABLE UserEventCursors (
+        Consumer NVARCHAR(50) PRIMARY KEY,
+        LastEventID BIGINT NOT NULL,
+        UpdatedAt DATETIME NOT NULL DEFAULT GETUTCDATE()
+    );
+END
+GO
+
+-- Create ProcessedUserEvents table (events after a consumer's cursor that it has
+-- already handled; events can commit out of ID order, so the cursor alone would
+-- skip one that shows up late)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='ProcessedUserEvents' and xtype='U')
+BEGIN
+    CREATE TABLE ProcessedUserEvents (
+        Consumer NVARCHAR(50) NOT NULL,
+        EventID BIGINT NOT NULL,
+        ProcessedAt DATETIME NOT NULL DEFAULT GETUTCDATE(),
+        PRIMARY KEY (Consumer, EventID)
+    );
+END
+GO
+
+-- Create LinkExports table (this service's part of a personal data export
+-- requested in auth-service's DataExports: a JSON object of file name to contents)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='LinkExports' and xtype='U')
+BEGIN
+    CREATE TABLE LinkExports (
+        ExportID NVARCHAR(64) PRIMARY KEY,
+        UserID INT NOT NULL,
+        Files NVARCHAR(MAX) NOT NULL,
+        CreatedAt DATETIME NOT NULL DEFAULT GETUTCDATE()
+    );
+END
+GO
+
+-- Create ImpersonationAudit table (every change an admin made to links while
+-- acting as a user through an impersonation token)
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='ImpersonationAudit' and xtype='U')
+BEGIN
+    CREATE TABLE ImpersonationAudit (
+        ID BIGINT IDENTITY(1,1) PRIMARY KEY,
+        ActorID INT NOT NULL,
+        UserID INT NOT NULL,
+        SessionID NVARCHAR(64) NOT NULL,
+        Method NVARCHAR(10) NOT NULL,
+        Path NVARCHAR(255) NOT NULL,
+        Status INT NOT NULL,
+        CreatedAt DATETIME NOT NULL DEFAULT GETUTCDATE()
+    );
+
+    CREATE INDEX IX_ImpersonationAudit_ActorID ON ImpersonationAudit(ActorID);
+    CREATE INDEX IX_ImpersonationAudit_UserID ON ImpersonationAudit(UserID);
+END
+GO
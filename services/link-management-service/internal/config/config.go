@@ -5,24 +5,38 @@ import (
 )
 
 type Config struct {
-	Port         string
-	DBHost       string
-	DBName       string
-	DBUser       string
-	DBPassword   string
-	JWTSecret        string
+	Port             string
+	DBHost           string
+	DBName           string
+	DBUser           string
+	DBPassword       string
+	JWKSUrl          string
 	CacheEvictionUrl string
+	// Shared with the cache eviction endpoint to sign its calls, at least 32
+	// characters; eviction is skipped with a warning when it is unset
+	CacheEvictionSecret string
+	ServiceID           string // Who signed calls come from
+	// analytics-query-service base URL, e.g. http://analytics-query-service/api/analytics,
+	// for click stats in data exports and purging the clicks of deleted accounts.
+	// ANALYTICS_PURGE_URL, its earlier name, is still read when ANALYTICS_URL is unset.
+	AnalyticsUrl string
+	// Shared with analytics-query-service to sign purge calls, at least 32 characters
+	AnalyticsPurgeToken string
 }
 
 func LoadConfig() *Config {
 	return &Config{
-		Port:       getEnv("PORT", "8080"),
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBName:     getEnv("DB_NAME", "UrlShortenerDb"),
-		DBUser:     getEnv("DB_USER", "sa"),
-		DBPassword: getEnv("DB_PASSWORD", "yourStrong(!)Password"),
-		JWTSecret:        getEnv("JWT_SECRET", "super-secret-key"),
-		CacheEvictionUrl: getEnv("CACHE_EVICTION_URL", "https://us-func-p6ndmuotrzo5a.azurewebsites.net/api/cache"),
+		Port:                getEnv("PORT", "8080"),
+		DBHost:              getEnv("DB_HOST", "localhost"),
+		DBName:              getEnv("DB_NAME", "UrlShortenerDb"),
+		DBUser:              getEnv("DB_USER", "sa"),
+		DBPassword:          getEnv("DB_PASSWORD", "yourStrong(!)Password"),
+		JWKSUrl:             getEnv("AUTH_JWKS_URL", "http://auth-service/.well-known/jwks.json"),
+		CacheEvictionUrl:    getEnv("CACHE_EVICTION_URL", "https://us-func-p6ndmuotrzo5a.azurewebsites.net/api/cache"),
+		CacheEvictionSecret: getEnv("CACHE_EVICTION_SECRET", ""),
+		ServiceID:           getEnv("SERVICE_ID", "link-management-service"),
+		AnalyticsUrl:        getEnv("ANALYTICS_URL", getEnv("ANALYTICS_PURGE_URL", "")),
+		AnalyticsPurgeToken: getEnv("ANALYTICS_PURGE_TOKEN", ""),
 	}
 }
 
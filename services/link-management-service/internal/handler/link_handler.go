@@ -2,6 +2,7 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/models"
@@ -25,18 +26,20 @@ func (h *LinkHandler) CreateLink(c *gin.Context) {
 
 	// Get User Info from Context (set by AuthMiddleware)
 	var userID *int
-	role := "Guest"
 
 	if id, exists := c.Get("userID"); exists {
 		uid := id.(int)
 		userID = &uid
 	}
-	if r, exists := c.Get("role"); exists {
-		role = r.(string)
-	}
+	perms := models.Permissions(c.GetStringSlice("permissions"))
+	emailVerified := c.GetBool("emailVerified")
 
-	link, err := h.Service.CreateLink(&req, userID, role)
+	link, err := h.Service.CreateLink(&req, userID, perms, emailVerified)
 	if err != nil {
+		if err.Error() == "not a member of this organization" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot create links in this organization"})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -51,8 +54,24 @@ func (h *LinkHandler) GetMyLinks(c *gin.Context) {
 		return
 	}
 
-	links, err := h.Service.GetUserLinks(userID.(int))
+	// ?orgId=N lists an organization's links instead of the personal ones
+	var links []models.Link
+	var err error
+	if orgParam := c.Query("orgId"); orgParam != "" {
+		orgID, convErr := strconv.Atoi(orgParam)
+		if convErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid orgId"})
+			return
+		}
+		links, err = h.Service.GetOrgLinks(orgID, userID.(int))
+	} else {
+		links, err = h.Service.GetUserLinks(userID.(int))
+	}
 	if err != nil {
+		if err.Error() == "not a member of this organization" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this organization"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -62,19 +81,19 @@ func (h *LinkHandler) GetMyLinks(c *gin.Context) {
 
 func (h *LinkHandler) DeleteLink(c *gin.Context) {
 	shortCode := c.Param("code")
-	
+
 	userID, exists := c.Get("userID")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
-	
-	role := c.GetString("role")
 
-	err := h.Service.DeleteLink(shortCode, userID.(int), role)
+	perms := models.Permissions(c.GetStringSlice("permissions"))
+
+	err := h.Service.DeleteLink(shortCode, userID.(int), perms)
 	if err != nil {
 		if err.Error() == "unauthorized" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "You do not own this link"})
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this link"})
 			return
 		}
 		if err.Error() == "link not found" {
@@ -101,12 +120,12 @@ func (h *LinkHandler) UpdateLink(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
-	role := c.GetString("role")
+	perms := models.Permissions(c.GetStringSlice("permissions"))
 
-	link, err := h.Service.UpdateLink(shortCode, &req, userID.(int), role)
+	link, err := h.Service.UpdateLink(shortCode, &req, userID.(int), perms)
 	if err != nil {
 		if err.Error() == "unauthorized" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "You do not own this link"})
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this link"})
 			return
 		}
 		if err.Error() == "link not found" {
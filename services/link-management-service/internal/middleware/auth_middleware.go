@@ -1,32 +1,53 @@
 package middleware
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/config"
+	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/repository"
 )
 
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+// API keys issued by auth-service start with this prefix
+const apiKeyPrefix = "usk_"
+
+// AuthMiddleware identifies the caller and sets userID, role and permissions
+// in the gin context. Requests without a token are treated as the Guest role.
+// JWT callers also get sessionID, and actorID when an admin is impersonating
+// the user.
+func AuthMiddleware(jwks *JWKSCache, sessions *repository.SessionRepository, apiKeys *repository.ApiKeyRepository, roles *RoleCache) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			// No token -> Guest
 			c.Set("role", "Guest")
+			if !setRolePermissions(c, roles, "Guest") {
+				return
+			}
 			c.Next()
 			return
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		if strings.HasPrefix(tokenString, apiKeyPrefix) {
+			authenticateApiKey(c, apiKeys, roles, tokenString)
+			return
+		}
+
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			if _, ok := token.Method.(*jwt.SigningMethodEd25519); !ok {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
-			return []byte(cfg.JWTSecret), nil
-		})
+			kid, _ := token.Header["kid"].(string)
+			return jwks.Key(kid)
+		}, jwt.WithValidMethods([]string{"EdDSA"}))
 
 		if err != nil || !token.Valid {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
@@ -34,17 +55,138 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			// Extract UserID (sub) and Role
-			// JWT numbers are float64 by default
-			if sub, ok := claims["sub"].(float64); ok {
-				c.Set("userID", int(sub))
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+
+		// Reject tokens whose session was logged out or revoked
+		sid, _ := claims["sid"].(string)
+		if sid == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+		active, err := sessions.IsSessionActive(sid)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			c.Abort()
+			return
+		}
+		if !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session revoked"})
+			c.Abort()
+			return
+		}
+
+		// Extract UserID (sub) and Role
+		// JWT numbers are float64 by default
+		if sub, ok := claims["sub"].(float64); ok {
+			c.Set("userID", int(sub))
+		}
+		c.Set("sessionID", sid)
+		role, _ := claims["role"].(string)
+		c.Set("role", role)
+		if verified, ok := claims["email_verified"].(bool); ok {
+			c.Set("emailVerified", verified)
+		}
+		// RFC 8693 actor claim naming the admin acting as the user
+		if act, ok := claims["act"].(map[string]interface{}); ok {
+			if sub, ok := act["sub"].(float64); ok {
+				c.Set("actorID", int(sub))
 			}
-			if role, ok := claims["role"].(string); ok {
-				c.Set("role", role)
+		}
+		if list, ok := claims["perms"].([]interface{}); ok {
+			perms := []string{}
+			for _, p := range list {
+				if s, ok := p.(string); ok {
+					perms = append(perms, s)
+				}
 			}
+			c.Set("permissions", perms)
+		} else if !setRolePermissions(c, roles, role) {
+			// Tokens issued before permissions were added to the claims
+			return
 		}
 
 		c.Next()
 	}
 }
+
+// setRolePermissions looks up the permissions of role. On failure it aborts
+// the request and returns false.
+func setRolePermissions(c *gin.Context, roles *RoleCache, role string) bool {
+	perms, err := roles.Permissions(role)
+	if err != nil {
+		log.Printf("Failed to resolve permissions for role %s: %v", role, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		c.Abort()
+		return false
+	}
+	c.Set("permissions", perms)
+	return true
+}
+
+func authenticateApiKey(c *gin.Context, apiKeys *repository.ApiKeyRepository, roles *RoleCache, key string) {
+	sum := sha256.Sum256([]byte(key))
+	apiKey, err := apiKeys.GetApiKeyByHash(hex.EncodeToString(sum[:]))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		c.Abort()
+		return
+	}
+	if apiKey == nil || apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && time.Now().UTC().After(*apiKey.ExpiresAt)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		c.Abort()
+		return
+	}
+
+	go func() {
+		if err := apiKeys.TouchApiKey(apiKey.ID); err != nil {
+			log.Printf("Failed to update API key last use: %v", err)
+		}
+	}()
+
+	c.Set("userID", apiKey.UserID)
+	c.Set("role", apiKey.Role)
+	c.Set("emailVerified", apiKey.EmailVerified)
+	c.Set("scopes", apiKey.Scopes)
+	if !setRolePermissions(c, roles, apiKey.Role) {
+		return
+	}
+	c.Next()
+}
+
+// RequirePermission rejects callers whose role lacks perm.
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if models.Permissions(c.GetStringSlice("permissions")).Has(perm) {
+			c.Next()
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Missing permission " + perm})
+		c.Abort()
+	}
+}
+
+// RequireScope restricts a route for API key callers. JWT sessions and
+// guests are not scoped and pass through.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, exists := c.Get("scopes")
+		if !exists {
+			c.Next()
+			return
+		}
+		for _, s := range scopes.([]string) {
+			if s == scope {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key is missing scope " + scope})
+		c.Abort()
+	}
+}
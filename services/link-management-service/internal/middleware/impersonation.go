@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/repository"
+)
+
+// AuditImpersonation records every change made with an impersonation token,
+// whether or not it succeeded. It must run after AuthMiddleware.
+func AuditImpersonation(audit *repository.ImpersonationAuditRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actorID := c.GetInt("actorID")
+		method := c.Request.Method
+		if actorID == 0 || method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		c.Next()
+
+		entry := &models.ImpersonationAudit{
+			ActorID:   actorID,
+			UserID:    c.GetInt("userID"),
+			SessionID: c.GetString("sessionID"),
+			Method:    method,
+			Path:      c.Request.URL.Path,
+			Status:    c.Writer.Status(),
+		}
+		log.Printf("Admin %d acting as user %d: %s %s -> %d", entry.ActorID, entry.UserID, entry.Method, entry.Path, entry.Status)
+		if err := audit.Record(entry); err != nil {
+			log.Printf("Failed to record impersonation audit: %v", err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	jwksCacheTTL      = 10 * time.Minute
+	jwksMinRefetchGap = 30 * time.Second // Throttle refetches triggered by unknown kids
+)
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+}
+
+// JWKSCache fetches auth-service's public keys and caches them by kid.
+type JWKSCache struct {
+	URL    string
+	client *http.Client
+
+	fetching sync.Mutex // One fetch at a time, without blocking lookups
+
+	mu          sync.RWMutex
+	keys        map[string]ed25519.PublicKey
+	fetchedAt   time.Time // Last successful fetch
+	attemptedAt time.Time // Last fetch, successful or not
+	fetchErr    error     // Outcome of the last fetch
+}
+
+func NewJWKSCache(url string) *JWKSCache {
+	return &JWKSCache{
+		URL:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+		keys:   map[string]ed25519.PublicKey{},
+	}
+}
+
+// Key returns the public key for kid, refreshing the key set when it is stale
+// or when the kid is unknown (e.g. auth-service started signing with a new key).
+func (j *JWKSCache) Key(kid string) (ed25519.PublicKey, error) {
+	j.mu.RLock()
+	key, ok := j.keys[kid]
+	age := time.Since(j.fetchedAt)
+	sinceAttempt := time.Since(j.attemptedAt)
+	j.mu.RUnlock()
+
+	if ok && age < jwksCacheTTL {
+		return key, nil
+	}
+	if !ok && sinceAttempt < jwksMinRefetchGap {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	if err := j.refresh(); err != nil {
+		// Keep serving the cached key if auth-service is briefly unreachable
+		if ok {
+			log.Printf("Failed to refresh JWKS, using cached key: %v", err)
+			return key, nil
+		}
+		return nil, err
+	}
+
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// refresh fetches the key set unless that was tried less than
+// jwksMinRefetchGap ago, in which case it returns how that attempt went.
+// Failed attempts count too, so an unreachable auth-service is not asked
+// again on every request.
+func (j *JWKSCache) refresh() error {
+	j.fetching.Lock()
+	defer j.fetching.Unlock()
+
+	// Another request may have fetched while we waited for our turn
+	j.mu.RLock()
+	recent, lastErr := time.Since(j.attemptedAt) < jwksMinRefetchGap, j.fetchErr
+	j.mu.RUnlock()
+	if recent {
+		return lastErr
+	}
+
+	keys, err := j.fetch()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.attemptedAt = time.Now()
+	j.fetchErr = err
+	if err != nil {
+		return err
+	}
+	j.keys = keys
+	j.fetchedAt = j.attemptedAt
+	return nil
+}
+
+func (j *JWKSCache) fetch() (map[string]ed25519.PublicKey, error) {
+	resp, err := j.client.Get(j.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := map[string]ed25519.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "OKP" || k.Crv != "Ed25519" || k.Kid == "" {
+			continue
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			continue
+		}
+		keys[k.Kid] = ed25519.PublicKey(x)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS contains no usable keys")
+	}
+	return keys, nil
+}
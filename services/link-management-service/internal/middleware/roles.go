@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/repository"
+)
+
+const roleCacheTTL = time.Minute
+
+// RoleCache resolves a role to its permissions for callers without a token
+// that carries them: guests and API keys.
+type RoleCache struct {
+	Repo *repository.RoleRepository
+
+	mu        sync.RWMutex
+	perms     map[string][]string
+	fetchedAt time.Time
+}
+
+func NewRoleCache(repo *repository.RoleRepository) *RoleCache {
+	return &RoleCache{Repo: repo}
+}
+
+func (r *RoleCache) Permissions(role string) ([]string, error) {
+	r.mu.RLock()
+	perms, fresh := r.perms, time.Since(r.fetchedAt) < roleCacheTTL
+	r.mu.RUnlock()
+
+	if !fresh {
+		loaded, err := r.Repo.GetRolePermissions()
+		if err != nil {
+			// Keep serving the cached permissions if the database is briefly unreachable
+			if perms == nil {
+				return nil, err
+			}
+			log.Printf("Failed to refresh role permissions, using cached set: %v", err)
+		} else {
+			r.mu.Lock()
+			r.perms, r.fetchedAt = loaded, time.Now()
+			r.mu.Unlock()
+			perms = loaded
+		}
+	}
+
+	return perms[role], nil
+}
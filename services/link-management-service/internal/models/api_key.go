@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// ApiKey is a personal access token created through auth-service, resolved
+// together with its owner's role.
+type ApiKey struct {
+	ID            int
+	UserID        int
+	Role          string
+	EmailVerified bool
+	Scopes        []string
+	ExpiresAt     *time.Time
+	RevokedAt     *time.Time
+}
@@ -0,0 +1,18 @@
+package models
+
+// DataExport is a personal data export requested in auth-service that still
+// needs this service's part.
+type DataExport struct {
+	ID     string
+	UserID int
+}
+
+// ClickStats summarizes a link's clicks for its owner. Visitor IP addresses
+// are personal data of the visitors and are left out.
+type ClickStats struct {
+	ShortCode   string         `json:"shortCode"`
+	TotalClicks int            `json:"totalClicks"`
+	Browsers    map[string]int `json:"browsers,omitempty"`
+	OS          map[string]int `json:"os,omitempty"`
+	ByDay       map[string]int `json:"byDay,omitempty"` // UTC dates, YYYY-MM-DD
+}
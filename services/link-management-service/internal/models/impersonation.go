@@ -0,0 +1,11 @@
+package models
+
+// ImpersonationAudit records one change an admin made while acting as a user.
+type ImpersonationAudit struct {
+	ActorID   int
+	UserID    int
+	SessionID string
+	Method    string
+	Path      string
+	Status    int
+}
@@ -4,8 +4,9 @@ import "time"
 
 type Link struct {
 	ShortCode   string     `json:"shortCode"`
-	OriginalUrl string     `json:"originalUrl"` // Renamed from LongUrl
-	UserID      *int       `json:"userId,omitempty"`
+	OriginalUrl string     `json:"originalUrl"`      // Renamed from LongUrl
+	UserID      *int       `json:"userId,omitempty"` // Creator; for personal links also the owner
+	OrgID       *int       `json:"orgId,omitempty"`  // Set when the link belongs to an organization
 	CreatedAt   time.Time  `json:"createdAt"`
 	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
 	ClickCount  int        `json:"clickCount"`
@@ -16,6 +17,7 @@ type Link struct {
 type CreateLinkRequest struct {
 	OriginalUrl string `json:"originalUrl" binding:"required,url"` // Renamed from LongUrl
 	CustomAlias string `json:"customAlias"`
+	OrgID       *int   `json:"orgId"` // Create the link in this organization instead of the personal account
 }
 
 type UpdateLinkRequest struct {
@@ -0,0 +1,17 @@
+package models
+
+// Organization membership roles managed by auth-service, from least to most privileged
+const (
+	OrgRoleViewer = "viewer"
+	OrgRoleEditor = "editor"
+	OrgRoleAdmin  = "admin"
+	OrgRoleOwner  = "owner"
+)
+
+var orgRoleRank = map[string]int{OrgRoleViewer: 1, OrgRoleEditor: 2, OrgRoleAdmin: 3, OrgRoleOwner: 4}
+
+// OrgRoleAtLeast reports whether role grants everything min does. An empty
+// role (not a member) grants nothing.
+func OrgRoleAtLeast(role, min string) bool {
+	return orgRoleRank[role] >= orgRoleRank[min] && orgRoleRank[min] > 0
+}
@@ -0,0 +1,24 @@
+package models
+
+// Permissions granted to roles in auth-service's RolePermissions table that
+// link-management enforces.
+const (
+	PermLinksCreate       = "links:create"
+	PermLinksCustomAlias  = "links:custom_alias"
+	PermLinksPermanent    = "links:permanent"    // Links never expire; without it they last 24 hours
+	PermLinksUnrestricted = "links:unrestricted" // No quotas and no verified email needed for aliases
+	PermLinksUpdateAny    = "links:update_any"
+	PermLinksDeleteAny    = "links:delete_any"
+)
+
+// Permissions is the set the caller was granted through their role.
+type Permissions []string
+
+func (p Permissions) Has(perm string) bool {
+	for _, granted := range p {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// User event types published by auth-service to the UserEvents outbox
+const (
+	UserEventDeleted = "user.deleted"
+)
+
+type UserEvent struct {
+	ID        int64
+	Type      string
+	UserID    int
+	CreatedAt time.Time
+}
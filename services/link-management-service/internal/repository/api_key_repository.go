@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/models"
+)
+
+// ApiKeyRepository reads the ApiKeys table owned by auth-service.
+type ApiKeyRepository struct {
+	DB *sql.DB
+}
+
+func NewApiKeyRepository(db *sql.DB) *ApiKeyRepository {
+	return &ApiKeyRepository{DB: db}
+}
+
+// GetApiKeyByHash ignores keys that belong to disabled users.
+func (r *ApiKeyRepository) GetApiKeyByHash(hash string) (*models.ApiKey, error) {
+	query := `
+		SELECT k.ID, k.UserID, u.Role, u.EmailVerified, k.Scopes, k.ExpiresAt, k.RevokedAt
+		FROM ApiKeys k
+		JOIN Users u ON u.ID = k.UserID
+		WHERE k.KeyHash = @p1 AND u.DisabledAt IS NULL
+	`
+	var k models.ApiKey
+	var scopes string
+	err := r.DB.QueryRow(query, hash).Scan(&k.ID, &k.UserID, &k.Role, &k.EmailVerified, &scopes, &k.ExpiresAt, &k.RevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	k.Scopes = strings.Split(scopes, ",")
+	return &k, nil
+}
+
+func (r *ApiKeyRepository) TouchApiKey(id int) error {
+	_, err := r.DB.Exec("UPDATE ApiKeys SET LastUsedAt = GETUTCDATE() WHERE ID = @p1", id)
+	return err
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/models"
+)
+
+// ExportRepository reads the DataExports jobs owned by auth-service and
+// stores this service's part of each in LinkExports.
+type ExportRepository struct {
+	DB *sql.DB
+}
+
+func NewExportRepository(db *sql.DB) *ExportRepository {
+	return &ExportRepository{DB: db}
+}
+
+// ListPendingExports returns pending exports that have no link part yet.
+func (r *ExportRepository) ListPendingExports() ([]models.DataExport, error) {
+	query := `
+		SELECT d.ID, d.UserID FROM DataExports d
+		WHERE d.Status = 'pending' AND NOT EXISTS (SELECT 1 FROM LinkExports l WHERE l.ExportID = d.ID)
+		ORDER BY d.CreatedAt
+	`
+	rows, err := r.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending exports: %w", err)
+	}
+	defer rows.Close()
+
+	var exports []models.DataExport
+	for rows.Next() {
+		var e models.DataExport
+		if err := rows.Scan(&e.ID, &e.UserID); err != nil {
+			return nil, err
+		}
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
+
+// SaveLinkExport stores the part once; a replica that built it at the same
+// time leaves the first copy in place.
+func (r *ExportRepository) SaveLinkExport(exportID string, userID int, files string) error {
+	query := `
+		INSERT INTO LinkExports (ExportID, UserID, Files)
+		SELECT @p1, @p2, @p3
+		WHERE NOT EXISTS (SELECT 1 FROM LinkExports WHERE ExportID = @p1)
+	`
+	if _, err := r.DB.Exec(query, exportID, userID, files); err != nil {
+		return fmt.Errorf("failed to save link export: %w", err)
+	}
+	return nil
+}
+
+// DeleteFinishedLinkExports drops parts auth-service no longer needs: those
+// of exports that are finished, failed or gone.
+func (r *ExportRepository) DeleteFinishedLinkExports() error {
+	query := `
+		DELETE l FROM LinkExports l
+		WHERE NOT EXISTS (SELECT 1 FROM DataExports d WHERE d.ID = l.ExportID AND d.Status = 'pending')
+	`
+	if _, err := r.DB.Exec(query); err != nil {
+		return fmt.Errorf("failed to delete finished link exports: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/models"
+)
+
+type ImpersonationAuditRepository struct {
+	DB *sql.DB
+}
+
+func NewImpersonationAuditRepository(db *sql.DB) *ImpersonationAuditRepository {
+	return &ImpersonationAuditRepository{DB: db}
+}
+
+func (r *ImpersonationAuditRepository) Record(e *models.ImpersonationAudit) error {
+	query := `
+		INSERT INTO ImpersonationAudit (ActorID, UserID, SessionID, Method, Path, Status)
+		VALUES (@p1, @p2, @p3, @p4, @p5, @p6)
+	`
+	if _, err := r.DB.Exec(query, e.ActorID, e.UserID, e.SessionID, e.Method, e.Path, e.Status); err != nil {
+		return fmt.Errorf("failed to record impersonation audit: %w", err)
+	}
+	return nil
+}
@@ -17,23 +17,37 @@ func NewLinkRepository(db *sql.DB) *LinkRepository {
 
 func (r *LinkRepository) CreateLink(link *models.Link) error {
 	query := `
-		INSERT INTO Links (ShortCode, OriginalUrl, UserID, CreatedAt, ExpiresAt, ClickCount, CustomAlias, IsActive)
-		VALUES (@p1, @p2, @p3, @p4, @p5, 0, @p6, @p7)
+		INSERT INTO Links (ShortCode, OriginalUrl, UserID, OrgID, CreatedAt, ExpiresAt, ClickCount, CustomAlias, IsActive)
+		VALUES (@p1, @p2, @p3, @p4, @p5, @p6, 0, @p7, @p8)
 	`
-	_, err := r.DB.Exec(query, link.ShortCode, link.OriginalUrl, link.UserID, link.CreatedAt, link.ExpiresAt, link.CustomAlias, link.IsActive)
+	_, err := r.DB.Exec(query, link.ShortCode, link.OriginalUrl, link.UserID, link.OrgID, link.CreatedAt, link.ExpiresAt, link.CustomAlias, link.IsActive)
 	if err != nil {
 		return fmt.Errorf("failed to create link: %w", err)
 	}
 	return nil
 }
 
+// GetLinksByUserID returns the user's personal links; links they created in an
+// organization are listed with the organization.
 func (r *LinkRepository) GetLinksByUserID(userID int) ([]models.Link, error) {
+	return r.queryLinks("WHERE UserID = @p1 AND OrgID IS NULL", userID)
+}
+
+// GetLinksCreatedBy returns every link the user created, personal or in an organization.
+func (r *LinkRepository) GetLinksCreatedBy(userID int) ([]models.Link, error) {
+	return r.queryLinks("WHERE UserID = @p1", userID)
+}
+
+func (r *LinkRepository) GetLinksByOrgID(orgID int) ([]models.Link, error) {
+	return r.queryLinks("WHERE OrgID = @p1", orgID)
+}
+
+func (r *LinkRepository) queryLinks(where string, arg interface{}) ([]models.Link, error) {
 	query := `
-		SELECT ShortCode, OriginalUrl, UserID, CreatedAt, ExpiresAt, ClickCount, CustomAlias, IsActive
+		SELECT ShortCode, OriginalUrl, UserID, OrgID, CreatedAt, ExpiresAt, ClickCount, CustomAlias, IsActive
 		FROM Links
-		WHERE UserID = @p1
-	`
-	rows, err := r.DB.Query(query, userID)
+		` + where
+	rows, err := r.DB.Query(query, arg)
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +56,7 @@ func (r *LinkRepository) GetLinksByUserID(userID int) ([]models.Link, error) {
 	var links []models.Link
 	for rows.Next() {
 		var l models.Link
-		if err := rows.Scan(&l.ShortCode, &l.OriginalUrl, &l.UserID, &l.CreatedAt, &l.ExpiresAt, &l.ClickCount, &l.CustomAlias, &l.IsActive); err != nil {
+		if err := rows.Scan(&l.ShortCode, &l.OriginalUrl, &l.UserID, &l.OrgID, &l.CreatedAt, &l.ExpiresAt, &l.ClickCount, &l.CustomAlias, &l.IsActive); err != nil {
 			return nil, err
 		}
 		links = append(links, l)
@@ -50,8 +64,9 @@ func (r *LinkRepository) GetLinksByUserID(userID int) ([]models.Link, error) {
 	return links, nil
 }
 
+// CountLinksByUserID counts the user's personal links, like GetLinksByUserID.
 func (r *LinkRepository) CountLinksByUserID(userID int) (int, error) {
-	query := "SELECT COUNT(*) FROM Links WHERE UserID = @p1"
+	query := "SELECT COUNT(*) FROM Links WHERE UserID = @p1 AND OrgID IS NULL"
 	var count int
 	err := r.DB.QueryRow(query, userID).Scan(&count)
 	if err != nil {
@@ -62,12 +77,12 @@ func (r *LinkRepository) CountLinksByUserID(userID int) (int, error) {
 
 func (r *LinkRepository) GetLinkByShortCode(code string) (*models.Link, error) {
 	query := `
-		SELECT ShortCode, OriginalUrl, UserID, CreatedAt, ExpiresAt, ClickCount, CustomAlias, IsActive
+		SELECT ShortCode, OriginalUrl, UserID, OrgID, CreatedAt, ExpiresAt, ClickCount, CustomAlias, IsActive
 		FROM Links
 		WHERE ShortCode = @p1
 	`
 	var l models.Link
-	err := r.DB.QueryRow(query, code).Scan(&l.ShortCode, &l.OriginalUrl, &l.UserID, &l.CreatedAt, &l.ExpiresAt, &l.ClickCount, &l.CustomAlias, &l.IsActive)
+	err := r.DB.QueryRow(query, code).Scan(&l.ShortCode, &l.OriginalUrl, &l.UserID, &l.OrgID, &l.CreatedAt, &l.ExpiresAt, &l.ClickCount, &l.CustomAlias, &l.IsActive)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -83,8 +98,19 @@ func (r *LinkRepository) DeleteLink(code string) error {
 	return err
 }
 
+// ClearUserID detaches the remaining (organization) links of a deleted user,
+// which keep working for the organization.
+func (r *LinkRepository) ClearUserID(userID int) error {
+	query := "UPDATE Links SET UserID = NULL WHERE UserID = @p1"
+	if _, err := r.DB.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to anonymize links: %w", err)
+	}
+	return nil
+}
+
+// CountCustomLinksByUserID counts the user's personal links with a custom alias.
 func (r *LinkRepository) CountCustomLinksByUserID(userID int) (int, error) {
-	query := "SELECT COUNT(*) FROM Links WHERE UserID = @p1 AND CustomAlias IS NOT NULL AND CustomAlias <> ''"
+	query := "SELECT COUNT(*) FROM Links WHERE UserID = @p1 AND OrgID IS NULL AND CustomAlias IS NOT NULL AND CustomAlias <> ''"
 	var count int
 	err := r.DB.QueryRow(query, userID).Scan(&count)
 	if err != nil {
@@ -93,8 +119,9 @@ func (r *LinkRepository) CountCustomLinksByUserID(userID int) (int, error) {
 	return count, nil
 }
 
+// CountStandardLinksByUserID counts the user's personal links without a custom alias.
 func (r *LinkRepository) CountStandardLinksByUserID(userID int) (int, error) {
-	query := "SELECT COUNT(*) FROM Links WHERE UserID = @p1 AND (CustomAlias IS NULL OR CustomAlias = '')"
+	query := "SELECT COUNT(*) FROM Links WHERE UserID = @p1 AND OrgID IS NULL AND (CustomAlias IS NULL OR CustomAlias = '')"
 	var count int
 	err := r.DB.QueryRow(query, userID).Scan(&count)
 	if err != nil {
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// OrgRepository reads organization memberships owned by auth-service.
+type OrgRepository struct {
+	DB *sql.DB
+}
+
+func NewOrgRepository(db *sql.DB) *OrgRepository {
+	return &OrgRepository{DB: db}
+}
+
+// GetMemberRole returns the user's role in the organization, or "" if they are not a member.
+func (r *OrgRepository) GetMemberRole(orgID, userID int) (string, error) {
+	var role string
+	err := r.DB.QueryRow("SELECT Role FROM OrgMembers WHERE OrgID = @p1 AND UserID = @p2", orgID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get organization membership: %w", err)
+	}
+	return role, nil
+}
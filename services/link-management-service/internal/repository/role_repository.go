@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// RoleRepository reads the RBAC tables owned by auth-service.
+type RoleRepository struct {
+	DB *sql.DB
+}
+
+func NewRoleRepository(db *sql.DB) *RoleRepository {
+	return &RoleRepository{DB: db}
+}
+
+// GetRolePermissions returns the permissions of every role, keyed by role name.
+func (r *RoleRepository) GetRolePermissions() (map[string][]string, error) {
+	rows, err := r.DB.Query("SELECT RoleName, PermissionName FROM RolePermissions")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role permissions: %w", err)
+	}
+	defer rows.Close()
+
+	perms := map[string][]string{}
+	for rows.Next() {
+		var role, perm string
+		if err := rows.Scan(&role, &perm); err != nil {
+			return nil, err
+		}
+		perms[role] = append(perms[role], perm)
+	}
+	return perms, rows.Err()
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// SessionRepository reads the Sessions table owned by auth-service so that
+// revoked logins are rejected before their access tokens expire.
+type SessionRepository struct {
+	DB *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{DB: db}
+}
+
+// IsSessionActive is false for revoked sessions and for sessions of disabled users.
+func (r *SessionRepository) IsSessionActive(sessionID string) (bool, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM Sessions s
+		JOIN Users u ON u.ID = s.UserID
+		WHERE s.ID = @p1 AND s.RevokedAt IS NULL AND u.DisabledAt IS NULL
+	`
+	var count int
+	if err := r.DB.QueryRow(query, sessionID).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	return count > 0, nil
+}
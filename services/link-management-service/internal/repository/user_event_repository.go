@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/models"
+)
+
+// UserEventRepository reads the UserEvents outbox owned by auth-service and
+// keeps track of the events this service has handled.
+type UserEventRepository struct {
+	DB *sql.DB
+}
+
+func NewUserEventRepository(db *sql.DB) *UserEventRepository {
+	return &UserEventRepository{DB: db}
+}
+
+// ListPendingEvents returns up to limit events with an ID above afterID that
+// the consumer has not handled yet, oldest first.
+func (r *UserEventRepository) ListPendingEvents(consumer string, afterID int64, limit int) ([]models.UserEvent, error) {
+	query := `
+		SELECT TOP (@p3) e.ID, e.EventType, e.UserID, e.CreatedAt
+		FROM UserEvents e
+		WHERE e.ID > @p2
+			AND NOT EXISTS (SELECT 1 FROM ProcessedUserEvents p WHERE p.Consumer = @p1 AND p.EventID = e.ID)
+		ORDER BY e.ID
+	`
+	rows, err := r.DB.Query(query, consumer, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.UserEvent
+	for rows.Next() {
+		var e models.UserEvent
+		if err := rows.Scan(&e.ID, &e.Type, &e.UserID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// MarkProcessed records that the consumer handled an event. Replicas may
+// both handle one; the second mark is a no-op.
+func (r *UserEventRepository) MarkProcessed(consumer string, eventID int64) error {
+	query := `
+		INSERT INTO ProcessedUserEvents (Consumer, EventID)
+		SELECT @p1, @p2
+		WHERE NOT EXISTS (SELECT 1 FROM ProcessedUserEvents WITH (UPDLOCK, HOLDLOCK) WHERE Consumer = @p1 AND EventID = @p2)
+	`
+	if _, err := r.DB.Exec(query, consumer, eventID); err != nil {
+		return fmt.Errorf("failed to mark user event processed: %w", err)
+	}
+	return nil
+}
+
+// GetCursor returns the ID up to which the consumer has handled every event,
+// or 0 if it has not started.
+func (r *UserEventRepository) GetCursor(consumer string) (int64, error) {
+	var id int64
+	err := r.DB.QueryRow("SELECT LastEventID FROM UserEventCursors WHERE Consumer = @p1", consumer).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read event cursor: %w", err)
+	}
+	return id, nil
+}
+
+// AdvanceCursor moves the cursor from `from` past the events that are handled
+// and older than settle, up to the first one that is not handled, and forgets
+// the processed marks it no longer needs. IDs are assigned before commit, so
+// an event can show up after one with a higher ID; settle must be longer than
+// auth-service takes to commit one. If another replica moved the cursor
+// first, nothing changes.
+func (r *UserEventRepository) AdvanceCursor(consumer string, from int64, settle time.Duration) error {
+	query := `
+		DECLARE @pending BIGINT = (
+			SELECT MIN(e.ID) FROM UserEvents e
+			WHERE e.ID > @p2
+				AND NOT EXISTS (SELECT 1 FROM ProcessedUserEvents p WHERE p.Consumer = @p1 AND p.EventID = e.ID)
+		);
+		DECLARE @to BIGINT = (
+			SELECT MAX(ID) FROM UserEvents
+			WHERE ID > @p2 AND (@pending IS NULL OR ID < @pending)
+				AND CreatedAt < DATEADD(SECOND, -@p3, GETUTCDATE())
+		);
+		IF @to IS NOT NULL
+		BEGIN
+			MERGE UserEventCursors AS t
+			USING (SELECT @p1 AS Consumer) AS s ON t.Consumer = s.Consumer
+			WHEN MATCHED AND t.LastEventID = @p2 THEN
+				UPDATE SET LastEventID = @to, UpdatedAt = GETUTCDATE()
+			WHEN NOT MATCHED THEN
+				INSERT (Consumer, LastEventID) VALUES (@p1, @to);
+			IF @@ROWCOUNT = 1
+				DELETE FROM ProcessedUserEvents WHERE Consumer = @p1 AND EventID <= @to;
+		END
+	`
+	if _, err := r.DB.Exec(query, consumer, from, int(settle.Seconds())); err != nil {
+		return fmt.Errorf("failed to advance event cursor: %w", err)
+	}
+	return nil
+}
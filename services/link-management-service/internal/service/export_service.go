@@ -0,0 +1,147 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/repository"
+)
+
+// ExportService contributes the user's links and click stats to the personal
+// data exports auth-service assembles.
+type ExportService struct {
+	Repo  *repository.ExportRepository
+	Links *LinkService
+}
+
+func NewExportService(repo *repository.ExportRepository, links *LinkService) *ExportService {
+	return &ExportService{Repo: repo, Links: links}
+}
+
+// Run checks for new export jobs every interval.
+func (s *ExportService) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.Poll(); err != nil {
+			log.Printf("Failed to process data exports: %v", err)
+		}
+	}
+}
+
+// Poll builds the link part of every pending export. A failed export is
+// logged and retried on the next poll.
+func (s *ExportService) Poll() error {
+	if err := s.Repo.DeleteFinishedLinkExports(); err != nil {
+		return err
+	}
+	exports, err := s.Repo.ListPendingExports()
+	if err != nil {
+		return err
+	}
+	for _, e := range exports {
+		files, err := s.buildFiles(e.UserID)
+		if err != nil {
+			log.Printf("Failed to export links of user %d: %v", e.UserID, err)
+			continue
+		}
+		if err := s.Repo.SaveLinkExport(e.ID, e.UserID, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildFiles returns the JSON object of file name to contents that goes into
+// the archive: every link the user created and its click stats.
+func (s *ExportService) buildFiles(userID int) (string, error) {
+	links, err := s.Links.Repo.GetLinksCreatedBy(userID)
+	if err != nil {
+		return "", err
+	}
+	if links == nil {
+		links = []models.Link{}
+	}
+	stats := []models.ClickStats{}
+	for i := range links {
+		st, err := s.Links.ClickStats(&links[i])
+		if err != nil {
+			return "", err
+		}
+		stats = append(stats, *st)
+	}
+
+	files := map[string]string{}
+	if files["links.json"], err = jsonFile(links); err != nil {
+		return "", err
+	}
+	if files["click_stats.json"], err = jsonFile(stats); err != nil {
+		return "", err
+	}
+
+	linkRows := [][]string{{"short_code", "original_url", "org_id", "created_at", "expires_at", "click_count", "custom_alias", "is_active"}}
+	for _, l := range links {
+		linkRows = append(linkRows, []string{l.ShortCode, l.OriginalUrl, optionalInt(l.OrgID), l.CreatedAt.UTC().Format(time.RFC3339),
+			optionalTime(l.ExpiresAt), strconv.Itoa(l.ClickCount), l.CustomAlias, strconv.FormatBool(l.IsActive)})
+	}
+	dayRows := [][]string{{"short_code", "date", "clicks"}}
+	for _, st := range stats {
+		days := make([]string, 0, len(st.ByDay))
+		for day := range st.ByDay {
+			days = append(days, day)
+		}
+		sort.Strings(days)
+		for _, day := range days {
+			dayRows = append(dayRows, []string{st.ShortCode, day, strconv.Itoa(st.ByDay[day])})
+		}
+	}
+	if files["links.csv"], err = csvFile(linkRows); err != nil {
+		return "", err
+	}
+	if files["clicks_by_day.csv"], err = csvFile(dayRows); err != nil {
+		return "", err
+	}
+
+	out, err := json.Marshal(files)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func jsonFile(v interface{}) (string, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func csvFile(rows [][]string) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll(rows); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func optionalInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func optionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
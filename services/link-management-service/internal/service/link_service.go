@@ -1,39 +1,49 @@
 package service
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/models"
 	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/repository"
+	"github.com/shinshark/azure-url-shortener/services/pkg/signing"
 	"github.com/teris-io/shortid"
 )
 
 type LinkService struct {
 	Repo             *repository.LinkRepository
+	Orgs             *repository.OrgRepository
 	CacheEvictionUrl string
+	CacheEviction    *signing.Client // Signs eviction calls; nil disables eviction
+	AnalyticsUrl     string          // analytics-query-service; GET {url}/{code} returns click stats
+	AnalyticsPurge   *signing.Client // Signs DELETE {url}/{code}, which rejects unsigned calls
 }
 
-func NewLinkService(repo *repository.LinkRepository, cacheEvictionUrl string) *LinkService {
+func NewLinkService(repo *repository.LinkRepository, orgs *repository.OrgRepository, cacheEvictionUrl string, cacheEviction *signing.Client, analyticsUrl string, analyticsPurge *signing.Client) *LinkService {
 	return &LinkService{
 		Repo:             repo,
+		Orgs:             orgs,
 		CacheEvictionUrl: cacheEvictionUrl,
+		CacheEviction:    cacheEviction,
+		AnalyticsUrl:     analyticsUrl,
+		AnalyticsPurge:   analyticsPurge,
 	}
 }
 
 func (s *LinkService) evictCache(shortCode string) {
-	if s.CacheEvictionUrl == "" {
+	if s.CacheEvictionUrl == "" || s.CacheEviction == nil {
 		return
 	}
-	client := &http.Client{Timeout: 5 * time.Second}
 	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/%s", s.CacheEvictionUrl, shortCode), nil)
 	if err != nil {
 		fmt.Printf("Failed to create cache eviction request: %v\n", err)
 		return
 	}
-	resp, err := client.Do(req)
+	resp, err := s.CacheEviction.Do(req)
 	if err != nil {
 		fmt.Printf("Failed to evict cache: %v\n", err)
 		return
@@ -44,10 +54,72 @@ func (s *LinkService) evictCache(shortCode string) {
 	}
 }
 
-func (s *LinkService) CreateLink(req *models.CreateLinkRequest, userID *int, role string) (*models.Link, error) {
-	// ... (existing code) ...
-	// 1. Quota Check for Users
-	if role == "User" && userID != nil {
+// purgeAnalytics deletes the click data analytics-query-service keeps for the
+// link. Unlike cache eviction it must succeed, or the data would outlive it.
+func (s *LinkService) purgeAnalytics(shortCode string) error {
+	if s.AnalyticsUrl == "" {
+		log.Printf("Click data of %s not purged: ANALYTICS_URL is not set", shortCode)
+		return nil
+	}
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/%s", s.AnalyticsUrl, shortCode), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.AnalyticsPurge.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to purge analytics of %s: %w", shortCode, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("analytics purge of %s failed with status %d", shortCode, resp.StatusCode)
+	}
+	return nil
+}
+
+// analyticsResponse is what analytics-query-service returns for a link.
+type analyticsResponse struct {
+	TotalClicks int            `json:"totalClicks"`
+	Browsers    map[string]int `json:"browsers"`
+	OS          map[string]int `json:"os"`
+	Timeline    []string       `json:"timeline"` // RFC 3339 time of each click
+}
+
+// ClickStats aggregates the clicks of a link. Without an analytics service
+// only the click counter kept with the link is known.
+func (s *LinkService) ClickStats(link *models.Link) (*models.ClickStats, error) {
+	stats := &models.ClickStats{ShortCode: link.ShortCode, TotalClicks: link.ClickCount}
+	if s.AnalyticsUrl == "" {
+		return stats, nil
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("%s/%s", s.AnalyticsUrl, link.ShortCode))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch analytics of %s: %w", link.ShortCode, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("analytics of %s failed with status %d", link.ShortCode, resp.StatusCode)
+	}
+	var data analyticsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("invalid analytics of %s: %w", link.ShortCode, err)
+	}
+
+	stats.TotalClicks = data.TotalClicks
+	stats.Browsers = data.Browsers
+	stats.OS = data.OS
+	stats.ByDay = map[string]int{}
+	for _, ts := range data.Timeline {
+		if t, err := time.Parse(time.RFC3339, ts); err == nil {
+			stats.ByDay[t.UTC().Format("2006-01-02")]++
+		}
+	}
+	return stats, nil
+}
+
+func (s *LinkService) CreateLink(req *models.CreateLinkRequest, userID *int, perms models.Permissions, emailVerified bool) (*models.Link, error) {
+	// 1. Quota Check for Users; organization links do not count against it
+	if userID != nil && req.OrgID == nil && !perms.Has(models.PermLinksUnrestricted) {
 		if req.CustomAlias != "" {
 			// Custom Link Quota
 			count, err := s.Repo.CountCustomLinksByUserID(*userID)
@@ -69,12 +141,29 @@ func (s *LinkService) CreateLink(req *models.CreateLinkRequest, userID *int, rol
 		}
 	}
 
+	// Organization links need an editor (or higher) membership
+	if req.OrgID != nil {
+		if userID == nil {
+			return nil, errors.New("not a member of this organization")
+		}
+		ok, err := s.hasOrgRole(*req.OrgID, *userID, models.OrgRoleEditor)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.New("not a member of this organization")
+		}
+	}
+
 	// 2. Generate Short Code
 	var shortCode string
 	if req.CustomAlias != "" {
-		if role != "User" && role != "Admin" {
+		if !perms.Has(models.PermLinksCustomAlias) {
 			return nil, errors.New("custom alias is only for registered users")
 		}
+		if !emailVerified && !perms.Has(models.PermLinksUnrestricted) {
+			return nil, errors.New("verify your email address to use custom aliases")
+		}
 		// Check if alias exists
 		existing, err := s.Repo.GetLinkByShortCode(req.CustomAlias)
 		if err != nil {
@@ -94,16 +183,17 @@ func (s *LinkService) CreateLink(req *models.CreateLinkRequest, userID *int, rol
 
 	// 3. Set Expiry
 	var expiresAt *time.Time
-	if role == "Guest" {
-		t := time.Now().Add(24 * time.Hour) // 24 hours for guests
+	if !perms.Has(models.PermLinksPermanent) {
+		t := time.Now().Add(24 * time.Hour) // 24 hours, e.g. for guests
 		expiresAt = &t
 	}
-	// Users have no expiry by default (nil)
+	// Registered users have no expiry by default (nil)
 
 	link := &models.Link{
 		ShortCode:   shortCode,
 		OriginalUrl: req.OriginalUrl,
 		UserID:      userID,
+		OrgID:       req.OrgID,
 		CreatedAt:   time.Now(),
 		ExpiresAt:   expiresAt,
 		CustomAlias: req.CustomAlias,
@@ -121,7 +211,40 @@ func (s *LinkService) GetUserLinks(userID int) ([]models.Link, error) {
 	return s.Repo.GetLinksByUserID(userID)
 }
 
-func (s *LinkService) UpdateLink(shortCode string, req *models.UpdateLinkRequest, userID int, role string) (*models.Link, error) {
+// GetOrgLinks lists an organization's links for any of its members.
+func (s *LinkService) GetOrgLinks(orgID, userID int) ([]models.Link, error) {
+	ok, err := s.hasOrgRole(orgID, userID, models.OrgRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("not a member of this organization")
+	}
+	return s.Repo.GetLinksByOrgID(orgID)
+}
+
+// canModify decides whether the caller may change a link: through the
+// anyPerm permission, as an editor of the owning organization, or as the
+// owner of a personal link.
+func (s *LinkService) canModify(link *models.Link, userID int, perms models.Permissions, anyPerm string) (bool, error) {
+	if perms.Has(anyPerm) {
+		return true, nil
+	}
+	if link.OrgID != nil {
+		return s.hasOrgRole(*link.OrgID, userID, models.OrgRoleEditor)
+	}
+	return link.UserID != nil && *link.UserID == userID, nil
+}
+
+func (s *LinkService) hasOrgRole(orgID, userID int, min string) (bool, error) {
+	role, err := s.Orgs.GetMemberRole(orgID, userID)
+	if err != nil {
+		return false, err
+	}
+	return models.OrgRoleAtLeast(role, min), nil
+}
+
+func (s *LinkService) UpdateLink(shortCode string, req *models.UpdateLinkRequest, userID int, perms models.Permissions) (*models.Link, error) {
 	link, err := s.Repo.GetLinkByShortCode(shortCode)
 	if err != nil {
 		return nil, err
@@ -131,10 +254,12 @@ func (s *LinkService) UpdateLink(shortCode string, req *models.UpdateLinkRequest
 	}
 
 	// Authorization Check
-	if role != "Admin" {
-		if link.UserID == nil || *link.UserID != userID {
-			return nil, errors.New("unauthorized")
-		}
+	allowed, err := s.canModify(link, userID, perms, models.PermLinksUpdateAny)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, errors.New("unauthorized")
 	}
 
 	// Validation: Only custom alias links can be edited
@@ -153,7 +278,7 @@ func (s *LinkService) UpdateLink(shortCode string, req *models.UpdateLinkRequest
 	return link, nil
 }
 
-func (s *LinkService) DeleteLink(shortCode string, userID int, role string) error {
+func (s *LinkService) DeleteLink(shortCode string, userID int, perms models.Permissions) error {
 	link, err := s.Repo.GetLinkByShortCode(shortCode)
 	if err != nil {
 		return err
@@ -163,10 +288,12 @@ func (s *LinkService) DeleteLink(shortCode string, userID int, role string) erro
 	}
 
 	// Authorization Check
-	if role != "Admin" {
-		if link.UserID == nil || *link.UserID != userID {
-			return errors.New("unauthorized")
-		}
+	allowed, err := s.canModify(link, userID, perms, models.PermLinksDeleteAny)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return errors.New("unauthorized")
 	}
 
 	if err := s.Repo.DeleteLink(shortCode); err != nil {
@@ -178,3 +305,28 @@ func (s *LinkService) DeleteLink(shortCode string, userID int, role string) erro
 
 	return nil
 }
+
+// DeleteUserLinks handles the deletion of an account. Personal links are
+// deleted together with their click data and cached redirects; organization
+// links belong to the organization and only lose their creator. Running it
+// again for the same user is harmless.
+func (s *LinkService) DeleteUserLinks(userID int) error {
+	links, err := s.Repo.GetLinksByUserID(userID)
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		if err := s.purgeAnalytics(link.ShortCode); err != nil {
+			return err
+		}
+		if err := s.Repo.DeleteLink(link.ShortCode); err != nil {
+			return err
+		}
+		go s.evictCache(link.ShortCode)
+	}
+	if err := s.Repo.ClearUserID(userID); err != nil {
+		return err
+	}
+	log.Printf("Deleted %d links of deleted user %d", len(links), userID)
+	return nil
+}
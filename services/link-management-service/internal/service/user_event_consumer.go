@@ -0,0 +1,77 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/models"
+	"github.com/shinshark/azure-url-shortener/services/link-management-service/internal/repository"
+)
+
+const userEventBatchSize = 100
+
+// How long the cursor stays behind an event. auth-service publishes events in
+// short transactions, so by then every event with a lower ID has committed.
+const userEventSettle = 10 * time.Minute
+
+// UserEventConsumer follows the account events auth-service publishes,
+// currently to clean up after deleted users.
+type UserEventConsumer struct {
+	Events *repository.UserEventRepository
+	Links  *LinkService
+	Name   string // Consumer name, shared by all replicas
+}
+
+func NewUserEventConsumer(events *repository.UserEventRepository, links *LinkService) *UserEventConsumer {
+	return &UserEventConsumer{Events: events, Links: links, Name: "link-management"}
+}
+
+// Run polls for new events every interval.
+func (c *UserEventConsumer) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := c.Poll(); err != nil {
+			log.Printf("Failed to process user events: %v", err)
+		}
+	}
+}
+
+// Poll handles every event that is not marked processed. Events behind the
+// cursor are all handled; the ones after it are checked one by one, so an
+// event that commits after a later one is still picked up. A failure is
+// retried on the next poll and later events wait behind it. Handlers are
+// idempotent, since replicas may handle the same event.
+func (c *UserEventConsumer) Poll() error {
+	cursor, err := c.Events.GetCursor(c.Name)
+	if err != nil {
+		return err
+	}
+	for {
+		events, err := c.Events.ListPendingEvents(c.Name, cursor, userEventBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if err := c.handle(&e); err != nil {
+				return err
+			}
+			if err := c.Events.MarkProcessed(c.Name, e.ID); err != nil {
+				return err
+			}
+		}
+		if len(events) < userEventBatchSize {
+			break
+		}
+	}
+	return c.Events.AdvanceCursor(c.Name, cursor, userEventSettle)
+}
+
+func (c *UserEventConsumer) handle(e *models.UserEvent) error {
+	switch e.Type {
+	case models.UserEventDeleted:
+		return c.Links.DeleteUserLinks(e.UserID)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,71 @@
+package signing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type serviceIDKey struct{}
+
+// Middleware passes requests signed by a known service on to next, with the
+// service id available through ServiceID. Other requests are answered with
+// 401 and a JSON error.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serviceID, status, err := v.check(w, r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), serviceIDKey{}, serviceID)))
+	})
+}
+
+// ServiceID returns the service that signed the request handled by Middleware.
+func ServiceID(ctx context.Context) (string, bool) {
+	serviceID, ok := ctx.Value(serviceIDKey{}).(string)
+	return serviceID, ok
+}
+
+// Gin is Middleware for gin routes. It exposes the service id as serviceID
+// in the gin context.
+func (v *Verifier) Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serviceID, status, err := v.check(c.Writer, c.Request)
+		if err != nil {
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set("serviceID", serviceID)
+		c.Next()
+	}
+}
+
+// check verifies r with its body limited to MaxBodySize. On failure it
+// returns the status to answer with and an error safe to show the caller.
+func (v *Verifier) check(w http.ResponseWriter, r *http.Request) (string, int, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxBodySize)
+	serviceID, err := v.Verify(r)
+	if err == nil {
+		return serviceID, http.StatusOK, nil
+	}
+
+	var rejected *RejectedError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &rejected):
+		return "", http.StatusUnauthorized, rejected
+	case errors.As(err, &tooLarge):
+		return "", http.StatusRequestEntityTooLarge, errors.New("request body too large")
+	default:
+		log.Printf("Failed to verify request signature: %v", err)
+		return "", http.StatusInternalServerError, errors.New("internal server error")
+	}
+}
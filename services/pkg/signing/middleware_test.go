@@ -0,0 +1,174 @@
+package signing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func newTestVerifier() *Verifier {
+	return NewVerifier(map[string]string{"link-management": testSecret}, 5*time.Minute, NewMemoryNonces())
+}
+
+// newSignedRequest returns a DELETE signed by link-management.
+func newSignedRequest(t *testing.T, body string) *http.Request {
+	t.Helper()
+	signer, err := NewSigner("link-management", testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodDelete, "/api/cache/abc123", strings.NewReader(body))
+	if err := signer.Sign(req); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+// handlers returns the verifier wrapped as net/http and gin middleware, both
+// answering 204 with the service id in X-Verified-Service.
+func handlers(v *Verifier) map[string]http.Handler {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.DELETE("/api/cache/:code", v.Gin(), func(c *gin.Context) {
+		c.Header("X-Verified-Service", c.GetString("serviceID"))
+		c.Status(http.StatusNoContent)
+	})
+
+	plain := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serviceID, _ := ServiceID(r.Context())
+		w.Header().Set("X-Verified-Service", serviceID)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	return map[string]http.Handler{"net/http": plain, "gin": r}
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddlewareAcceptsSignedRequest(t *testing.T) {
+	for name, h := range handlers(newTestVerifier()) {
+		rec := serve(h, newSignedRequest(t, `{"reason":"updated"}`))
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("%s: status = %d, want %d: %s", name, rec.Code, http.StatusNoContent, rec.Body)
+		}
+		if got := rec.Header().Get("X-Verified-Service"); got != "link-management" {
+			t.Errorf("%s: service id = %q, want link-management", name, got)
+		}
+	}
+}
+
+func TestMiddlewareRejectsUnsignedRequest(t *testing.T) {
+	for name, h := range handlers(newTestVerifier()) {
+		rec := serve(h, httptest.NewRequest(http.MethodDelete, "/api/cache/abc123", nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want %d", name, rec.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestMiddlewareRejectsUnknownService(t *testing.T) {
+	v := NewVerifier(map[string]string{"redirect": testSecret}, 5*time.Minute, NewMemoryNonces())
+	for name, h := range handlers(v) {
+		rec := serve(h, newSignedRequest(t, ""))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want %d", name, rec.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestMiddlewareRejectsTamperedRequest(t *testing.T) {
+	tamper := map[string]func(*http.Request){
+		"body": func(req *http.Request) {
+			req.Body = io.NopCloser(strings.NewReader(`{"reason":"other"}`))
+		},
+		"path": func(req *http.Request) {
+			req.URL.Path = "/api/cache/other1"
+		},
+		"method": func(req *http.Request) {
+			req.Method = http.MethodPost
+		},
+		"service": func(req *http.Request) {
+			req.Header.Set(HeaderService, "analytics")
+		},
+		"signature": func(req *http.Request) {
+			req.Header.Set(HeaderSignature, strings.Repeat("00", 32))
+		},
+	}
+	for what, change := range tamper {
+		v := NewVerifier(map[string]string{"link-management": testSecret, "analytics": testSecret}, 5*time.Minute, NewMemoryNonces())
+		// The plain handler routes every method, so tampering is caught by the verifier
+		h := handlers(v)["net/http"]
+		req := newSignedRequest(t, `{"reason":"updated"}`)
+		change(req)
+		if rec := serve(h, req); rec.Code != http.StatusUnauthorized {
+			t.Errorf("tampered %s: status = %d, want %d", what, rec.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestMiddlewareRejectsStaleRequest(t *testing.T) {
+	for _, offset := range []time.Duration{-10 * time.Minute, 10 * time.Minute} {
+		for name, h := range handlers(newTestVerifier()) {
+			req := httptest.NewRequest(http.MethodDelete, "/api/cache/abc123", nil)
+			timestamp := strconv.FormatInt(time.Now().Add(offset).Unix(), 10)
+			nonce := strings.Repeat("ab", 16)
+			req.Header.Set(HeaderService, "link-management")
+			req.Header.Set(HeaderTimestamp, timestamp)
+			req.Header.Set(HeaderNonce, nonce)
+			req.Header.Set(HeaderSignature, hex.EncodeToString(sign([]byte(testSecret), req, "link-management", timestamp, nonce, nil)))
+
+			rec := serve(h, req)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("%s, signed %v away: status = %d, want %d", name, offset, rec.Code, http.StatusUnauthorized)
+			}
+			if !strings.Contains(rec.Body.String(), "stale signature") {
+				t.Errorf("%s, signed %v away: body = %s, want stale signature", name, offset, rec.Body)
+			}
+		}
+	}
+}
+
+func TestMiddlewareRejectsReplayedRequest(t *testing.T) {
+	for name, h := range handlers(newTestVerifier()) {
+		req := newSignedRequest(t, `{"reason":"updated"}`)
+		body, _ := io.ReadAll(req.Body)
+		replay := req.Clone(req.Context())
+
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if rec := serve(h, req); rec.Code != http.StatusNoContent {
+			t.Fatalf("%s: first status = %d, want %d", name, rec.Code, http.StatusNoContent)
+		}
+
+		replay.Body = io.NopCloser(bytes.NewReader(body))
+		rec := serve(h, replay)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: replay status = %d, want %d", name, rec.Code, http.StatusUnauthorized)
+		}
+		if !strings.Contains(rec.Body.String(), "replayed signature") {
+			t.Errorf("%s: replay body = %s, want replayed signature", name, rec.Body)
+		}
+	}
+}
+
+func TestMiddlewareRejectsOversizedBody(t *testing.T) {
+	for name, h := range handlers(newTestVerifier()) {
+		rec := serve(h, newSignedRequest(t, strings.Repeat("x", MaxBodySize+1)))
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: status = %d, want %d", name, rec.Code, http.StatusRequestEntityTooLarge)
+		}
+	}
+}
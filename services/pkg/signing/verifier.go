@@ -0,0 +1,128 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MaxBodySize bounds what a receiver should read to check a signature.
+const MaxBodySize = 1 << 20
+
+// NonceStore remembers the nonces of accepted signatures until they expire.
+// A receiver running several replicas needs a store they share, such as its
+// database, or a request replayed to another replica would be accepted.
+type NonceStore interface {
+	// Use records key and reports whether it was not recorded before.
+	Use(key string, expiresAt time.Time) (bool, error)
+}
+
+// RejectedError is returned by Verify for requests without a valid signature,
+// as opposed to failures reading the body or using the nonce store.
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return e.Reason
+}
+
+func reject(reason string) error {
+	return &RejectedError{Reason: reason}
+}
+
+// Verifier checks signatures made by a Signer with one of the shared secrets.
+type Verifier struct {
+	secrets map[string][]byte // By service id
+	maxSkew time.Duration
+	nonces  NonceStore
+}
+
+// NewVerifier accepts the services in secrets, keyed by service id, whose
+// timestamps are within maxSkew of the local clock.
+func NewVerifier(secrets map[string]string, maxSkew time.Duration, nonces NonceStore) *Verifier {
+	v := &Verifier{secrets: map[string][]byte{}, maxSkew: maxSkew, nonces: nonces}
+	for id, secret := range secrets {
+		v.secrets[id] = []byte(secret)
+	}
+	return v
+}
+
+// Verify returns the id of the service that signed req, or a *RejectedError
+// if it is not validly signed. The body should be limited to MaxBodySize,
+// e.g. with http.MaxBytesReader; Middleware and Gin do that.
+func (v *Verifier) Verify(req *http.Request) (string, error) {
+	serviceID := req.Header.Get(HeaderService)
+	timestamp := req.Header.Get(HeaderTimestamp)
+	nonce := req.Header.Get(HeaderNonce)
+	signature, err := hex.DecodeString(req.Header.Get(HeaderSignature))
+	if serviceID == "" || timestamp == "" || len(nonce) < 16 || err != nil || len(signature) == 0 {
+		return "", reject("missing signature")
+	}
+	secret, ok := v.secrets[serviceID]
+	if !ok {
+		return "", reject("unknown service")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", reject("invalid timestamp")
+	}
+	now := time.Now()
+	signedAt := time.Unix(unix, 0)
+	if signedAt.Before(now.Add(-v.maxSkew)) || signedAt.After(now.Add(v.maxSkew)) {
+		return "", reject("stale signature")
+	}
+
+	body, err := readBody(req)
+	if err != nil {
+		return "", err
+	}
+	if !hmac.Equal(signature, sign(secret, req, serviceID, timestamp, nonce, body)) {
+		return "", reject("invalid signature")
+	}
+
+	// Only remember nonces of valid signatures, so nobody can fill the store
+	fresh, err := v.nonces.Use(serviceID+":"+nonce, signedAt.Add(v.maxSkew))
+	if err != nil {
+		return "", err
+	}
+	if !fresh {
+		return "", reject("replayed signature")
+	}
+	return serviceID, nil
+}
+
+// MemoryNonces is a NonceStore for a receiver with a single replica.
+type MemoryNonces struct {
+	mu       sync.Mutex
+	nonces   map[string]time.Time // Seen nonces until they are too old to be accepted anyway
+	prunedAt time.Time
+}
+
+func NewMemoryNonces() *MemoryNonces {
+	return &MemoryNonces{nonces: map[string]time.Time{}}
+}
+
+func (m *MemoryNonces) Use(key string, expiresAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.prunedAt) > time.Minute {
+		for k, exp := range m.nonces {
+			if now.After(exp) {
+				delete(m.nonces, k)
+			}
+		}
+		m.prunedAt = now
+	}
+	if _, seen := m.nonces[key]; seen {
+		return false, nil
+	}
+	m.nonces[key] = expiresAt
+	return true, nil
+}